@@ -74,6 +74,10 @@ var LogCallDepth = 4
 // FuncLogError Record error log
 var FuncLogError func(ctx context.Context, err error) = defaultLogError
 
+// FuncLogInfo 记录info日志
+// FuncLogInfo Record info log
+var FuncLogInfo func(ctx context.Context, msg string) = defaultLogInfo
+
 // FuncLogPanic  记录panic日志,默认使用"defaultLogPanic"实现
 // FuncLogPanic Record panic log, using "defaultLogPanic" by default
 var FuncLogPanic func(ctx context.Context, err error) = defaultLogPanic
@@ -87,6 +91,10 @@ func defaultLogError(ctx context.Context, err error) {
 	hlog.Error(err)
 }
 
+func defaultLogInfo(ctx context.Context, msg string) {
+	hlog.Info(msg)
+}
+
 func defaultLogPanic(ctx context.Context, err error) {
 	defaultLogError(ctx, err)
 }
@@ -0,0 +1,208 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gitee.com/chunanyong/zorm"
+)
+
+// CitationSource 引用的来源,PromptBuilder开启引用时按照documentChunks的顺序从1开始编号,大模型在回答中使用[n]标注
+type CitationSource struct {
+	// Index 编号
+	Index int `json:"index"`
+	// DocumentChunkID 分块ID
+	DocumentChunkID string `json:"documentChunkID,omitempty"`
+	// DocumentID 文档ID
+	DocumentID string `json:"documentID,omitempty"`
+	// DocumentName 文档名称
+	DocumentName string `json:"documentName,omitempty"`
+	// KnowledgeBaseID 知识库ID
+	KnowledgeBaseID string `json:"knowledgeBaseID,omitempty"`
+	// KnowledgeBaseName 知识库名称
+	KnowledgeBaseName string `json:"knowledgeBaseName,omitempty"`
+	// HeadingPath 分块所在的标题路径,从一级标题开始
+	HeadingPath []string `json:"headingPath,omitempty"`
+	// FilePath 文档的文件路径
+	FilePath string `json:"filePath,omitempty"`
+	// URL 网页抓取的文档地址
+	URL string `json:"url,omitempty"`
+	// Cited 回答中是否引用了
+	Cited bool `json:"cited,omitempty"`
+}
+
+// citationMarkerRegexp 回答中的引用标记,例如[1],[1,2]
+var citationMarkerRegexp = regexp.MustCompile(`\[(\d+(?:\s*[,，]\s*\d+)*)\]`)
+
+// defaultCitationPrompt 要求大模型标注引用的提示词
+const defaultCitationPrompt = "\n回答时在使用了文档内容的句子后面用[n]标注文档的编号,例如[1],多个文档使用[1][2],不要编造不存在的编号."
+
+// buildCitationSources 按照documentChunks的顺序生成引用来源,查询文档名称,知识库,文件路径,网页地址和标题路径
+func buildCitationSources(ctx context.Context, documentChunks []DocumentChunk) ([]CitationSource, error) {
+	documentIDs := make([]string, 0, len(documentChunks))
+	for _, documentChunk := range documentChunks {
+		if documentChunk.DocumentID != "" {
+			documentIDs = append(documentIDs, documentChunk.DocumentID)
+		}
+	}
+	documentMap := make(map[string]Document)
+	urlMap := make(map[string]string)
+	if len(documentIDs) > 0 {
+		finder := zorm.NewSelectFinder(tableDocumentName, "id,name,knowledge_base_id,knowledge_base_name,file_path").Append("WHERE id IN (?)", documentIDs)
+		finder.SelectTotalCount = false
+		documents := make([]Document, 0)
+		err := zorm.Query(ctx, finder, &documents, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, document := range documents {
+			documentMap[document.Id] = document
+		}
+		finder = zorm.NewSelectFinder(tableWebCrawlPageName, "document_id,url").Append("WHERE document_id IN (?)", documentIDs)
+		finder.SelectTotalCount = false
+		pages := make([]WebCrawlPage, 0)
+		err = zorm.Query(ctx, finder, &pages, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, page := range pages {
+			urlMap[page.DocumentID] = page.URL
+		}
+	}
+
+	sources := make([]CitationSource, 0, len(documentChunks))
+	for i, documentChunk := range documentChunks {
+		source := CitationSource{Index: i + 1, DocumentChunkID: documentChunk.Id, DocumentID: documentChunk.DocumentID, KnowledgeBaseID: documentChunk.KnowledgeBaseID}
+		if document, has := documentMap[documentChunk.DocumentID]; has {
+			source.DocumentName = document.Name
+			source.KnowledgeBaseID = document.KnowledgeBaseID
+			source.KnowledgeBaseName = document.KnowledgeBaseName
+			source.FilePath = document.FilePath
+		}
+		source.URL = urlMap[documentChunk.DocumentID]
+		headingPath, err := findDocumentChunkHeadingPath(ctx, documentChunk)
+		if err != nil {
+			return nil, err
+		}
+		source.HeadingPath = headingPath
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// findDocumentChunkHeadingPath 根据ParentID向上查询标题,返回从一级标题开始的标题路径
+func findDocumentChunkHeadingPath(ctx context.Context, documentChunk DocumentChunk) ([]string, error) {
+	headingPath := make([]string, 0)
+	if documentChunk.Title != "" {
+		headingPath = append(headingPath, documentChunk.Title)
+	}
+	parentID := documentChunk.ParentID
+	if parentID == "" && documentChunk.Title == "" && documentChunk.Id != "" {
+		// 检索结果可能只有ID,查询分块的标题和上级ID
+		finder := zorm.NewSelectFinder(tableDocumentChunkName, "title,parent_id").Append("WHERE id=?", documentChunk.Id)
+		chunk := DocumentChunk{}
+		_, err := zorm.QueryRow(ctx, finder, &chunk)
+		if err != nil {
+			return nil, err
+		}
+		if chunk.Title != "" {
+			headingPath = append(headingPath, chunk.Title)
+		}
+		parentID = chunk.ParentID
+	}
+	// 限制层级,避免错误数据造成死循环
+	for depth := 0; parentID != "" && depth < 10; depth++ {
+		finder := zorm.NewSelectFinder(tableDocumentChunkName, "title,parent_id").Append("WHERE id=?", parentID)
+		parent := DocumentChunk{}
+		has, err := zorm.QueryRow(ctx, finder, &parent)
+		if err != nil {
+			return nil, err
+		}
+		if !has {
+			break
+		}
+		if parent.Title != "" {
+			headingPath = append(headingPath, parent.Title)
+		}
+		parentID = parent.ParentID
+	}
+	for i, j := 0, len(headingPath)-1; i < j; i, j = i+1, j-1 {
+		headingPath[i], headingPath[j] = headingPath[j], headingPath[i]
+	}
+	return headingPath, nil
+}
+
+// numberCitationChunks 复制documentChunks,内容前面加上编号[n],用于渲染提示词
+func numberCitationChunks(documentChunks []DocumentChunk) []DocumentChunk {
+	numbered := make([]DocumentChunk, len(documentChunks))
+	for i, documentChunk := range documentChunks {
+		documentChunk.Markdown = "[" + strconv.Itoa(i+1) + "] " + documentChunk.Markdown
+		numbered[i] = documentChunk
+	}
+	return numbered
+}
+
+// markCitedSources 根据回答中的[n]标记,设置引用来源的Cited
+func markCitedSources(content string, sources []CitationSource) []CitationSource {
+	cited := make(map[int]bool)
+	for _, match := range citationMarkerRegexp.FindAllStringSubmatch(content, -1) {
+		for _, number := range strings.FieldsFunc(match[1], func(r rune) bool { return r == ',' || r == '，' }) {
+			index, err := strconv.Atoi(strings.TrimSpace(number))
+			if err == nil {
+				cited[index] = true
+			}
+		}
+	}
+	marked := make([]CitationSource, len(sources))
+	for i, source := range sources {
+		source.Cited = cited[source.Index]
+		marked[i] = source
+	}
+	return marked
+}
+
+// inputCitations 大模型回答完成后,标记input["citations"]中引用的来源,没有开启引用返回nil
+func inputCitations(input map[string]any, content string) []CitationSource {
+	sources, ok := input["citations"].([]CitationSource)
+	if !ok || len(sources) < 1 {
+		return nil
+	}
+	sources = markCitedSources(content, sources)
+	input["citations"] = sources
+	return sources
+}
+
+// citedSourcesJson 回答中引用的来源json,用于保存到消息记录
+func citedSourcesJson(sources []CitationSource) string {
+	cited := make([]CitationSource, 0)
+	for _, source := range sources {
+		if source.Cited {
+			cited = append(cited, source)
+		}
+	}
+	if len(cited) < 1 {
+		return ""
+	}
+	citedByte, _ := json.Marshal(cited)
+	return string(citedByte)
+}
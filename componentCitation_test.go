@@ -0,0 +1,52 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkCitedSources(t *testing.T) {
+	sources := []CitationSource{{Index: 1}, {Index: 2}, {Index: 3}, {Index: 4}}
+	marked := markCitedSources("先打开设置[1],再关闭自动更新[2，4].数组下标a[0]不是引用", sources)
+	cited := make([]int, 0)
+	for _, source := range marked {
+		if source.Cited {
+			cited = append(cited, source.Index)
+		}
+	}
+	if len(cited) != 3 || cited[0] != 1 || cited[1] != 2 || cited[2] != 4 {
+		t.Errorf("unexpected cited: %v", cited)
+	}
+	// 不修改原来的来源
+	if sources[0].Cited {
+		t.Error("sources should not be modified")
+	}
+	if json := citedSourcesJson(marked); !strings.Contains(json, `"index":4`) || strings.Contains(json, `"index":3`) {
+		t.Errorf("unexpected json: %s", json)
+	}
+}
+
+func TestNumberCitationChunks(t *testing.T) {
+	documentChunks := []DocumentChunk{{Markdown: "a"}, {Markdown: "b"}}
+	numbered := numberCitationChunks(documentChunks)
+	if numbered[0].Markdown != "[1] a" || numbered[1].Markdown != "[2] b" || documentChunks[0].Markdown != "a" {
+		t.Errorf("unexpected numbered: %v", numbered)
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"gitee.com/chunanyong/zorm"
+)
+
+const (
+	// contextExpandModeNeighbor 扩展前后相邻的分块
+	contextExpandModeNeighbor = "neighbor"
+	// contextExpandModeParent 扩展到上级标题的整个章节,超过长度时在章节内扩展相邻的分块
+	contextExpandModeParent = "parent"
+)
+
+// ContextExpander 上下文扩展("small-to-big"),放到检索组件之后,每个命中的分块扩展前后相邻的分块或者上级章节,不超过最大长度.
+// 同一个文档重叠和相邻的窗口合并为一段,按照文档中的位置拼接,替换documentChunks,大模型可以看到完整的步骤,而不是片段
+type ContextExpander struct {
+	// Mode 扩展方式,neighbor(默认)前后相邻的分块,parent上级标题的章节
+	Mode string `json:"mode,omitempty"`
+	// Window neighbor方式前后各扩展几个分块,默认1
+	Window int `json:"window,omitempty"`
+	// MaxLength 每个命中分块扩展后的最大长度,默认2000
+	MaxLength int `json:"maxLength,omitempty"`
+}
+
+// chunkWindow 扩展后的分块范围,包含start和end
+type chunkWindow struct {
+	Start int
+	End   int
+	// Hits 命中的分块索引
+	Hits []int
+}
+
+func (component *ContextExpander) Initialization(ctx context.Context, input map[string]any) error {
+	if component.Mode == "" {
+		component.Mode = contextExpandModeNeighbor
+	}
+	if component.Window == 0 {
+		component.Window = 1
+	}
+	if component.MaxLength == 0 {
+		component.MaxLength = 2000
+	}
+	return nil
+}
+
+func (component *ContextExpander) Run(ctx context.Context, input map[string]any) error {
+	if input["documentChunks"] == nil {
+		err := errors.New(funcT("input['documentChunks'] cannot be empty"))
+		input[errorKey] = err
+		return err
+	}
+	documentChunks := input["documentChunks"].([]DocumentChunk)
+	if len(documentChunks) < 1 {
+		return nil
+	}
+
+	// 按照文档分组,保持文档第一次出现的顺序
+	documentIDs := make([]string, 0)
+	hitMap := make(map[string][]DocumentChunk)
+	for _, documentChunk := range documentChunks {
+		if _, has := hitMap[documentChunk.DocumentID]; !has {
+			documentIDs = append(documentIDs, documentChunk.DocumentID)
+		}
+		hitMap[documentChunk.DocumentID] = append(hitMap[documentChunk.DocumentID], documentChunk)
+	}
+
+	expandDCS := make([]DocumentChunk, 0, len(documentChunks))
+	for _, documentID := range documentIDs {
+		hits := hitMap[documentID]
+		list, err := findDocumentChunksByDocumentID(ctx, documentID)
+		if err != nil {
+			input[errorKey] = err
+			return err
+		}
+		indexMap := make(map[string]int, len(list))
+		for i := 0; i < len(list); i++ {
+			indexMap[list[i].Id] = i
+		}
+		hitIndexes := make([]int, 0, len(hits))
+		scoreMap := make(map[int]float32, len(hits))
+		for _, hit := range hits {
+			index, has := indexMap[hit.Id]
+			if !has { // 历史版本等不在当前文档中的分块,保持原样
+				expandDCS = append(expandDCS, hit)
+				continue
+			}
+			if _, has := scoreMap[index]; !has {
+				hitIndexes = append(hitIndexes, index)
+			}
+			scoreMap[index] = hit.Score
+		}
+		windows := expandChunkWindows(list, hitIndexes, component.Mode, component.Window, component.MaxLength)
+		for _, window := range windows {
+			passage := mergeChunkWindow(list, window)
+			// 使用分数最高的命中分块的ID和分数
+			for j, hitIndex := range window.Hits {
+				if j == 0 || scoreMap[hitIndex] > passage.Score {
+					passage.Id = list[hitIndex].Id
+					passage.Score = scoreMap[hitIndex]
+				}
+			}
+			expandDCS = append(expandDCS, passage)
+		}
+	}
+	input["documentChunks"] = expandDCS
+	return nil
+}
+
+// findDocumentChunksByDocumentID 查询文档的所有分块,按照文档中的位置排序
+func findDocumentChunksByDocumentID(ctx context.Context, documentID string) ([]DocumentChunk, error) {
+	finder := zorm.NewSelectFinder(tableDocumentChunkName, "id,document_id,knowledge_base_id,title,parent_id,level,markdown,metadata,sortno").Append("WHERE document_id=? order by sortno asc", documentID)
+	finder.SelectTotalCount = false
+	list := make([]DocumentChunk, 0)
+	err := zorm.Query(ctx, finder, &list, nil)
+	return list, err
+}
+
+// expandChunkWindows 扩展命中的分块,合并重叠和相邻的窗口,按照位置排序
+func expandChunkWindows(list []DocumentChunk, hitIndexes []int, mode string, window int, maxLength int) []chunkWindow {
+	windows := make([]chunkWindow, 0, len(hitIndexes))
+	for _, hitIndex := range hitIndexes {
+		lower, upper := 0, len(list)-1
+		limit := window
+		if mode == contextExpandModeParent {
+			if parentStart, parentEnd, has := parentSectionRange(list, hitIndex); has {
+				lower, upper = parentStart, parentEnd
+				limit = len(list)
+			}
+		}
+		start, end := hitIndex, hitIndex
+		length := runeLength(list[hitIndex].Markdown)
+		// 前后交替扩展,不超过最大长度
+		for step := 1; step <= limit; step++ {
+			expanded := false
+			if start-1 >= lower && length+runeLength(list[start-1].Markdown) <= maxLength {
+				start--
+				length += runeLength(list[start].Markdown)
+				expanded = true
+			}
+			if end+1 <= upper && length+runeLength(list[end+1].Markdown) <= maxLength {
+				end++
+				length += runeLength(list[end].Markdown)
+				expanded = true
+			}
+			if !expanded {
+				break
+			}
+		}
+		windows = append(windows, chunkWindow{Start: start, End: end, Hits: []int{hitIndex}})
+	}
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].Start < windows[j].Start
+	})
+	merged := make([]chunkWindow, 0, len(windows))
+	for _, w := range windows {
+		last := len(merged) - 1
+		if last >= 0 && w.Start <= merged[last].End+1 {
+			if w.End > merged[last].End {
+				merged[last].End = w.End
+			}
+			merged[last].Hits = append(merged[last].Hits, w.Hits...)
+			continue
+		}
+		merged = append(merged, w)
+	}
+	return merged
+}
+
+// parentSectionRange 上级标题章节的范围,从上级标题开始,到下一个同级或者更高级的标题之前
+func parentSectionRange(list []DocumentChunk, hitIndex int) (int, int, bool) {
+	parentID := list[hitIndex].ParentID
+	if parentID == "" {
+		return 0, 0, false
+	}
+	parentIndex := -1
+	for i := hitIndex - 1; i >= 0; i-- {
+		if list[i].Id == parentID {
+			parentIndex = i
+			break
+		}
+	}
+	if parentIndex < 0 {
+		return 0, 0, false
+	}
+	end := len(list) - 1
+	for i := parentIndex + 1; i < len(list); i++ {
+		if list[i].Level > 0 && list[i].Level <= list[parentIndex].Level {
+			end = i - 1
+			break
+		}
+	}
+	return parentIndex, end, true
+}
+
+// mergeChunkWindow 按照位置拼接窗口内的分块,有标题的分块拼接markdown标题
+func mergeChunkWindow(list []DocumentChunk, window chunkWindow) DocumentChunk {
+	passage := list[window.Start]
+	var sb strings.Builder
+	for i := window.Start; i <= window.End; i++ {
+		documentChunk := list[i]
+		if sb.Len() > 0 {
+			sb.WriteString("\n\n")
+		}
+		if documentChunk.Title != "" && documentChunk.Level > 0 && !strings.HasPrefix(strings.TrimSpace(documentChunk.Markdown), "#") {
+			sb.WriteString(strings.Repeat("#", documentChunk.Level) + " " + documentChunk.Title + "\n")
+		}
+		sb.WriteString(documentChunk.Markdown)
+	}
+	passage.Markdown = sb.String()
+	return passage
+}
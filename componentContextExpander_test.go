@@ -0,0 +1,53 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandChunkWindows(t *testing.T) {
+	list := []DocumentChunk{
+		{Id: "h1", Title: "安装", Level: 1, Markdown: "aaaa"},
+		{Id: "c1", ParentID: "h1", Markdown: "bbbb"},
+		{Id: "c2", ParentID: "h1", Markdown: "cccc"},
+		{Id: "h2", Title: "卸载", Level: 1, Markdown: "dddd"},
+		{Id: "c3", ParentID: "h2", Markdown: "eeee"},
+		{Id: "c4", ParentID: "h2", Markdown: "ffff"},
+	}
+	// c1和c2的窗口重叠,合并为一段
+	windows := expandChunkWindows(list, []int{2, 1}, contextExpandModeNeighbor, 1, 100)
+	if len(windows) != 1 || windows[0].Start != 0 || windows[0].End != 3 || len(windows[0].Hits) != 2 {
+		t.Errorf("unexpected windows: %v", windows)
+	}
+	// 长度限制,只能扩展一个分块
+	windows = expandChunkWindows(list, []int{4}, contextExpandModeNeighbor, 2, 8)
+	if len(windows) != 1 || windows[0].Start != 3 || windows[0].End != 4 {
+		t.Errorf("unexpected windows: %v", windows)
+	}
+	// 上级章节,不超过下一个同级标题
+	windows = expandChunkWindows(list, []int{1}, contextExpandModeParent, 1, 100)
+	if len(windows) != 1 || windows[0].Start != 0 || windows[0].End != 2 {
+		t.Errorf("unexpected windows: %v", windows)
+	}
+	passage := mergeChunkWindow(list, windows[0])
+	if passage.Id != "h1" || !strings.HasPrefix(passage.Markdown, "# 安装\naaaa\n\nbbbb") {
+		t.Errorf("unexpected passage: %v", passage.Markdown)
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gitee.com/chunanyong/zorm"
+)
+
+// DocumentChunkContextualizer 使用大模型为分块生成一到两句话的上下文,说明分块在文档中的位置和指代的对象,例如 "它必须在重启之前开启" 中的 "它".
+// 上下文保存到DocumentChunk.Context,向量化和全文检索时拼接到内容前面,Markdown保持不变用于展示.
+// 放到indexPipeline的DocumentSplitter之后,向量化组件之前.多个分块合并到一次请求,内容没有变化的分块复用上次的上下文,向量化时可以命中向量缓存
+type DocumentChunkContextualizer struct {
+	OpenAIChatGenerator
+
+	// Prompt 生成上下文的提示词
+	Prompt string `json:"prompt,omitempty"`
+	// BatchSize 每次请求大模型的分块数量,默认10
+	BatchSize int `json:"batchSize,omitempty"`
+	// MaxContextLength 上下文的最大长度,默认200
+	MaxContextLength int `json:"maxContextLength,omitempty"`
+	// MaxChunkLength 发送给大模型的分块最大长度,默认1000
+	MaxChunkLength int `json:"maxChunkLength,omitempty"`
+	// MaxDocumentLength 文档没有摘要和目录时,使用文档开头的内容,默认2000
+	MaxDocumentLength int `json:"maxDocumentLength,omitempty"`
+}
+
+// chunkContextResult 大模型返回的分块上下文
+type chunkContextResult struct {
+	Contexts []struct {
+		Id      int    `json:"id"`
+		Context string `json:"context"`
+	} `json:"contexts"`
+}
+
+func (component *DocumentChunkContextualizer) Initialization(ctx context.Context, input map[string]any) error {
+	component.OpenAIChatGenerator.Initialization(ctx, input)
+	if component.Prompt == "" {
+		component.Prompt = `根据提供的文档信息,为每个分块写一到两句话的上下文,说明分块在文档中的位置,主题和指代的对象,用于提升分块的检索效果.
+		只返回上下文,不要重复分块的内容,使用和分块相同的语言.
+		返回的json格式示例:{"contexts":[{"id":1,"context":"<分块1的上下文>"},{"id":2,"context":"<分块2的上下文>"}]}
+		`
+	}
+	if component.BatchSize == 0 {
+		component.BatchSize = 10
+	}
+	if component.MaxContextLength == 0 {
+		component.MaxContextLength = 200
+	}
+	if component.MaxChunkLength == 0 {
+		component.MaxChunkLength = 1000
+	}
+	if component.MaxDocumentLength == 0 {
+		component.MaxDocumentLength = 2000
+	}
+	return nil
+}
+
+func (component *DocumentChunkContextualizer) Run(ctx context.Context, input map[string]any) error {
+	if input["document"] == nil || input["documentChunks"] == nil {
+		return nil
+	}
+	document := input["document"].(*Document)
+	documentChunks := input["documentChunks"].([]DocumentChunk)
+
+	// 内容没有变化的分块复用上次的上下文,向量化的文本不变,可以命中向量缓存
+	previousContexts, err := findDocumentChunkContexts(ctx, document.Id)
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+	pending := make([]int, 0, len(documentChunks))
+	for i := 0; i < len(documentChunks); i++ {
+		// 重复的分块不向量化,不需要上下文
+		if documentChunks[i].DuplicateOf != "" || strings.TrimSpace(documentChunks[i].Markdown) == "" {
+			continue
+		}
+		if chunkContext, has := previousContexts[documentChunks[i].Markdown]; has {
+			documentChunks[i].Context = chunkContext
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	documentInfo := component.documentInfo(document)
+	for start := 0; start < len(pending); start += component.BatchSize {
+		end := start + component.BatchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		batch := pending[start:end]
+		contexts, err := component.generateContexts(ctx, documentInfo, documentChunks, batch)
+		if err != nil {
+			// 上下文只是补充检索,生成失败不影响文档索引
+			FuncLogError(ctx, fmt.Errorf("DocumentChunkContextualizer %s: %w", document.Id, err))
+			continue
+		}
+		for j, index := range batch {
+			documentChunks[index].Context = contexts[j]
+		}
+	}
+	input["documentChunks"] = documentChunks
+	return nil
+}
+
+// documentInfo 文档的名称,摘要和目录,都没有时使用文档开头的内容
+func (component *DocumentChunkContextualizer) documentInfo(document *Document) string {
+	var sb strings.Builder
+	sb.WriteString("文档名称:" + document.Name + "\n")
+	if document.Summary != "" {
+		sb.WriteString("文档摘要:" + document.Summary + "\n")
+	}
+	if document.Toc != "" {
+		sb.WriteString("文档目录:" + document.Toc + "\n")
+	}
+	if document.Summary == "" && document.Toc == "" {
+		sb.WriteString("文档开头的内容:" + truncateRunes(document.Markdown, component.MaxDocumentLength) + "\n")
+	}
+	return sb.String()
+}
+
+// generateContexts 一次请求生成多个分块的上下文,返回和batch顺序一致的上下文,大模型没有返回的分块为空
+func (component *DocumentChunkContextualizer) generateContexts(ctx context.Context, documentInfo string, documentChunks []DocumentChunk, batch []int) ([]string, error) {
+	var sb strings.Builder
+	sb.WriteString(component.Prompt)
+	sb.WriteString(documentInfo)
+	sb.WriteString("分块列表:\n")
+	for j, index := range batch {
+		sb.WriteString("<chunk id=\"" + strconv.Itoa(j+1) + "\">\n")
+		sb.WriteString(truncateRunes(documentChunks[index].Markdown, component.MaxChunkLength))
+		sb.WriteString("\n</chunk>\n")
+	}
+	resultJson, err := llmJSONResult(ctx, component.OpenAIChatGenerator, sb.String())
+	if err != nil {
+		return nil, err
+	}
+	return parseChunkContexts(resultJson, len(batch), component.MaxContextLength)
+}
+
+// parseChunkContexts 解析大模型返回的json,id从1开始,忽略超出范围的id
+func parseChunkContexts(resultJson string, size int, maxLength int) ([]string, error) {
+	result := chunkContextResult{}
+	err := json.Unmarshal([]byte(resultJson), &result)
+	if err != nil {
+		return nil, err
+	}
+	contexts := make([]string, size)
+	for _, chunkContext := range result.Contexts {
+		if chunkContext.Id < 1 || chunkContext.Id > size {
+			continue
+		}
+		contexts[chunkContext.Id-1] = truncateRunes(strings.TrimSpace(chunkContext.Context), maxLength)
+	}
+	return contexts, nil
+}
+
+// findDocumentChunkContexts 查询文档已有分块的上下文,key是分块的内容
+func findDocumentChunkContexts(ctx context.Context, documentID string) (map[string]string, error) {
+	finder := zorm.NewSelectFinder(tableDocumentChunkName, "markdown,context").Append("WHERE document_id=? and context IS NOT NULL and context!=''", documentID)
+	finder.SelectTotalCount = false
+	documentChunks := make([]DocumentChunk, 0)
+	err := zorm.Query(ctx, finder, &documentChunks, nil)
+	if err != nil {
+		return nil, err
+	}
+	contexts := make(map[string]string, len(documentChunks))
+	for _, documentChunk := range documentChunks {
+		contexts[documentChunk.Markdown] = documentChunk.Context
+	}
+	return contexts, nil
+}
+
+// documentChunkEmbeddingText 向量化分块的文本,有上下文时拼接到内容前面
+func documentChunkEmbeddingText(documentChunk DocumentChunk) string {
+	if documentChunk.Context == "" {
+		return documentChunk.Markdown
+	}
+	return documentChunk.Context + "\n\n" + documentChunk.Markdown
+}
+
+// truncateRunes 截取前maxLength个字符
+func truncateRunes(s string, maxLength int) string {
+	runes := []rune(s)
+	if maxLength <= 0 || len(runes) <= maxLength {
+		return s
+	}
+	return string(runes[:maxLength])
+}
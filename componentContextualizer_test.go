@@ -0,0 +1,43 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseChunkContexts(t *testing.T) {
+	resultJson := `{"contexts":[{"id":2,"context":" 第二章 安装步骤 "},{"id":1,"context":"第一章 产品介绍"},{"id":5,"context":"越界"}]}`
+	contexts, err := parseChunkContexts(resultJson, 3, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(contexts, "|") != "第一章 产品|第二章 安装|" {
+		t.Errorf("unexpected contexts: %v", contexts)
+	}
+}
+
+func TestDocumentChunkEmbeddingText(t *testing.T) {
+	if text := documentChunkEmbeddingText(DocumentChunk{Markdown: "内容"}); text != "内容" {
+		t.Errorf("unexpected text: %s", text)
+	}
+	if text := documentChunkEmbeddingText(DocumentChunk{Markdown: "内容", Context: "上下文"}); text != "上下文\n\n内容" {
+		t.Errorf("unexpected text: %s", text)
+	}
+}
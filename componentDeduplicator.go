@@ -0,0 +1,366 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gitee.com/chunanyong/zorm"
+)
+
+const (
+	// dedupAlgorithmSimHash 64位SimHash,相似度是 1-海明距离/64
+	dedupAlgorithmSimHash = "simhash"
+	// dedupAlgorithmMinHash MinHash,相似度是相同的最小hash的比例,近似Jaccard相似度
+	dedupAlgorithmMinHash = "minhash"
+	// dedupActionDrop 删除重复的分块
+	dedupActionDrop = "drop"
+	// dedupActionLink 保留重复的分块,不向量化,关联到相似的分块
+	dedupActionLink = "link"
+	// minHashSize MinHash的hash函数个数
+	minHashSize = 64
+	// dedupShingleSize 计算签名的字符窗口大小
+	dedupShingleSize = 3
+)
+
+// DocumentChunkDeduplicator 分块近似去重,放到indexPipeline的DocumentSplitter之后,向量化之前.
+// 使用SimHash或者MinHash计算分块的签名,和同一个知识库中其他文档的分块比较,相似度超过阈值的分块删除或者关联
+type DocumentChunkDeduplicator struct {
+	// Algorithm 签名算法 simhash(默认) 或者 minhash
+	Algorithm string `json:"algorithm,omitempty"`
+	// Threshold 相似度阈值,默认0.9
+	Threshold float64 `json:"threshold,omitempty"`
+	// Action 重复分块的处理方式,drop(默认)删除分块,link保留分块但是不向量化
+	Action string `json:"action,omitempty"`
+	// MinLength 少于这个长度的分块不去重,默认20
+	MinLength int `json:"minLength,omitempty"`
+}
+
+func (component *DocumentChunkDeduplicator) Initialization(ctx context.Context, input map[string]any) error {
+	if component.Algorithm == "" {
+		component.Algorithm = dedupAlgorithmSimHash
+	}
+	if component.Algorithm != dedupAlgorithmSimHash && component.Algorithm != dedupAlgorithmMinHash {
+		return fmt.Errorf("Initialization DocumentChunkDeduplicator error:algorithm %s is not supported", component.Algorithm)
+	}
+	if component.Threshold <= 0 {
+		component.Threshold = 0.9
+	}
+	if component.Action == "" {
+		component.Action = dedupActionDrop
+	}
+	if component.Action != dedupActionDrop && component.Action != dedupActionLink {
+		return fmt.Errorf("Initialization DocumentChunkDeduplicator error:action %s is not supported", component.Action)
+	}
+	if component.MinLength <= 0 {
+		component.MinLength = 20
+	}
+	return nil
+}
+
+func (component *DocumentChunkDeduplicator) Run(ctx context.Context, input map[string]any) error {
+	if input["document"] == nil {
+		err := errors.New(funcT("The document of DocumentChunkDeduplicator cannot be empty"))
+		input[errorKey] = err
+		return err
+	}
+	document := input["document"].(*Document)
+	if input["documentChunks"] == nil {
+		err := errors.New(funcT("input['documentChunks'] cannot be empty"))
+		input[errorKey] = err
+		return err
+	}
+	documentChunks := input["documentChunks"].([]DocumentChunk)
+
+	// 知识库中其他文档的签名,文档重新索引时不和自己比较
+	existing := make([]DocumentChunkSignature, 0)
+	finder := zorm.NewSelectFinder(tableDocumentChunkSignatureName, "id,document_id,signature").Append("WHERE knowledge_base_id=? and algorithm=? and document_id!=?", document.KnowledgeBaseID, component.Algorithm, document.Id)
+	finder.SelectTotalCount = false
+	err := zorm.Query(ctx, finder, &existing, nil)
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+
+	kept, duplicates, signatures := component.dedup(documentChunks, existing)
+	err = fillDuplicateDocumentNames(ctx, document, duplicates)
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+	now := time.Now().Format("2006-01-02 15:04:05")
+	_, err = zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		for _, tableName := range []string{tableDocumentChunkSignatureName, tableDocumentChunkDuplicateName} {
+			f := zorm.NewDeleteFinder(tableName).Append("WHERE document_id=?", document.Id)
+			if _, err := zorm.UpdateFinder(ctx, f); err != nil {
+				return nil, err
+			}
+		}
+		entities := make([]zorm.IEntityStruct, 0, len(signatures)+len(duplicates))
+		for i := 0; i < len(signatures); i++ {
+			signatures[i].KnowledgeBaseID = document.KnowledgeBaseID
+			entities = append(entities, &signatures[i])
+		}
+		for i := 0; i < len(duplicates); i++ {
+			duplicates[i].CreateTime = now
+			entities = append(entities, &duplicates[i])
+		}
+		if len(entities) < 1 {
+			return nil, nil
+		}
+		return zorm.InsertSlice(ctx, entities)
+	})
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+	input["documentChunks"] = kept
+	return nil
+}
+
+// dedup 比较分块和已有的签名,返回保留的分块,重复的分块和需要保存的签名.只保存不重复分块的签名
+func (component *DocumentChunkDeduplicator) dedup(documentChunks []DocumentChunk, existing []DocumentChunkSignature) ([]DocumentChunk, []DocumentChunkDuplicate, []DocumentChunkSignature) {
+	type candidate struct {
+		id         string
+		documentID string
+		signature  []uint64
+	}
+	candidates := make([]candidate, 0, len(existing)+len(documentChunks))
+	for _, s := range existing {
+		signature, err := parseChunkSignature(s.Signature)
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, candidate{id: s.Id, documentID: s.DocumentID, signature: signature})
+	}
+
+	kept := make([]DocumentChunk, 0, len(documentChunks))
+	duplicates := make([]DocumentChunkDuplicate, 0)
+	signatures := make([]DocumentChunkSignature, 0, len(documentChunks))
+	// 删除的分块ID对应的上级ID,用于修复子节点的ParentID
+	dropped := make(map[string]string)
+	for _, documentChunk := range documentChunks {
+		if runeLength(strings.TrimSpace(documentChunk.Markdown)) < component.MinLength {
+			kept = append(kept, documentChunk)
+			continue
+		}
+		signature := chunkSignature(component.Algorithm, documentChunk.Markdown)
+		bestIndex, bestSimilarity := -1, 0.0
+		for i := range candidates {
+			similarity := chunkSimilarity(component.Algorithm, signature, candidates[i].signature)
+			if similarity > bestSimilarity {
+				bestIndex, bestSimilarity = i, similarity
+			}
+		}
+		if bestIndex < 0 || bestSimilarity < component.Threshold {
+			candidates = append(candidates, candidate{id: documentChunk.Id, documentID: documentChunk.DocumentID, signature: signature})
+			signatures = append(signatures, DocumentChunkSignature{Id: documentChunk.Id, DocumentID: documentChunk.DocumentID, Algorithm: component.Algorithm, Signature: formatChunkSignature(signature)})
+			kept = append(kept, documentChunk)
+			continue
+		}
+
+		duplicates = append(duplicates, DocumentChunkDuplicate{
+			Id:                    documentChunk.Id,
+			KnowledgeBaseID:       documentChunk.KnowledgeBaseID,
+			DocumentID:            documentChunk.DocumentID,
+			DuplicateOf:           candidates[bestIndex].id,
+			DuplicateOfDocumentID: candidates[bestIndex].documentID,
+			Similarity:            bestSimilarity,
+			Action:                component.Action,
+			Markdown:              string([]rune(documentChunk.Markdown)[:min(200, runeLength(documentChunk.Markdown))]),
+		})
+		if component.Action == dedupActionLink {
+			documentChunk.DuplicateOf = candidates[bestIndex].id
+			kept = append(kept, documentChunk)
+		} else {
+			dropped[documentChunk.Id] = documentChunk.ParentID
+		}
+	}
+
+	if len(dropped) > 0 {
+		// 重新关联上一个和下一个节点,子节点关联到删除节点的上级
+		for i := range kept {
+			for hasKey(dropped, kept[i].ParentID) {
+				kept[i].ParentID = dropped[kept[i].ParentID]
+			}
+			kept[i].PreID, kept[i].NextID = "", ""
+			if i > 0 {
+				kept[i].PreID = kept[i-1].Id
+			}
+			if i < len(kept)-1 {
+				kept[i].NextID = kept[i+1].Id
+			}
+		}
+	}
+	return kept, duplicates, signatures
+}
+
+// hasKey map中是否存在key
+func hasKey(m map[string]string, key string) bool {
+	_, has := m[key]
+	return has
+}
+
+// fillDuplicateDocumentNames 填充重复分块的文档名称
+func fillDuplicateDocumentNames(ctx context.Context, document *Document, duplicates []DocumentChunkDuplicate) error {
+	if len(duplicates) < 1 {
+		return nil
+	}
+	ids := make([]string, 0)
+	for _, duplicate := range duplicates {
+		ids = append(ids, duplicate.DuplicateOfDocumentID)
+	}
+	finder := zorm.NewSelectFinder(tableDocumentName, "id,name").Append("WHERE id IN (?)", ids)
+	finder.SelectTotalCount = false
+	documents := make([]Document, 0)
+	err := zorm.Query(ctx, finder, &documents, nil)
+	if err != nil {
+		return err
+	}
+	names := map[string]string{document.Id: document.Name}
+	for _, d := range documents {
+		names[d.Id] = d.Name
+	}
+	for i := range duplicates {
+		duplicates[i].DocumentName = document.Name
+		duplicates[i].DuplicateOfDocumentName = names[duplicates[i].DuplicateOfDocumentID]
+	}
+	return nil
+}
+
+// chunkShingles 文本规范化后按照字符窗口拆分,忽略大小写、空白和标点,兼容中文
+func chunkShingles(text string) []string {
+	runes := make([]rune, 0, len(text))
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			runes = append(runes, r)
+		}
+	}
+	if len(runes) <= dedupShingleSize {
+		return []string{string(runes)}
+	}
+	shingles := make([]string, 0, len(runes)-dedupShingleSize+1)
+	for i := 0; i+dedupShingleSize <= len(runes); i++ {
+		shingles = append(shingles, string(runes[i:i+dedupShingleSize]))
+	}
+	return shingles
+}
+
+// fnv64 计算字符串的FNV-1a hash
+func fnv64(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// splitMix64 混淆hash值,用于生成MinHash的多个hash函数
+func splitMix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// chunkSignature 计算文本的签名,SimHash返回1个uint64,MinHash返回minHashSize个uint64
+func chunkSignature(algorithm string, text string) []uint64 {
+	shingles := chunkShingles(text)
+	if algorithm == dedupAlgorithmMinHash {
+		signature := make([]uint64, minHashSize)
+		for i := range signature {
+			signature[i] = ^uint64(0)
+		}
+		for _, shingle := range shingles {
+			h := fnv64(shingle)
+			for i := range signature {
+				if v := splitMix64(h ^ splitMix64(uint64(i+1))); v < signature[i] {
+					signature[i] = v
+				}
+			}
+		}
+		return signature
+	}
+
+	var weights [64]int
+	for _, shingle := range shingles {
+		h := fnv64(shingle)
+		for i := 0; i < 64; i++ {
+			if h&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+	var simhash uint64
+	for i := 0; i < 64; i++ {
+		if weights[i] > 0 {
+			simhash |= 1 << uint(i)
+		}
+	}
+	return []uint64{simhash}
+}
+
+// chunkSimilarity 计算两个签名的相似度,范围0-1
+func chunkSimilarity(algorithm string, a []uint64, b []uint64) float64 {
+	if len(a) != len(b) || len(a) < 1 {
+		return 0
+	}
+	if algorithm == dedupAlgorithmMinHash {
+		same := 0
+		for i := range a {
+			if a[i] == b[i] {
+				same++
+			}
+		}
+		return float64(same) / float64(len(a))
+	}
+	return 1 - float64(bits.OnesCount64(a[0]^b[0]))/64
+}
+
+// formatChunkSignature 签名转换为十六进制字符串
+func formatChunkSignature(signature []uint64) string {
+	var sb strings.Builder
+	for _, v := range signature {
+		sb.WriteString(fmt.Sprintf("%016x", v))
+	}
+	return sb.String()
+}
+
+// parseChunkSignature 解析十六进制的签名
+func parseChunkSignature(s string) ([]uint64, error) {
+	if len(s) < 16 || len(s)%16 != 0 {
+		return nil, fmt.Errorf("invalid signature %q", s)
+	}
+	signature := make([]uint64, 0, len(s)/16)
+	for i := 0; i < len(s); i += 16 {
+		v, err := strconv.ParseUint(s[i:i+16], 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		signature = append(signature, v)
+	}
+	return signature, nil
+}
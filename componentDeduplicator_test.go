@@ -0,0 +1,81 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestChunkSignature(t *testing.T) {
+	text := "minRAG是一个轻量级的RAG框架,使用SQLite存储文档和向量,支持全文检索和向量检索,可以单文件部署运行"
+	similar := "minRAG是一个轻量级的RAG框架，使用SQLite存储文档和向量，支持全文检索和向量检索，可以单文件部署运行!"
+	other := "The quick brown fox jumps over the lazy dog, while the weather in the mountains stays cold and windy"
+	for _, algorithm := range []string{dedupAlgorithmSimHash, dedupAlgorithmMinHash} {
+		a := chunkSignature(algorithm, text)
+		b := chunkSignature(algorithm, similar)
+		c := chunkSignature(algorithm, other)
+		if s := chunkSimilarity(algorithm, a, b); s < 0.99 {
+			t.Errorf("%s: punctuation only change similarity %f", algorithm, s)
+		}
+		if s := chunkSimilarity(algorithm, a, c); s > 0.8 {
+			t.Errorf("%s: unrelated text similarity %f", algorithm, s)
+		}
+		parsed, err := parseChunkSignature(formatChunkSignature(a))
+		if err != nil || chunkSimilarity(algorithm, a, parsed) != 1 {
+			t.Errorf("%s: signature round trip failed: %v", algorithm, err)
+		}
+	}
+}
+
+func TestDocumentChunkDeduplicator(t *testing.T) {
+	text := "minRAG是一个轻量级的RAG框架,使用SQLite存储文档和向量,支持全文检索和向量检索"
+	existing := []DocumentChunkSignature{{Id: "old", DocumentID: "doc0", Signature: formatChunkSignature(chunkSignature(dedupAlgorithmSimHash, text))}}
+	chunks := []DocumentChunk{
+		{Id: "c1", DocumentID: "doc1", Markdown: "# 标题"},
+		{Id: "c2", DocumentID: "doc1", ParentID: "c1", Markdown: text},
+		{Id: "c3", DocumentID: "doc1", ParentID: "c2", Markdown: "The quick brown fox jumps over the lazy dog near the river bank"},
+		{Id: "c4", DocumentID: "doc1", ParentID: "c1", Markdown: "The quick brown fox jumps over the lazy dog near the river bank"},
+	}
+
+	component := &DocumentChunkDeduplicator{}
+	if err := component.Initialization(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	kept, duplicates, signatures := component.dedup(append([]DocumentChunk{}, chunks...), existing)
+	if len(kept) != 2 || kept[0].Id != "c1" || kept[1].Id != "c3" {
+		t.Fatalf("unexpected kept chunks %+v", kept)
+	}
+	if kept[0].NextID != "c3" || kept[1].PreID != "c1" || kept[1].ParentID != "c1" {
+		t.Errorf("dropped chunks are not relinked: %+v", kept)
+	}
+	if len(duplicates) != 2 || duplicates[0].DuplicateOf != "old" || duplicates[0].DuplicateOfDocumentID != "doc0" || duplicates[1].DuplicateOf != "c3" {
+		t.Errorf("unexpected duplicates %+v", duplicates)
+	}
+	// 短分块不计算签名
+	if len(signatures) != 1 || signatures[0].Id != "c3" {
+		t.Errorf("unexpected signatures %+v", signatures)
+	}
+
+	component = &DocumentChunkDeduplicator{Action: dedupActionLink}
+	component.Initialization(context.Background(), nil)
+	kept, _, _ = component.dedup(append([]DocumentChunk{}, chunks...), existing)
+	if len(kept) != 4 || kept[1].DuplicateOf != "old" || kept[3].DuplicateOf != "c3" || kept[2].DuplicateOf != "" {
+		t.Errorf("unexpected linked chunks %+v", kept)
+	}
+}
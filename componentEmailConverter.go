@@ -0,0 +1,862 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gitee.com/chunanyong/zorm"
+	"golang.org/x/net/html"
+)
+
+const (
+	// emailThreadIDSeparator 邮件线程文档ID的分隔符,线程文档ID是 源文档ID-thread-线程hash
+	emailThreadIDSeparator = "-thread-"
+	// emailAttachmentDir 邮件附件的保存目录
+	emailAttachmentDir = "upload/email/"
+	// maxEmailMimeDepth MIME嵌套的最大层数
+	maxEmailMimeDepth = 10
+)
+
+var (
+	// emailReplyPrefixRegexp 回复和转发的主题前缀
+	emailReplyPrefixRegexp = regexp.MustCompile(`(?i)^\s*((re|fw|fwd|aw|sv|回复|答复|转发)\s*(\[\d+\])?\s*[:：]\s*)+`)
+	// emailOriginalMessageRegexp 引用原始邮件的分隔行,之后的内容都是引用
+	emailOriginalMessageRegexp = regexp.MustCompile(`(?i)^-{2,}\s*(original message|原始邮件|原始郵件)\s*-{2,}$`)
+	// emailOnWroteRegexp 例如 On Mon, 3 Mar 2025 at 10:00, Tom <tom@example.com> wrote:
+	emailOnWroteRegexp = regexp.MustCompile(`(?i)^on\s.+wrote\s*:$`)
+	// emailWroteCNRegexp 例如 在 2025年3月3日 10:00,张三 写道：
+	emailWroteCNRegexp = regexp.MustCompile(`^在.+写道\s*[:：]$`)
+	// emailOutlookFromRegexp Outlook回复时引用的头信息,下一行是发送时间
+	emailOutlookFromRegexp = regexp.MustCompile(`(?i)^\*?(from|发件人)\s*\*?\s*[:：]`)
+	// emailOutlookSentRegexp Outlook引用头信息的发送时间
+	emailOutlookSentRegexp = regexp.MustCompile(`(?i)^\*?(sent|date|发送时间|日期)\s*\*?\s*[:：]`)
+	// emailMobileSignatureRegexp 手机客户端自动添加的签名
+	emailMobileSignatureRegexp = regexp.MustCompile(`(?i)^(sent from my |get outlook for |发自我的|从我的).{0,40}$`)
+	// emailMboxFromRegexp mboxrd格式转义的From行
+	emailMboxFromRegexp = regexp.MustCompile(`^>+From `)
+)
+
+// EmailConverter 解析 .eml 和 mbox 邮件归档,按照 Message-ID/References 组成邮件线程,每个线程一个文档.
+// 放到indexPipeline的MarkdownConverter之前,例如 {"id":"EmailConverter","downStream":[{"id":"MarkdownConverter"}]},不是邮件的文档直接跳过.
+// 第一个线程写入当前文档,其他线程生成新的文档,ID是 当前文档ID-thread-线程hash,并执行indexPipeline.
+// 去掉引用的回复和签名,附件使用AttachmentConverter组件转换为markdown,邮件头信息保存到文档的元数据
+type EmailConverter struct {
+	FilePath string `json:"filePath,omitempty"`
+	// Exts 邮件文件的后缀,默认 .eml .mbox
+	Exts []string `json:"exts,omitempty"`
+	// AttachmentConverter 转换附件的组件ID,默认 MarkdownConverter
+	AttachmentConverter string `json:"attachmentConverter,omitempty"`
+	// AttachmentExts 需要转换的附件后缀,其他的附件只记录名称
+	AttachmentExts []string `json:"attachmentExts,omitempty"`
+	// MaxAttachmentSize 附件的最大字节数,默认10M
+	MaxAttachmentSize int `json:"maxAttachmentSize,omitempty"`
+	// KeepQuote 保留引用的回复和签名
+	KeepQuote bool `json:"keepQuote,omitempty"`
+}
+
+// emailMessage 解析后的邮件
+type emailMessage struct {
+	MessageID   string
+	InReplyTo   string
+	References  []string
+	Subject     string
+	From        string
+	To          string
+	Cc          string
+	Date        time.Time
+	Body        string
+	Attachments []emailAttachment
+}
+
+// emailAttachment 邮件附件
+type emailAttachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// emailThread 邮件线程,Messages按照时间排序
+type emailThread struct {
+	ID       string
+	Subject  string
+	Messages []*emailMessage
+}
+
+func (component *EmailConverter) Initialization(ctx context.Context, input map[string]any) error {
+	if len(component.Exts) < 1 {
+		component.Exts = []string{".eml", ".mbox"}
+	}
+	if component.AttachmentConverter == "" {
+		component.AttachmentConverter = "MarkdownConverter"
+	}
+	if len(component.AttachmentExts) < 1 {
+		component.AttachmentExts = []string{".pdf", ".doc", ".docx", ".xls", ".xlsx", ".ppt", ".pptx", ".txt", ".md", ".csv", ".html", ".htm"}
+	}
+	if component.MaxAttachmentSize == 0 {
+		component.MaxAttachmentSize = 10 << 20
+	}
+	return nil
+}
+
+func (component *EmailConverter) Run(ctx context.Context, input map[string]any) error {
+	if input["document"] == nil {
+		err := errors.New(funcT("The document of EmailConverter cannot be empty"))
+		input[errorKey] = err
+		return err
+	}
+	document := input["document"].(*Document)
+	filePath := component.FilePath
+	if filePath == "" {
+		filePath = document.FilePath
+	} else {
+		document.FilePath = filePath
+	}
+	// 已经有内容或者不是邮件,交给下游的转换组件
+	if document.Markdown != "" || !component.isEmailFile(filePath) {
+		return nil
+	}
+
+	data, err := os.ReadFile(datadir + filePath)
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+	messages := parseEmailArchive(ctx, data)
+	if len(messages) < 1 {
+		err := errors.New(funcT("No email message found"))
+		input[errorKey] = err
+		return err
+	}
+	threads := buildEmailThreads(messages)
+
+	// 第一个线程写入当前文档
+	err = component.fillThreadDocument(ctx, document, threads[0])
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+	document.FileSize = len(data)
+
+	// 其他线程生成新的文档,和导入zip文档一样在后台依次索引,不阻塞当前文档的流水线
+	threadIDs := make(map[string]bool, len(threads))
+	threadDocuments := make([]*Document, 0, len(threads)-1)
+	for i := 1; i < len(threads); i++ {
+		threadDocument, err := component.newThreadDocument(ctx, document, threads[i])
+		if err != nil {
+			FuncLogError(ctx, err)
+			continue
+		}
+		threadIDs[threadDocument.Id] = true
+		threadDocuments = append(threadDocuments, threadDocument)
+	}
+	// 删除邮件归档更新后已经不存在的线程文档
+	err = deleteStaleEmailThreadDocuments(ctx, document.Id, threadIDs)
+	if err != nil {
+		FuncLogError(ctx, err)
+	}
+	indexDocumentsInBackground(threadDocuments)
+	FuncLogInfo(ctx, fmt.Sprintf("EmailConverter %s: %d messages, %d threads", filePath, len(messages), len(threads)))
+
+	document.Status = 2
+	input["document"] = document
+	return nil
+}
+
+// isEmailFile 根据后缀判断是否是邮件文件
+func (component *EmailConverter) isEmailFile(filePath string) bool {
+	if filePath == "" {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(filePath))
+	for _, e := range component.Exts {
+		if strings.ToLower(e) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// fillThreadDocument 使用邮件线程设置文档的名称,内容和元数据
+func (component *EmailConverter) fillThreadDocument(ctx context.Context, document *Document, thread *emailThread) error {
+	metadataMap := make(map[string]any)
+	if strings.TrimSpace(document.Metadata) != "" {
+		// 保留用户设置的元数据,邮件头信息覆盖同名的key
+		json.Unmarshal([]byte(document.Metadata), &metadataMap)
+	}
+	for key, value := range emailThreadMetadata(thread) {
+		metadataMap[key] = value
+	}
+	metadata, err := json.Marshal(metadataMap)
+	if err != nil {
+		return err
+	}
+	document.Metadata = string(metadata)
+	if thread.Subject != "" {
+		document.Name = thread.Subject
+	}
+	document.Markdown = component.threadMarkdown(ctx, document.Id, thread)
+	return nil
+}
+
+// newThreadDocument 邮件归档中的其他线程,生成新的文档
+func (component *EmailConverter) newThreadDocument(ctx context.Context, document *Document, thread *emailThread) (*Document, error) {
+	now := time.Now().Format("2006-01-02 15:04:05")
+	threadDocument := &Document{
+		Id:                emailThreadDocumentID(document.Id, thread.ID),
+		Name:              document.Name,
+		KnowledgeBaseID:   document.KnowledgeBaseID,
+		KnowledgeBaseName: document.KnowledgeBaseName,
+		FileExt:           document.FileExt,
+		Language:          document.Language,
+		SortNo:            document.SortNo,
+		Status:            2,
+		CreateTime:        now,
+		UpdateTime:        now,
+	}
+	// 已经存在的线程文档,保留创建时间
+	finder := zorm.NewSelectFinder(tableDocumentName, "create_time").Append("WHERE id=?", threadDocument.Id)
+	createTime := ""
+	_, err := zorm.QueryRow(ctx, finder, &createTime)
+	if err != nil {
+		return threadDocument, err
+	}
+	if createTime != "" {
+		threadDocument.CreateTime = createTime
+	}
+	// 源文档的元数据,不包含邮件头信息
+	metadataMap := make(map[string]any)
+	if strings.TrimSpace(document.Metadata) != "" {
+		json.Unmarshal([]byte(document.Metadata), &metadataMap)
+	}
+	metadataMap["email_archive"] = document.Id
+	metadata, _ := json.Marshal(metadataMap)
+	threadDocument.Metadata = string(metadata)
+	err = component.fillThreadDocument(ctx, threadDocument, thread)
+	threadDocument.FileSize = len(threadDocument.Markdown)
+	return threadDocument, err
+}
+
+// threadMarkdown 邮件线程转换为markdown,每封邮件一个二级标题,包含发件人,时间和主题
+func (component *EmailConverter) threadMarkdown(ctx context.Context, documentID string, thread *emailThread) string {
+	var sb strings.Builder
+	subject := thread.Subject
+	if subject == "" {
+		subject = "(no subject)"
+	}
+	sb.WriteString("# " + subject + "\n\n")
+	for _, message := range thread.Messages {
+		sb.WriteString("## " + message.From)
+		if !message.Date.IsZero() {
+			sb.WriteString(" " + message.Date.Format("2006-01-02 15:04:05"))
+		}
+		sb.WriteString("\n\n")
+		sb.WriteString("- Subject: " + message.Subject + "\n")
+		sb.WriteString("- From: " + message.From + "\n")
+		if message.To != "" {
+			sb.WriteString("- To: " + message.To + "\n")
+		}
+		if message.Cc != "" {
+			sb.WriteString("- Cc: " + message.Cc + "\n")
+		}
+		if !message.Date.IsZero() {
+			sb.WriteString("- Date: " + message.Date.Format("2006-01-02 15:04:05") + "\n")
+		}
+		sb.WriteString("\n")
+		body := message.Body
+		if !component.KeepQuote {
+			body = stripEmailReply(body)
+		}
+		if body != "" {
+			sb.WriteString(body + "\n\n")
+		}
+		for _, attachment := range message.Attachments {
+			sb.WriteString("### " + attachment.Name + "\n\n")
+			markdown, err := component.convertAttachment(ctx, documentID, message.MessageID, attachment)
+			if err != nil {
+				FuncLogError(ctx, err)
+			}
+			if markdown != "" {
+				sb.WriteString(strings.TrimSpace(markdown) + "\n\n")
+			}
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// convertAttachment 附件保存到 upload/email/文档ID/ 目录,使用AttachmentConverter组件转换为markdown
+func (component *EmailConverter) convertAttachment(ctx context.Context, documentID string, messageID string, attachment emailAttachment) (string, error) {
+	ext := strings.ToLower(filepath.Ext(attachment.Name))
+	if !hasEmailExt(component.AttachmentExts, ext) || len(attachment.Data) > component.MaxAttachmentSize {
+		return "", nil
+	}
+	converter := baseComponentMap[component.AttachmentConverter]
+	if converter == nil {
+		return "", fmt.Errorf(funcT("The %s component of the pipeline does not exist"), component.AttachmentConverter)
+	}
+	// 使用hash作为文件名,避免附件名称中的路径,重新索引时覆盖同一个文件
+	dir := emailAttachmentDir + documentID + "/"
+	if err := os.MkdirAll(datadir+dir, 0755); err != nil {
+		return "", err
+	}
+	filePath := dir + fmt.Sprintf("%x", fnv64(messageID+"/"+attachment.Name)) + ext
+	if err := os.WriteFile(datadir+filePath, attachment.Data, 0644); err != nil {
+		return "", err
+	}
+	attachmentDocument := &Document{Id: documentID, Name: attachment.Name, FilePath: filePath, FileExt: ext}
+	attachmentInput := map[string]any{"document": attachmentDocument}
+	err := converter.Run(ctx, attachmentInput)
+	if err != nil {
+		return "", err
+	}
+	return attachmentDocument.Markdown, nil
+}
+
+// hasEmailExt 后缀是否在列表中,忽略大小写
+func hasEmailExt(exts []string, ext string) bool {
+	for _, e := range exts {
+		if strings.EqualFold(e, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// emailThreadDocumentID 线程文档的ID
+func emailThreadDocumentID(documentID string, threadID string) string {
+	return documentID + emailThreadIDSeparator + fmt.Sprintf("%x", fnv64(threadID))
+}
+
+// findEmailThreadDocumentIDs 查询邮件归档生成的线程文档ID
+func findEmailThreadDocumentIDs(ctx context.Context, documentID string) ([]string, error) {
+	prefix := documentID + emailThreadIDSeparator
+	finder := zorm.NewSelectFinder(tableDocumentName, "id").Append("WHERE substr(id,1,?)=?", len(prefix), prefix)
+	finder.SelectTotalCount = false
+	ids := make([]string, 0)
+	err := zorm.Query(ctx, finder, &ids, nil)
+	return ids, err
+}
+
+// deleteStaleEmailThreadDocuments 删除不在threadIDs中的线程文档
+func deleteStaleEmailThreadDocuments(ctx context.Context, documentID string, threadIDs map[string]bool) error {
+	ids, err := findEmailThreadDocumentIDs(ctx, documentID)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if threadIDs[id] {
+			continue
+		}
+		err = funcDeleteDocumentById(ctx, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emailThreadMetadata 线程的邮件头信息,作为文档的元数据
+func emailThreadMetadata(thread *emailThread) map[string]any {
+	first := thread.Messages[0]
+	last := thread.Messages[len(thread.Messages)-1]
+	participants := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, message := range thread.Messages {
+		for _, address := range emailAddresses(message.From + "," + message.To + "," + message.Cc) {
+			if seen[address] {
+				continue
+			}
+			seen[address] = true
+			participants = append(participants, address)
+		}
+	}
+	metadata := map[string]any{
+		"source":        "email",
+		"subject":       thread.Subject,
+		"from":          strings.Join(emailAddresses(first.From), ","),
+		"to":            strings.Join(emailAddresses(first.To), ","),
+		"message_id":    thread.ID,
+		"message_count": len(thread.Messages),
+		"participants":  strings.Join(participants, ","),
+	}
+	if cc := strings.Join(emailAddresses(first.Cc), ","); cc != "" {
+		metadata["cc"] = cc
+	}
+	if !first.Date.IsZero() {
+		metadata["date"] = first.Date.Format("2006-01-02 15:04:05")
+	}
+	if !last.Date.IsZero() {
+		metadata["last_date"] = last.Date.Format("2006-01-02 15:04:05")
+	}
+	return metadata
+}
+
+// emailAddresses 解析地址列表,只返回小写的邮箱地址
+func emailAddresses(list string) []string {
+	addresses := make([]string, 0)
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		address, err := mail.ParseAddress(part)
+		if err == nil {
+			addresses = append(addresses, strings.ToLower(address.Address))
+		} else if strings.Contains(part, "@") {
+			addresses = append(addresses, strings.ToLower(strings.Trim(part, "<>\" ")))
+		}
+	}
+	return addresses
+}
+
+// parseEmailArchive 解析 .eml 或者 mbox 内容,解析失败的邮件记录日志后跳过
+func parseEmailArchive(ctx context.Context, data []byte) []*emailMessage {
+	messages := make([]*emailMessage, 0)
+	for _, raw := range splitMbox(data) {
+		message, err := parseEmailMessage(raw)
+		if err != nil {
+			FuncLogError(ctx, err)
+			continue
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// splitMbox 按照 "From " 开头的分隔行拆分mbox,没有分隔行时是单个 .eml 文件
+func splitMbox(data []byte) [][]byte {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	if !bytes.HasPrefix(data, []byte("From ")) {
+		return [][]byte{data}
+	}
+	messages := make([][]byte, 0)
+	var current *bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	previousBlank := true
+	for scanner.Scan() {
+		line := scanner.Text()
+		if previousBlank && strings.HasPrefix(line, "From ") {
+			if current != nil && current.Len() > 0 {
+				messages = append(messages, current.Bytes())
+			}
+			current = &bytes.Buffer{}
+			previousBlank = false
+			continue
+		}
+		// mboxrd 转义的 >From 还原
+		if emailMboxFromRegexp.MatchString(line) {
+			line = line[1:]
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		previousBlank = line == ""
+	}
+	if current != nil && current.Len() > 0 {
+		messages = append(messages, current.Bytes())
+	}
+	return messages
+}
+
+// parseEmailMessage 解析单封邮件的头信息,正文和附件
+func parseEmailMessage(raw []byte) (*emailMessage, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	header := msg.Header
+	message := &emailMessage{
+		MessageID:  emailMessageID(header.Get("Message-Id")),
+		InReplyTo:  emailMessageID(header.Get("In-Reply-To")),
+		References: emailMessageIDs(header.Get("References")),
+		Subject:    strings.TrimSpace(decodeEmailHeader(header.Get("Subject"))),
+		From:       formatEmailAddressList(header.Get("From")),
+		To:         formatEmailAddressList(header.Get("To")),
+		Cc:         formatEmailAddressList(header.Get("Cc")),
+	}
+	if date, err := header.Date(); err == nil {
+		message.Date = date.Local()
+	}
+	// 没有Message-ID,使用发件人,时间和主题生成
+	if message.MessageID == "" {
+		message.MessageID = fmt.Sprintf("%x@minrag", fnv64(message.From+header.Get("Date")+message.Subject))
+	}
+	text, htmlText, attachments := readEmailPart(header, msg.Body, 0)
+	if strings.TrimSpace(text) == "" {
+		text = htmlText
+	}
+	message.Body = strings.TrimSpace(text)
+	message.Attachments = attachments
+	return message, nil
+}
+
+// emailHeader 邮件和MIME分段的头信息
+type emailHeader interface {
+	Get(key string) string
+}
+
+// readEmailPart 递归解析MIME分段,返回纯文本正文,html转换的正文和附件
+func readEmailPart(header emailHeader, body io.Reader, depth int) (string, string, []emailAttachment) {
+	attachments := make([]emailAttachment, 0)
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+		params = map[string]string{}
+	}
+	reader := emailTransferDecoder(header.Get("Content-Transfer-Encoding"), body)
+
+	if strings.HasPrefix(mediaType, "multipart/") && depth < maxEmailMimeDepth {
+		var text, htmlText strings.Builder
+		mr := multipart.NewReader(reader, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err != nil {
+				break
+			}
+			partText, partHTML, partAttachments := readEmailPart(part.Header, part, depth+1)
+			attachments = append(attachments, partAttachments...)
+			// multipart/alternative 是同一内容的不同格式,只保留第一个
+			if mediaType == "multipart/alternative" {
+				if text.Len() == 0 {
+					text.WriteString(partText)
+				}
+				if htmlText.Len() == 0 {
+					htmlText.WriteString(partHTML)
+				}
+				continue
+			}
+			appendEmailText(&text, partText)
+			appendEmailText(&htmlText, partHTML)
+		}
+		return text.String(), htmlText.String(), attachments
+	}
+
+	// 附件
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	name := dispositionParams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+	name = decodeEmailHeader(name)
+	if disposition == "attachment" || (name != "" && !strings.HasPrefix(mediaType, "text/")) || mediaType == "message/rfc822" {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return "", "", attachments
+		}
+		if name == "" {
+			name = "attachment"
+			if mediaType == "message/rfc822" {
+				name = "message.eml"
+			}
+		}
+		attachments = append(attachments, emailAttachment{Name: filepath.Base(name), ContentType: mediaType, Data: data})
+		return "", "", attachments
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", "", attachments
+	}
+	content := decodeEmailCharset(params["charset"], data)
+	switch mediaType {
+	case "text/html":
+		root, err := html.Parse(strings.NewReader(content))
+		if err != nil {
+			return "", "", attachments
+		}
+		return "", htmlInnerText(root), attachments
+	case "text/plain", "text/markdown":
+		return content, "", attachments
+	}
+	return "", "", attachments
+}
+
+// appendEmailText 拼接多个正文分段
+func appendEmailText(sb *strings.Builder, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	if sb.Len() > 0 {
+		sb.WriteString("\n\n")
+	}
+	sb.WriteString(text)
+}
+
+// emailTransferDecoder 根据 Content-Transfer-Encoding 解码
+func emailTransferDecoder(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &emailBase64Cleaner{reader: body})
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
+
+// emailBase64Cleaner 去掉base64内容中的换行和空格
+type emailBase64Cleaner struct {
+	reader io.Reader
+}
+
+func (cleaner *emailBase64Cleaner) Read(p []byte) (int, error) {
+	n, err := cleaner.reader.Read(p)
+	j := 0
+	for i := 0; i < n; i++ {
+		switch p[i] {
+		case '\r', '\n', ' ', '\t':
+			continue
+		}
+		p[j] = p[i]
+		j++
+	}
+	// 全部是空白字符时继续读取,避免返回0,nil
+	if j == 0 && n > 0 && err == nil {
+		return cleaner.Read(p)
+	}
+	return j, err
+}
+
+// decodeEmailCharset 转换为utf-8,只处理常见的单字节编码,其他编码去掉无效的字符
+func decodeEmailCharset(charset string, data []byte) string {
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "iso-8859-1", "latin1", "latin-1", "windows-1252", "cp1252":
+		if !utf8.Valid(data) {
+			runes := make([]rune, len(data))
+			for i, b := range data {
+				runes[i] = rune(b)
+			}
+			return string(runes)
+		}
+	}
+	return strings.ToValidUTF8(string(data), "")
+}
+
+// emailWordDecoder 解码 =?charset?B?...?= 格式的头信息
+var emailWordDecoder = &mime.WordDecoder{CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	return strings.NewReader(decodeEmailCharset(charset, data)), nil
+}}
+
+// decodeEmailHeader 解码头信息,解码失败返回原值
+func decodeEmailHeader(value string) string {
+	decoded, err := emailWordDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// formatEmailAddressList 格式化地址列表为 Name <address>,逗号分隔
+func formatEmailAddressList(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return ""
+	}
+	parser := mail.AddressParser{WordDecoder: emailWordDecoder}
+	addresses, err := parser.ParseList(value)
+	if err != nil {
+		return strings.TrimSpace(decodeEmailHeader(value))
+	}
+	list := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		if address.Name == "" {
+			list = append(list, address.Address)
+			continue
+		}
+		list = append(list, address.Name+" <"+address.Address+">")
+	}
+	return strings.Join(list, ", ")
+}
+
+// emailMessageID 去掉Message-ID的尖括号和空格
+func emailMessageID(value string) string {
+	ids := emailMessageIDs(value)
+	if len(ids) < 1 {
+		return ""
+	}
+	return ids[0]
+}
+
+// emailMessageIDs 解析References中的多个Message-ID
+func emailMessageIDs(value string) []string {
+	ids := make([]string, 0)
+	for _, field := range strings.Fields(strings.ReplaceAll(value, ",", " ")) {
+		id := strings.Trim(field, "<>")
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// normalizeEmailSubject 去掉主题中的 Re: Fwd: 回复: 等前缀
+func normalizeEmailSubject(subject string) string {
+	return strings.TrimSpace(emailReplyPrefixRegexp.ReplaceAllString(subject, ""))
+}
+
+// buildEmailThreads 按照 Message-ID,In-Reply-To,References 组成线程.
+// 没有引用信息的回复邮件,按照去掉前缀后的主题归入已有的线程.线程按照第一封邮件的时间排序
+func buildEmailThreads(messages []*emailMessage) []*emailThread {
+	parent := make(map[string]string)
+	var find func(id string) string
+	find = func(id string) string {
+		p, has := parent[id]
+		if !has || p == id {
+			parent[id] = id
+			return id
+		}
+		root := find(p)
+		parent[id] = root
+		return root
+	}
+	union := func(a string, b string) {
+		ra, rb := find(a), find(b)
+		if ra != rb {
+			parent[rb] = ra
+		}
+	}
+
+	// 去掉重复的邮件
+	unique := make([]*emailMessage, 0, len(messages))
+	seen := make(map[string]bool)
+	for _, message := range messages {
+		if seen[message.MessageID] {
+			continue
+		}
+		seen[message.MessageID] = true
+		unique = append(unique, message)
+	}
+
+	subjectRoots := make(map[string]string)
+	for _, message := range unique {
+		find(message.MessageID)
+		for _, ref := range message.References {
+			union(ref, message.MessageID)
+		}
+		if message.InReplyTo != "" {
+			union(message.InReplyTo, message.MessageID)
+		}
+		// 不是回复的邮件,记录主题对应的线程
+		subject := normalizeEmailSubject(message.Subject)
+		if subject != "" && subject == strings.TrimSpace(message.Subject) {
+			if _, has := subjectRoots[subject]; !has {
+				subjectRoots[subject] = message.MessageID
+			}
+		}
+	}
+	for _, message := range unique {
+		if len(message.References) > 0 || message.InReplyTo != "" {
+			continue
+		}
+		subject := normalizeEmailSubject(message.Subject)
+		if subject == strings.TrimSpace(message.Subject) {
+			continue
+		}
+		if root, has := subjectRoots[subject]; has {
+			union(root, message.MessageID)
+		}
+	}
+
+	threadMap := make(map[string]*emailThread)
+	threads := make([]*emailThread, 0)
+	for _, message := range unique {
+		root := find(message.MessageID)
+		thread, has := threadMap[root]
+		if !has {
+			thread = &emailThread{}
+			threadMap[root] = thread
+			threads = append(threads, thread)
+		}
+		thread.Messages = append(thread.Messages, message)
+	}
+	for _, thread := range threads {
+		sort.SliceStable(thread.Messages, func(i, j int) bool {
+			return thread.Messages[i].Date.Before(thread.Messages[j].Date)
+		})
+		first := thread.Messages[0]
+		thread.ID = first.MessageID
+		thread.Subject = normalizeEmailSubject(first.Subject)
+	}
+	sort.SliceStable(threads, func(i, j int) bool {
+		return threads[i].Messages[0].Date.Before(threads[j].Messages[0].Date)
+	})
+	return threads
+}
+
+// stripEmailReply 去掉引用的回复内容和签名
+func stripEmailReply(body string) string {
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	result := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimSpace(line)
+		// 签名分隔符 "-- "
+		if line == "--" {
+			break
+		}
+		// 引用原始邮件的分隔行,之后都是引用
+		if emailOriginalMessageRegexp.MatchString(trimmed) || emailWroteCNRegexp.MatchString(trimmed) {
+			break
+		}
+		if emailOnWroteRegexp.MatchString(trimmed) {
+			break
+		}
+		// On ... 和 wrote: 被折行
+		if i+1 < len(lines) && strings.HasPrefix(strings.ToLower(trimmed), "on ") && emailOnWroteRegexp.MatchString(trimmed+" "+strings.TrimSpace(lines[i+1])) {
+			break
+		}
+		if i+1 < len(lines) && emailOutlookFromRegexp.MatchString(trimmed) && emailOutlookSentRegexp.MatchString(strings.TrimSpace(lines[i+1])) {
+			break
+		}
+		if strings.HasPrefix(trimmed, ">") || emailMobileSignatureRegexp.MatchString(trimmed) {
+			continue
+		}
+		result = append(result, line)
+	}
+	// 合并多余的空行
+	text := strings.TrimSpace(strings.Join(result, "\n"))
+	for strings.Contains(text, "\n\n\n") {
+		text = strings.ReplaceAll(text, "\n\n\n", "\n\n")
+	}
+	return text
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const testMbox = `From alice@example.com Mon Mar  3 10:00:00 2025
+Message-ID: <m1@example.com>
+From: Alice <alice@example.com>
+To: dev@example.com
+Subject: Release plan
+Date: Mon, 3 Mar 2025 10:00:00 +0800
+
+We release on Friday.
+
+--
+Alice
+Product team
+
+From bob@example.com Mon Mar  3 11:00:00 2025
+Message-ID: <m2@example.com>
+In-Reply-To: <m1@example.com>
+References: <m1@example.com>
+From: Bob <bob@example.com>
+To: dev@example.com
+Cc: carol@example.com
+Subject: Re: Release plan
+Date: Mon, 3 Mar 2025 11:00:00 +0800
+Content-Type: multipart/mixed; boundary="b1"
+
+--b1
+Content-Type: multipart/alternative; boundary="b2"
+
+--b2
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: quoted-printable
+
+Friday works, the build is green.
+>From the CI log everything passed.
+
+On Mon, 3 Mar 2025 at 10:00, Alice <alice@example.com> wrote:
+> We release on Friday.
+--b2
+Content-Type: text/html; charset=utf-8
+
+<p>Friday works, the build is green.</p>
+--b2--
+--b1
+Content-Type: application/octet-stream; name="notes.bin"
+Content-Disposition: attachment; filename="notes.bin"
+Content-Transfer-Encoding: base64
+
+aGVsbG8=
+--b1--
+
+From carol@example.com Tue Mar  4 09:00:00 2025
+Message-ID: <m3@example.com>
+From: =?UTF-8?B?5byg5LiJ?= <zhangsan@example.com>
+To: dev@example.com
+Subject: =?UTF-8?B?5Lya6K6u6YCa55+l?=
+Date: Tue, 4 Mar 2025 09:00:00 +0800
+
+明天下午开会。
+
+发自我的iPhone
+
+From dave@example.com Tue Mar  4 10:00:00 2025
+Message-ID: <m4@example.com>
+From: Dave <dave@example.com>
+Subject: RE: Release plan
+Date: Tue, 4 Mar 2025 10:00:00 +0800
+
+Late reply without references.
+`
+
+func TestBuildEmailThreads(t *testing.T) {
+	messages := parseEmailArchive(context.Background(), []byte(testMbox))
+	if len(messages) != 4 {
+		t.Fatalf("expected 4 messages, got %d", len(messages))
+	}
+	reply := messages[1]
+	if !strings.Contains(reply.Body, "From the CI log") || strings.Contains(reply.Body, "<p>") {
+		t.Errorf("unexpected reply body: %q", reply.Body)
+	}
+	if len(reply.Attachments) != 1 || reply.Attachments[0].Name != "notes.bin" || string(reply.Attachments[0].Data) != "hello" {
+		t.Errorf("unexpected attachments: %+v", reply.Attachments)
+	}
+	if messages[2].Subject != "会议通知" || messages[2].From != "张三 <zhangsan@example.com>" {
+		t.Errorf("encoded headers not decoded: %q %q", messages[2].Subject, messages[2].From)
+	}
+
+	threads := buildEmailThreads(messages)
+	if len(threads) != 2 {
+		t.Fatalf("expected 2 threads, got %d", len(threads))
+	}
+	if threads[0].ID != "m1@example.com" || threads[0].Subject != "Release plan" || len(threads[0].Messages) != 3 {
+		t.Errorf("unexpected first thread: %s %s %d", threads[0].ID, threads[0].Subject, len(threads[0].Messages))
+	}
+	metadata := emailThreadMetadata(threads[0])
+	if metadata["from"] != "alice@example.com" || metadata["message_count"] != 3 || !strings.Contains(metadata["participants"].(string), "carol@example.com") {
+		t.Errorf("unexpected metadata: %v", metadata)
+	}
+}
+
+func TestStripEmailReply(t *testing.T) {
+	tests := map[string]string{
+		"Looks good.\n\nOn Mon, 3 Mar 2025 at 10:00, Alice <alice@example.com>\nwrote:\n> old": "Looks good.",
+		"同意。\n\n在 2025年3月3日 10:00,张三 写道：\n> 原文":                                                "同意。",
+		"OK\n\n-----Original Message-----\nFrom: a\nhello":                                     "OK",
+		"OK\n\nFrom: Alice\nSent: Monday\nSubject: x":                                          "OK",
+		"Thanks\n-- \nBob\nEngineer":                                                           "Thanks",
+		"Line one\n> quoted\nLine two\n\nSent from my iPhone":                                  "Line one\nLine two",
+	}
+	for body, want := range tests {
+		if got := stripEmailReply(body); got != want {
+			t.Errorf("stripEmailReply(%q)=%q want %q", body, got, want)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GraphEntity 大模型抽取的实体或关系,Entity不为空是实体,Entity1和Entity2不为空是关系.
+// 直接生成GraphData结构效果不好,需要先生成GraphEntity,再使用rebuildGraph转换为GraphData
+type GraphEntity struct {
+	Entity           string   `json:"entity,omitempty"`
+	EntityAttributes []string `json:"entity_attributes,omitempty"`
+	Entity1          string   `json:"entity1,omitempty"`
+	Entity2          string   `json:"entity2,omitempty"`
+	Relation         string   `json:"relation,omitempty"`
+}
+
+// GraphData 一个分块抽取的知识图谱
+type GraphData struct {
+	Text     string          `json:"text,omitempty"`
+	Node     []GraphNode     `json:"node,omitempty"`
+	Relation []GraphRelation `json:"relation,omitempty"`
+	// DocumentChunkID 抽取知识图谱的分块ID
+	DocumentChunkID string `json:"documentChunkID,omitempty"`
+}
+
+// GraphNode 知识图谱的节点,Chunks是节点关联的分块ID
+type GraphNode struct {
+	Name       string   `json:"name,omitempty"`
+	Chunks     []string `json:"chunks,omitempty"`
+	Attributes []string `json:"attributes,omitempty"`
+}
+
+// GraphRelation 知识图谱节点之间的关系
+type GraphRelation struct {
+	Node1 string `json:"node1,omitempty"`
+	Node2 string `json:"node2,omitempty"`
+	Type  string `json:"type,omitempty"`
+}
+
+// graphExtractExample 抽取实体和关系的示例,放到提示词中
+var graphExtractExample = `Q: 2023年秋,研究员林远与项目负责人苏雯在北京中关村的实验室研发了时空图神经网络(TGNN),用于城市交通流量预测,成果发表于IEEE TKDE期刊.
+A: {"answer":[{"entity":"时空图神经网络","entity_attributes":["简称TGNN","用于城市交通流量预测"]},{"entity":"林远","entity_attributes":["研究员"]},{"entity":"苏雯","entity_attributes":["项目负责人"]},{"entity":"北京中关村","entity_attributes":["实验室所在地"]},{"entity":"IEEE TKDE期刊"},{"entity":"2023年秋"},{"entity1":"时空图神经网络","entity2":"林远","relation":"作者"},{"entity1":"时空图神经网络","entity2":"苏雯","relation":"作者"},{"entity1":"时空图神经网络","entity2":"北京中关村","relation":"地点"},{"entity1":"时空图神经网络","entity2":"2023年秋","relation":"时间"},{"entity1":"时空图神经网络","entity2":"IEEE TKDE期刊","relation":"发表"}]}`
+
+// GraphExtractor 使用大模型从分块中抽取实体和关系,构建知识库的知识图谱,节点关联抽取的分块.
+// 放到indexPipeline的DocumentSplitter之后,SQLiteVecDocumentStore之前,结果放到input["graphData"],由SQLiteVecDocumentStore保存,重新索引文档时替换文档的知识图谱
+type GraphExtractor struct {
+	OpenAIChatGenerator
+
+	// Prompt 抽取实体和关系的提示词,第一个%s是关系类型,第二个%s是示例
+	Prompt string `json:"prompt,omitempty"`
+	// RelationTypes 限定的关系类型
+	RelationTypes []string `json:"relationTypes,omitempty"`
+	// MinLength 分块内容少于这个长度时不抽取,默认50
+	MinLength int `json:"minLength,omitempty"`
+	// MaxChunkLength 发送给大模型的分块最大长度,默认2000
+	MaxChunkLength int `json:"maxChunkLength,omitempty"`
+}
+
+func (component *GraphExtractor) Initialization(ctx context.Context, input map[string]any) error {
+	component.OpenAIChatGenerator.Initialization(ctx, input)
+	if component.Prompt == "" {
+		component.Prompt = `请基于给定文本,按以下步骤完成信息提取任务,确保逻辑清晰,信息完整准确:
+		1. 提取核心实体:通读文本,按文本叙述顺序提取所有核心实体,并补充文本中明确提及的实体属性.
+		2. 提取关系:仅从指定关系列表中选择关系类型,限定关系类型为: %s.基于已提取的实体,识别文本中真实存在的关系,清晰标注两个关联主体,不添加文本中没有的关系.
+		3. 实体名称使用文本中的原文,使用和文本相同的语言.
+		返回的json格式和示例一致.
+		# Examples
+		%s
+		`
+	}
+	if len(component.RelationTypes) < 1 {
+		component.RelationTypes = []string{"时间", "地点", "关系", "属性", "作者", "事件", "人物", "内容"}
+	}
+	if component.MinLength == 0 {
+		component.MinLength = 50
+	}
+	if component.MaxChunkLength == 0 {
+		component.MaxChunkLength = 2000
+	}
+	return nil
+}
+
+func (component *GraphExtractor) Run(ctx context.Context, input map[string]any) error {
+	if input["documentChunks"] == nil {
+		return nil
+	}
+	documentChunks := input["documentChunks"].([]DocumentChunk)
+	relationTypes, _ := json.Marshal(component.RelationTypes)
+	prompt := fmt.Sprintf(component.Prompt, string(relationTypes), graphExtractExample)
+
+	graphs := make([]GraphData, 0, len(documentChunks))
+	for i := 0; i < len(documentChunks); i++ {
+		documentChunk := documentChunks[i]
+		// 重复的分块不参与检索,不抽取知识图谱
+		if documentChunk.DuplicateOf != "" || runeLength(strings.TrimSpace(documentChunk.Markdown)) < component.MinLength {
+			continue
+		}
+		message := prompt + "\n# Question Q: " + truncateRunes(documentChunk.Markdown, component.MaxChunkLength) + "\nA:"
+		resultJson, err := llmJSONResult(ctx, component.OpenAIChatGenerator, message)
+		if err != nil {
+			// 知识图谱只是补充检索,抽取失败不影响文档索引
+			FuncLogError(ctx, fmt.Errorf("GraphExtractor %s: %w", documentChunk.Id, err))
+			continue
+		}
+		// 直接返回[]数组效果不好,需要包装一下,返回对象
+		result := struct {
+			Answer []GraphEntity `json:"answer,omitempty"`
+		}{}
+		err = json.Unmarshal([]byte(resultJson), &result)
+		if err != nil {
+			FuncLogError(ctx, fmt.Errorf("GraphExtractor %s: %w", documentChunk.Id, err))
+			continue
+		}
+		graph := rebuildGraph(result.Answer, documentChunk.Id)
+		if len(graph.Node) > 0 {
+			graphs = append(graphs, graph)
+		}
+	}
+	input["graphData"] = graphs
+	return nil
+}
+
+// rebuildGraph 整理大模型返回的实体和关系,合并同名实体的属性,关系的实体没有单独返回时补充为节点,去掉重复的关系和自己指向自己的关系
+func rebuildGraph(entities []GraphEntity, documentChunkID string) GraphData {
+	graph := GraphData{DocumentChunkID: documentChunkID}
+	nodeIndex := make(map[string]int)
+	addNode := func(name string, attributes []string) {
+		index, has := nodeIndex[name]
+		if !has {
+			index = len(graph.Node)
+			nodeIndex[name] = index
+			graph.Node = append(graph.Node, GraphNode{Name: name, Chunks: []string{documentChunkID}})
+		}
+		graph.Node[index].Attributes = mergeGraphAttributes(graph.Node[index].Attributes, attributes)
+	}
+	relationSeen := make(map[GraphRelation]bool)
+	for _, entity := range entities {
+		name := strings.TrimSpace(entity.Entity)
+		if name != "" {
+			addNode(name, entity.EntityAttributes)
+			continue
+		}
+		relation := GraphRelation{Node1: strings.TrimSpace(entity.Entity1), Node2: strings.TrimSpace(entity.Entity2), Type: strings.TrimSpace(entity.Relation)}
+		if relation.Node1 == "" || relation.Node2 == "" || relation.Node1 == relation.Node2 || relationSeen[relation] {
+			continue
+		}
+		relationSeen[relation] = true
+		addNode(relation.Node1, nil)
+		addNode(relation.Node2, nil)
+		graph.Relation = append(graph.Relation, relation)
+	}
+	return graph
+}
+
+// mergeGraphAttributes 合并实体属性,去掉空的和重复的属性,保持原有顺序
+func mergeGraphAttributes(attributes []string, others []string) []string {
+	seen := make(map[string]bool, len(attributes)+len(others))
+	merged := make([]string, 0, len(attributes)+len(others))
+	for _, attribute := range append(attributes, others...) {
+		attribute = strings.TrimSpace(attribute)
+		if attribute == "" || seen[attribute] {
+			continue
+		}
+		seen[attribute] = true
+		merged = append(merged, attribute)
+	}
+	return merged
+}
+
+// GraphRetriever 知识图谱检索,使用大模型抽取用户问题中的实体,在知识库的知识图谱中匹配节点,沿着关系走一到两跳,
+// 节点关联的分块追加到input["documentChunks"],经过的关系放到input["graphRelations"],文本格式的关系事实放到input["graphFacts"],可以在PromptBuilder的模板中使用.
+// 放到检索组件之后,重排组件之前,例如 VecEmbeddingRetriever -> GraphRetriever -> DocumentChunkReranker
+type GraphRetriever struct {
+	OpenAIChatGenerator
+
+	// Prompt 抽取问题中实体的提示词
+	Prompt string `json:"prompt,omitempty"`
+	// KnowledgeBaseID 知识库ID,input["knowledgeBaseID"]优先,包含子知识库
+	KnowledgeBaseID string `json:"knowledgeBaseID,omitempty"`
+	// Hops 从匹配的节点走几跳,只支持1和2,默认1
+	Hops int `json:"hops,omitempty"`
+	// TopN 最多追加多少个分块,默认5
+	TopN int `json:"top_n,omitempty"`
+	// MaxRelations 最多经过多少条关系,默认30
+	MaxRelations int `json:"maxRelations,omitempty"`
+}
+
+func (component *GraphRetriever) Initialization(ctx context.Context, input map[string]any) error {
+	component.OpenAIChatGenerator.Initialization(ctx, input)
+	if component.Prompt == "" {
+		component.Prompt = `请基于用户的问题,提取问题中的关键实体,用于查询知识图谱.
+		1. 梳理问题的核心逻辑,围绕核心逻辑提取关键实体,不遗漏核心信息,不添加冗余内容;
+		2. 实体名称使用问题中的原文,按实体和问题主题的关联程度排序.
+		返回的json格式示例:{"entities":["实体1","实体2"]}
+		用户的问题:
+		`
+	}
+	if component.Hops <= 0 {
+		component.Hops = 1
+	}
+	if component.Hops > 2 {
+		component.Hops = 2
+	}
+	if component.TopN == 0 {
+		component.TopN = 5
+	}
+	if component.MaxRelations == 0 {
+		component.MaxRelations = 30
+	}
+	return nil
+}
+
+func (component *GraphRetriever) Run(ctx context.Context, input map[string]any) error {
+	query, _ := input["query"].(string)
+	if query == "" {
+		return nil
+	}
+	knowledgeBaseID, _ := input["knowledgeBaseID"].(string)
+	if knowledgeBaseID == "" {
+		knowledgeBaseID = component.KnowledgeBaseID
+	}
+
+	entities, err := component.extractEntities(ctx, query)
+	if err != nil {
+		// 知识图谱只是补充检索,抽取失败时使用其他检索组件的结果
+		FuncLogError(ctx, fmt.Errorf("GraphRetriever: %w", err))
+		return nil
+	}
+	if len(entities) < 1 {
+		return nil
+	}
+	seeds, err := findKnowledgeGraphNodesByNames(ctx, knowledgeBaseID, entities)
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+	if len(seeds) < 1 {
+		appendTrace(input, "GraphRetriever", map[string]any{"entities": entities})
+		return nil
+	}
+
+	distances, edges, err := walkKnowledgeGraph(ctx, input, knowledgeBaseID, seeds, component.Hops, component.MaxRelations)
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+	relations, err := findKnowledgeGraphRelations(ctx, edges)
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+	nodeChunks, err := findKnowledgeGraphNodeChunks(ctx, distances)
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+	chunkScores := scoreGraphChunks(distances, nodeChunks)
+	graphChunks, err := findGraphDocumentChunks(ctx, input, chunkScores, component.TopN)
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+
+	var documentChunks []DocumentChunk
+	if input["documentChunks"] != nil {
+		documentChunks = input["documentChunks"].([]DocumentChunk)
+	}
+	input["documentChunks"] = appendGraphDocumentChunks(documentChunks, graphChunks)
+	input["graphRelations"] = relations
+	input["graphFacts"] = graphFacts(relations)
+	appendTrace(input, "GraphRetriever", map[string]any{"entities": entities, "nodes": len(distances), "relations": len(relations), "documentChunks": len(graphChunks)})
+	return nil
+}
+
+// extractEntities 请求大模型抽取问题中的实体,去掉空的和重复的实体
+func (component *GraphRetriever) extractEntities(ctx context.Context, query string) ([]string, error) {
+	resultJson, err := llmJSONResult(ctx, component.OpenAIChatGenerator, component.Prompt+query)
+	if err != nil {
+		return nil, err
+	}
+	result := struct {
+		Entities []string `json:"entities,omitempty"`
+	}{}
+	err = json.Unmarshal([]byte(resultJson), &result)
+	if err != nil {
+		return nil, err
+	}
+	return mergeGraphAttributes(nil, result.Entities), nil
+}
+
+// scoreGraphChunks 计算分块的分数,分块关联的每个节点加 1/(跳数+1),匹配的节点是1,一跳的节点是0.5
+func scoreGraphChunks(distances map[string]int, nodeChunks []KnowledgeGraphNodeChunk) map[string]float32 {
+	scores := make(map[string]float32)
+	seen := make(map[string]bool, len(nodeChunks))
+	for _, nodeChunk := range nodeChunks {
+		distance, has := distances[nodeChunk.NodeID]
+		key := nodeChunk.NodeID + "|" + nodeChunk.DocumentChunkID
+		if !has || seen[key] {
+			continue
+		}
+		seen[key] = true
+		scores[nodeChunk.DocumentChunkID] += 1 / float32(distance+1)
+	}
+	return scores
+}
+
+// topGraphChunkIDs 按照分数降序返回前topN个分块ID,分数相同时按照ID排序,保证结果稳定
+func topGraphChunkIDs(scores map[string]float32, topN int) []string {
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] == scores[ids[j]] {
+			return ids[i] < ids[j]
+		}
+		return scores[ids[i]] > scores[ids[j]]
+	})
+	if topN > 0 && len(ids) > topN {
+		ids = ids[:topN]
+	}
+	return ids
+}
+
+// appendGraphDocumentChunks 知识图谱检索的分块追加到已有分块后面,已经检索到的分块不重复追加
+func appendGraphDocumentChunks(documentChunks []DocumentChunk, graphChunks []DocumentChunk) []DocumentChunk {
+	seen := make(map[string]bool, len(documentChunks))
+	for _, documentChunk := range documentChunks {
+		seen[documentChunk.Id] = true
+	}
+	for _, graphChunk := range graphChunks {
+		if seen[graphChunk.Id] {
+			continue
+		}
+		seen[graphChunk.Id] = true
+		documentChunks = append(documentChunks, graphChunk)
+	}
+	return documentChunks
+}
+
+// graphFacts 关系转换为文本,每行一条,例如 "林远 -[作者]-> 时空图神经网络"
+func graphFacts(relations []GraphRelation) string {
+	var sb strings.Builder
+	for _, relation := range relations {
+		sb.WriteString(relation.Node1 + " -[" + relation.Type + "]-> " + relation.Node2 + "\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRebuildGraph(t *testing.T) {
+	entities := []GraphEntity{
+		{Entity: "林远", EntityAttributes: []string{"研究员", " "}},
+		{Entity: "林远", EntityAttributes: []string{"研究员", "核心算法设计者"}},
+		{Entity: "时空图神经网络"},
+		{Entity1: "时空图神经网络", Entity2: "林远", Relation: "作者"},
+		// 重复的关系
+		{Entity1: "时空图神经网络", Entity2: "林远", Relation: "作者"},
+		// 关系的实体没有单独返回
+		{Entity1: "时空图神经网络", Entity2: "IEEE TKDE期刊", Relation: "发表"},
+		// 自己指向自己和缺少实体的关系
+		{Entity1: "林远", Entity2: "林远", Relation: "关系"},
+		{Entity1: "苏雯", Relation: "关系"},
+	}
+	graph := rebuildGraph(entities, "c1")
+	if graph.DocumentChunkID != "c1" {
+		t.Errorf("unexpected documentChunkID %s", graph.DocumentChunkID)
+	}
+	if len(graph.Node) != 3 {
+		t.Fatalf("unexpected nodes %v", graph.Node)
+	}
+	if len(graph.Node[0].Attributes) != 2 || graph.Node[0].Attributes[1] != "核心算法设计者" {
+		t.Errorf("unexpected attributes %v", graph.Node[0].Attributes)
+	}
+	if graph.Node[2].Name != "IEEE TKDE期刊" || len(graph.Node[2].Chunks) != 1 || graph.Node[2].Chunks[0] != "c1" {
+		t.Errorf("unexpected node %v", graph.Node[2])
+	}
+	if len(graph.Relation) != 2 || graph.Relation[1].Type != "发表" {
+		t.Errorf("unexpected relations %v", graph.Relation)
+	}
+	if facts := graphFacts(graph.Relation); facts != "时空图神经网络 -[作者]-> 林远\n时空图神经网络 -[发表]-> IEEE TKDE期刊\n" {
+		t.Errorf("unexpected facts %q", facts)
+	}
+}
+
+func TestScoreGraphChunks(t *testing.T) {
+	// n1是匹配的节点,n2是一跳的节点
+	distances := map[string]int{"n1": 0, "n2": 1}
+	nodeChunks := []KnowledgeGraphNodeChunk{
+		{NodeID: "n1", DocumentChunkID: "c1"},
+		{NodeID: "n2", DocumentChunkID: "c1"},
+		{NodeID: "n2", DocumentChunkID: "c2"},
+		{NodeID: "n2", DocumentChunkID: "c2"},
+		{NodeID: "n3", DocumentChunkID: "c3"},
+	}
+	scores := scoreGraphChunks(distances, nodeChunks)
+	if scores["c1"] != 1.5 || scores["c2"] != 0.5 || len(scores) != 2 {
+		t.Errorf("unexpected scores %v", scores)
+	}
+	ids := topGraphChunkIDs(scores, 1)
+	if len(ids) != 1 || ids[0] != "c1" {
+		t.Errorf("unexpected top chunks %v", ids)
+	}
+
+	documentChunks := appendGraphDocumentChunks([]DocumentChunk{{Id: "c2"}}, []DocumentChunk{{Id: "c1"}, {Id: "c2"}})
+	if len(documentChunks) != 2 || documentChunks[1].Id != "c1" {
+		t.Errorf("unexpected documentChunks %v", documentChunks)
+	}
+}
+
+func TestGraphChunkFilterSQL(t *testing.T) {
+	sql, values, err := graphChunkFilterSQL(map[string]any{})
+	if err != nil || sql != "" || len(values) != 0 {
+		t.Errorf("no filter should return empty sql: %q %v %v", sql, values, err)
+	}
+	// 元数据过滤和时间点都用于过滤关系来源的分块
+	sql, values, err = graphChunkFilterSQL(map[string]any{"metadataFilter": `dept = "hr"`, "asOf": "2025-01-02"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sql, "SELECT id FROM "+tableDocumentChunkName+" WHERE (") || !strings.Contains(sql, documentValidFromSQL+" <= ?") {
+		t.Errorf("unexpected sql %q", sql)
+	}
+	if len(values) != 3 || values[1] != "hr" || values[2] != "2025-01-02 00:00:00" {
+		t.Errorf("unexpected values %v", values)
+	}
+	if _, _, err = graphChunkFilterSQL(map[string]any{"metadataFilter": `x = 1) OR (dept = "secret"`}); err == nil {
+		t.Errorf("unbalanced filter should be rejected")
+	}
+}
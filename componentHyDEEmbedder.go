@@ -0,0 +1,139 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// HyDEEmbedder 假设文档向量化(Hypothetical Document Embeddings),简短或者模糊的问题直接向量化召回效果差,
+// 先让大模型写一段假设的回答,再向量化这段回答,可以和问题的向量取平均值.结果保存到input["embedding"],用于VecEmbeddingRetriever.
+// 问题已经足够长时跳过大模型,直接向量化问题
+type HyDEEmbedder struct {
+	OpenAIChatGenerator
+
+	// Prompt 生成假设回答的提示词
+	Prompt string `json:"prompt,omitempty"`
+	// EmbedderID 向量化组件的ID,例如OpenAITextEmbedder,LKETextEmbedder,默认OpenAITextEmbedder,知识库指定了向量化组件时使用知识库的
+	EmbedderID string `json:"embedderID,omitempty"`
+	// MaxTokens 假设回答的最大token数,默认256
+	MaxTokens int `json:"maxTokens,omitempty"`
+	// MaxQueryLength 问题超过这个长度时跳过,直接向量化问题,默认100
+	MaxQueryLength int `json:"maxQueryLength,omitempty"`
+	// AverageQuery 是否和问题的向量取平均值
+	AverageQuery bool `json:"averageQuery,omitempty"`
+}
+
+func (component *HyDEEmbedder) Initialization(ctx context.Context, input map[string]any) error {
+	component.OpenAIChatGenerator.Initialization(ctx, input)
+	if component.Prompt == "" {
+		component.Prompt = `请写一段简洁的文字回答下面的问题,风格类似知识库文档中的段落,可以包含相关的术语和细节,不需要保证完全正确,只返回这段文字.使用和问题相同的语言.
+		问题:`
+	}
+	if component.EmbedderID == "" {
+		component.EmbedderID = "OpenAITextEmbedder"
+	}
+	if component.MaxTokens == 0 {
+		component.MaxTokens = 256
+	}
+	if component.MaxQueryLength == 0 {
+		component.MaxQueryLength = 100
+	}
+	return nil
+}
+
+func (component *HyDEEmbedder) Run(ctx context.Context, input map[string]any) error {
+	query, _ := input["query"].(string)
+	if query == "" {
+		err := errors.New(funcT("input['query'] cannot be empty"))
+		input[errorKey] = err
+		return err
+	}
+	// 知识库指定了向量化组件,需要和知识库的向量表一致
+	knowledgeBaseID, _ := input["knowledgeBaseID"].(string)
+	embedder, err := findKnowledgeBaseEmbedder(ctx, knowledgeBaseID)
+	if err == nil && embedder == nil {
+		embedder, err = findEmbedder(component.EmbedderID)
+	}
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+
+	passage := ""
+	if runeLength(query) <= component.MaxQueryLength {
+		passage, err = llmChatResult(ctx, component.OpenAIChatGenerator, component.Prompt+query, map[string]any{"max_tokens": component.MaxTokens})
+		if err != nil {
+			// 生成失败时直接向量化问题
+			FuncLogError(ctx, fmt.Errorf("HyDEEmbedder: %w", err))
+		}
+		passage = strings.TrimSpace(passage)
+	}
+
+	var embedding []float64
+	if passage == "" || component.AverageQuery {
+		embedding, _, err = embedder.Embedding(ctx, query)
+		if err != nil {
+			input[errorKey] = err
+			return err
+		}
+	}
+	if passage != "" {
+		passageEmbedding, _, err := embedder.Embedding(ctx, passage)
+		if err != nil {
+			input[errorKey] = err
+			return err
+		}
+		if embedding == nil {
+			embedding = passageEmbedding
+		} else {
+			embedding = averageEmbeddings(embedding, passageEmbedding)
+		}
+		appendTrace(input, "HyDEEmbedder", map[string]any{"query": query, "passage": passage})
+	}
+	input["embedding"] = embedding
+	input["embeddingModel"] = embedder.EmbeddingModel()
+	return nil
+}
+
+// averageEmbeddings 两个向量取平均值,并缩放到两个向量的平均长度,避免L2距离受长度影响
+func averageEmbeddings(a []float64, b []float64) []float64 {
+	if len(a) != len(b) {
+		return a
+	}
+	average := make([]float64, len(a))
+	normA, normB, norm := 0.0, 0.0, 0.0
+	for i := 0; i < len(a); i++ {
+		average[i] = (a[i] + b[i]) / 2
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+		norm += average[i] * average[i]
+	}
+	if norm == 0 {
+		return average
+	}
+	scale := (math.Sqrt(normA) + math.Sqrt(normB)) / 2 / math.Sqrt(norm)
+	for i := 0; i < len(average); i++ {
+		average[i] = average[i] * scale
+	}
+	return average
+}
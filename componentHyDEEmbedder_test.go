@@ -0,0 +1,35 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAverageEmbeddings(t *testing.T) {
+	average := averageEmbeddings([]float64{1, 0}, []float64{0, 1})
+	// 两个单位向量的平均值缩放为单位长度
+	if math.Abs(average[0]-math.Sqrt(0.5)) > 1e-9 || math.Abs(average[1]-math.Sqrt(0.5)) > 1e-9 {
+		t.Errorf("unexpected average: %v", average)
+	}
+	// 长度不一致时返回第一个向量
+	if average := averageEmbeddings([]float64{1, 0}, []float64{1}); len(average) != 2 || average[0] != 1 {
+		t.Errorf("unexpected average: %v", average)
+	}
+}
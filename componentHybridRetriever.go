@@ -0,0 +1,295 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+const (
+	// hybridFusionRRF 倒数排名融合,只使用排名,不受分数尺度影响
+	hybridFusionRRF = "rrf"
+	// hybridFusionMinMax 分数缩放到[0,1]后加权求和
+	hybridFusionMinMax = "minmax"
+	// hybridFusionZScore 分数标准化为z-score后加权求和
+	hybridFusionZScore = "zscore"
+
+	// hybridSourceVector 向量检索的来源名称
+	hybridSourceVector = "vector"
+	// hybridSourceKeyword 全文检索的来源名称
+	hybridSourceKeyword = "keyword"
+)
+
+// HybridRetriever 混合检索,并行执行向量检索和全文检索,分数归一化后加权融合,按照分块ID去重,输出一个排序后的documentChunks.
+// 向量检索的score是距离(越小越好),全文检索的score是BM25分数(越大越好),不能直接比较,所以先按照各自的排名或者分布归一化.
+// 融合后的分数保存到Score,各个检索器的原始分数保存到SourceScores,用于调试
+type HybridRetriever struct {
+	// VecRetrieverID 向量检索组件的ID,默认VecEmbeddingRetriever
+	VecRetrieverID string `json:"vecRetrieverID,omitempty"`
+	// FtsRetrieverID 全文检索组件的ID,默认FtsKeywordRetriever,使用direct模式直接检索
+	FtsRetrieverID string `json:"ftsRetrieverID,omitempty"`
+	// Fusion 融合方式,rrf(默认),minmax,zscore
+	Fusion string `json:"fusion,omitempty"`
+	// VecWeight 向量检索的权重,默认1.0
+	VecWeight float32 `json:"vecWeight,omitempty"`
+	// FtsWeight 全文检索的权重,默认1.0
+	FtsWeight float32 `json:"ftsWeight,omitempty"`
+	// RRFK 倒数排名融合的平滑常数k,默认60
+	RRFK int `json:"rrfK,omitempty"`
+	// TopN 融合后返回多少条,input["topN"]优先,默认5
+	TopN int `json:"top_n,omitempty"`
+	// CandidateN 每个检索器检索多少条候选数据,默认TopN的2倍
+	CandidateN int `json:"candidateN,omitempty"`
+}
+
+// hybridSource 一个检索器的结果
+type hybridSource struct {
+	// Name 来源名称
+	Name string
+	// Weight 权重
+	Weight float32
+	// LowerBetter score越小越好,例如向量距离
+	LowerBetter bool
+	// DocumentChunks 检索结果
+	DocumentChunks []DocumentChunk
+}
+
+func (component *HybridRetriever) Initialization(ctx context.Context, input map[string]any) error {
+	if component.VecRetrieverID == "" {
+		component.VecRetrieverID = "VecEmbeddingRetriever"
+	}
+	if component.FtsRetrieverID == "" {
+		component.FtsRetrieverID = "FtsKeywordRetriever"
+	}
+	if component.Fusion == "" {
+		component.Fusion = hybridFusionRRF
+	}
+	if component.VecWeight == 0 {
+		component.VecWeight = 1.0
+	}
+	if component.FtsWeight == 0 {
+		component.FtsWeight = 1.0
+	}
+	if component.RRFK == 0 {
+		component.RRFK = 60
+	}
+	if component.TopN == 0 {
+		component.TopN = 5
+	}
+	return nil
+}
+
+func (component *HybridRetriever) Run(ctx context.Context, input map[string]any) error {
+	topN := 0
+	if tId, has := input["topN"]; has && tId != nil {
+		topN = tId.(int)
+	}
+	if topN == 0 {
+		topN = component.TopN
+	}
+	candidateN := component.CandidateN
+	if candidateN == 0 {
+		candidateN = topN * 2
+	}
+
+	vecRetriever, vecLowerBetter, err := findRetrieverComponent(component.VecRetrieverID)
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+	ftsRetriever, ftsLowerBetter, err := findRetrieverComponent(component.FtsRetrieverID)
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+
+	sources := []hybridSource{
+		{Name: hybridSourceVector, Weight: component.VecWeight, LowerBetter: vecLowerBetter},
+		{Name: hybridSourceKeyword, Weight: component.FtsWeight, LowerBetter: ftsLowerBetter},
+	}
+	retrievers := []IComponent{vecRetriever, ftsRetriever}
+	errs := make([]error, len(retrievers))
+	var wg sync.WaitGroup
+	for i := 0; i < len(retrievers); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			retrieverInput := copyRetrieverInput(input)
+			retrieverInput["topN"] = candidateN
+			errs[i] = retrievers[i].Run(ctx, retrieverInput)
+			if errs[i] == nil && retrieverInput["documentChunks"] != nil {
+				sources[i].DocumentChunks = retrieverInput["documentChunks"].([]DocumentChunk)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, err = range errs {
+		if err != nil {
+			input[errorKey] = err
+			return err
+		}
+	}
+
+	documentChunks := fuseDocumentChunks(sources, component.Fusion, component.RRFK, topN)
+	oldDcs, has := input["documentChunks"]
+	if has && oldDcs != nil {
+		oldDocumentChunks := oldDcs.([]DocumentChunk)
+		documentChunks = append(oldDocumentChunks, documentChunks...)
+	}
+	input["documentChunks"] = documentChunks
+	return nil
+}
+
+// findRetrieverComponent 查询检索组件,全文检索组件默认把检索函数提供给大模型,转换为direct模式直接检索.
+// 返回检索结果的score是否越小越好,向量检索的score是距离
+func findRetrieverComponent(retrieverID string) (IComponent, bool, error) {
+	retriever := baseComponentMap[retrieverID]
+	if retriever == nil {
+		return nil, false, fmt.Errorf(funcT("The %s component of the pipeline does not exist"), retrieverID)
+	}
+	switch component := retriever.(type) {
+	case *FtsKeywordRetriever:
+		directFts := *component
+		directFts.Mode = ftsRetrieverModeDirect
+		return &directFts, false, nil
+	case *VecEmbeddingRetriever:
+		return retriever, true, nil
+	}
+	return retriever, false, nil
+}
+
+// copyRetrieverInput 复制input用于单独运行检索器,避免并发写入和结果互相追加
+func copyRetrieverInput(input map[string]any) map[string]any {
+	retrieverInput := make(map[string]any, len(input))
+	for k, v := range input {
+		retrieverInput[k] = v
+	}
+	delete(retrieverInput, "documentChunks")
+	delete(retrieverInput, errorKey)
+	return retrieverInput
+}
+
+// fuseDocumentChunks 融合多个检索器的结果,按照分块ID去重,融合分数降序,返回前topN条
+func fuseDocumentChunks(sources []hybridSource, fusion string, rrfK int, topN int) []DocumentChunk {
+	fusedMap := make(map[string]*DocumentChunk)
+	ids := make([]string, 0)
+	for _, source := range sources {
+		documentChunks := make([]DocumentChunk, len(source.DocumentChunks))
+		copy(documentChunks, source.DocumentChunks)
+		// 按照从好到差排序,用于计算排名
+		sort.SliceStable(documentChunks, func(i, j int) bool {
+			if source.LowerBetter {
+				return documentChunks[i].Score < documentChunks[j].Score
+			}
+			return documentChunks[i].Score > documentChunks[j].Score
+		})
+		normalized := normalizeSourceScores(documentChunks, source.LowerBetter, fusion, rrfK)
+		for i := 0; i < len(documentChunks); i++ {
+			documentChunk := documentChunks[i]
+			sourceScore := documentChunk.Score
+			fused, has := fusedMap[documentChunk.Id]
+			if !has {
+				fused = &documentChunk
+				fused.Score = 0
+				fused.SourceScores = make(map[string]float32)
+				fusedMap[documentChunk.Id] = fused
+				ids = append(ids, documentChunk.Id)
+			} else if fused.Markdown == "" {
+				fused.Markdown = documentChunk.Markdown
+			}
+			// 同一个来源的重复分块只计算一次
+			if _, has := fused.SourceScores[source.Name]; has {
+				continue
+			}
+			fused.SourceScores[source.Name] = sourceScore
+			fused.Score += source.Weight * normalized[i]
+		}
+	}
+
+	result := make([]DocumentChunk, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, *fusedMap[id])
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Score > result[j].Score
+	})
+	if len(result) > topN {
+		result = result[:topN]
+	}
+	return result
+}
+
+// normalizeSourceScores 计算一个来源已排序结果的归一化分数,越大越好
+func normalizeSourceScores(documentChunks []DocumentChunk, lowerBetter bool, fusion string, rrfK int) []float32 {
+	normalized := make([]float32, len(documentChunks))
+	if len(documentChunks) < 1 {
+		return normalized
+	}
+	if fusion != hybridFusionMinMax && fusion != hybridFusionZScore {
+		for i := 0; i < len(documentChunks); i++ {
+			normalized[i] = 1.0 / float32(rrfK+i+1)
+		}
+		return normalized
+	}
+
+	// 统一转换为越大越好
+	values := make([]float64, len(documentChunks))
+	for i := 0; i < len(documentChunks); i++ {
+		values[i] = float64(documentChunks[i].Score)
+		if lowerBetter {
+			values[i] = -values[i]
+		}
+	}
+	if fusion == hybridFusionMinMax {
+		minValue, maxValue := values[0], values[0]
+		for _, v := range values {
+			minValue = math.Min(minValue, v)
+			maxValue = math.Max(maxValue, v)
+		}
+		for i, v := range values {
+			if maxValue == minValue {
+				normalized[i] = 1.0
+				continue
+			}
+			normalized[i] = float32((v - minValue) / (maxValue - minValue))
+		}
+		return normalized
+	}
+
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean = mean / float64(len(values))
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	std := math.Sqrt(variance / float64(len(values)))
+	for i, v := range values {
+		if std == 0 {
+			continue
+		}
+		normalized[i] = float32((v - mean) / std)
+	}
+	return normalized
+}
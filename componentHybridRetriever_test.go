@@ -0,0 +1,60 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestFuseDocumentChunks(t *testing.T) {
+	sources := []hybridSource{
+		// 向量检索的score是距离,越小越好
+		{Name: hybridSourceVector, Weight: 1, LowerBetter: true, DocumentChunks: []DocumentChunk{{Id: "c3", Score: 0.9}, {Id: "c1", Score: 0.2}, {Id: "c2", Score: 0.5}}},
+		// 全文检索的score是BM25分数,越大越好
+		{Name: hybridSourceKeyword, Weight: 1, DocumentChunks: []DocumentChunk{{Id: "c2", Score: 8, Markdown: "c2"}, {Id: "c4", Score: 3}}},
+	}
+	for _, fusion := range []string{hybridFusionRRF, hybridFusionMinMax, hybridFusionZScore} {
+		result := fuseDocumentChunks(sources, fusion, 60, 3)
+		if len(result) != 3 {
+			t.Fatalf("%s: unexpected length %d", fusion, len(result))
+		}
+		// c2 两个检索器都命中,去重后保留两个来源的原始分数
+		index := -1
+		for i := 0; i < len(result); i++ {
+			if result[i].Id == "c2" {
+				index = i
+			}
+		}
+		if index < 0 || result[index].Markdown != "c2" {
+			t.Fatalf("%s: c2 not found %v", fusion, result)
+		}
+		if result[index].SourceScores[hybridSourceVector] != 0.5 || result[index].SourceScores[hybridSourceKeyword] != 8 {
+			t.Errorf("%s: unexpected source scores %v", fusion, result[index].SourceScores)
+		}
+		// 排名融合和min-max时,c2排在第一
+		if fusion != hybridFusionZScore && index != 0 {
+			t.Errorf("%s: unexpected first chunk %v", fusion, result[0])
+		}
+	}
+	// 提高全文检索的权重
+	sources[1].Weight = 10
+	result := fuseDocumentChunks(sources, hybridFusionRRF, 60, 2)
+	if result[1].Id != "c4" {
+		t.Errorf("unexpected second chunk %v", result[1])
+	}
+}
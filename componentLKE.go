@@ -117,59 +117,19 @@ func (component *LKEDocumentEmbedder) Initialization(ctx context.Context, input
 	return nil
 }
 func (component *LKEDocumentEmbedder) Run(ctx context.Context, input map[string]any) error {
-	if input["documentChunks"] == nil {
-		return errors.New(funcT("input['documentChunks'] cannot be empty"))
-	}
-	documentChunks := input["documentChunks"].([]DocumentChunk)
-	vecDocumentChunks := make([]VecDocumentChunk, 0)
-	for i := 0; i < len(documentChunks); i++ {
-		documentChunk := documentChunks[i]
-
-		bodyMap := make(map[string]any, 0)
-		bodyMap["Inputs"] = []string{documentChunk.Markdown}
-		bodyMap["Model"] = component.Model
-		bodyByte, err := httpPostLKEBody(component.client, component.SecretId, component.SecretKey, component.Host, component.Algorithm, component.Service, component.Version, component.Action, component.Region, bodyMap)
-		if err != nil {
-			input[errorKey] = err
-			return err
-		}
-
-		rs := struct {
-			Response struct {
-				Data []struct {
-					Embedding []float64 `json:"Embedding,omitempty"`
-				} `json:"Data,omitempty"`
-			} `json:"Response,omitempty"`
-		}{}
-		err = json.Unmarshal(bodyByte, &rs)
-		if err != nil {
-			input[errorKey] = err
-			return err
-		}
-		if len(rs.Response.Data) < 1 {
-			err := errors.New("httpPostLKEBody data is empty")
-			input[errorKey] = err
-			return err
-		}
-		embedding, err := vecSerializeFloat64(rs.Response.Data[0].Embedding)
-		if err != nil {
-			input[errorKey] = err
-			return err
-		}
-		documentChunks[i].Embedding = embedding
-
-		vecdc := VecDocumentChunk{}
-		vecdc.Id = documentChunks[i].Id
-		vecdc.DocumentID = documentChunks[i].DocumentID
-		vecdc.KnowledgeBaseID = documentChunks[i].KnowledgeBaseID
-		vecdc.SortNo = documentChunks[i].SortNo
-		vecdc.Status = 2
-		vecdc.Embedding = embedding
-		vecDocumentChunks = append(vecDocumentChunks, vecdc)
-	}
-	input["documentChunks"] = documentChunks
-	input["vecDocumentChunks"] = vecDocumentChunks
-	return nil
+	return runDocumentEmbedder(ctx, input, component)
+}
+
+// EmbeddingModel 向量模型名称
+func (component *LKEDocumentEmbedder) EmbeddingModel() string {
+	return component.Model
+}
+
+// Embedding 向量化文本,优先使用向量缓存
+func (component *LKEDocumentEmbedder) Embedding(ctx context.Context, text string) ([]float64, []byte, error) {
+	return embeddingWithCache(ctx, component.Model, "https://"+component.Host, text, func() ([]float64, error) {
+		return lkeEmbedding(component.client, component.SecretId, component.SecretKey, component.Host, component.Algorithm, component.Service, component.Version, component.Action, component.Region, component.Model, text)
+	})
 }
 
 // LKETextEmbedder  LKE向量化字符串文本
@@ -233,14 +193,36 @@ func (component *LKETextEmbedder) Run(ctx context.Context, input map[string]any)
 		return errors.New(funcT("input['query'] cannot be empty"))
 	}
 	query := input["query"].(string)
-	bodyMap := make(map[string]any, 0)
-	bodyMap["Inputs"] = []string{query}
-	bodyMap["Model"] = component.Model
-	bodyByte, err := httpPostLKEBody(component.client, component.SecretId, component.SecretKey, component.Host, component.Algorithm, component.Service, component.Version, component.Action, component.Region, bodyMap)
+	embedding, _, err := component.Embedding(ctx, query)
 	if err != nil {
 		input[errorKey] = err
 		return err
 	}
+	input["embedding"] = embedding
+	return nil
+}
+
+// EmbeddingModel 向量模型名称
+func (component *LKETextEmbedder) EmbeddingModel() string {
+	return component.Model
+}
+
+// Embedding 向量化文本,优先使用向量缓存
+func (component *LKETextEmbedder) Embedding(ctx context.Context, text string) ([]float64, []byte, error) {
+	return embeddingWithCache(ctx, component.Model, "https://"+component.Host, text, func() ([]float64, error) {
+		return lkeEmbedding(component.client, component.SecretId, component.SecretKey, component.Host, component.Algorithm, component.Service, component.Version, component.Action, component.Region, component.Model, text)
+	})
+}
+
+// lkeEmbedding 调用LKE的GetEmbedding接口,向量化文本
+func lkeEmbedding(client *http.Client, secretId, secretKey, host, algorithm, service, version, action, region, model, text string) ([]float64, error) {
+	bodyMap := make(map[string]any, 0)
+	bodyMap["Inputs"] = []string{text}
+	bodyMap["Model"] = model
+	bodyByte, err := httpPostLKEBody(client, secretId, secretKey, host, algorithm, service, version, action, region, bodyMap)
+	if err != nil {
+		return nil, err
+	}
 
 	rs := struct {
 		Response struct {
@@ -251,16 +233,12 @@ func (component *LKETextEmbedder) Run(ctx context.Context, input map[string]any)
 	}{}
 	err = json.Unmarshal(bodyByte, &rs)
 	if err != nil {
-		input[errorKey] = err
-		return err
+		return nil, err
 	}
 	if len(rs.Response.Data) < 1 {
-		err := errors.New("httpPostLKEBody data is empty")
-		input[errorKey] = err
-		return err
+		return nil, errors.New("httpPostLKEBody data is empty")
 	}
-	input["embedding"] = rs.Response.Data[0].Embedding
-	return nil
+	return rs.Response.Data[0].Embedding, nil
 }
 
 // LKEDocumentChunkReranker  LKE对DocumentChunks进行重新排序. https://cloud.tencent.com/document/product/1772/115339
@@ -0,0 +1,282 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"gitee.com/chunanyong/zorm"
+)
+
+// MMRReranker 本地的最大边际相关性(Maximal Marginal Relevance)重排序,不需要请求外部的Reranker服务.
+// 使用vec表中已经保存的分块向量和问题的向量,每次选择和问题相关,并且和已选分块差异最大的分块,避免topN都是近似重复的内容.
+// 没有向量的分块(例如重复分块,历史版本)按照原有顺序排在后面.子知识库使用不同的向量化组件时,分块只和同一个向量空间的问题向量和分块比较
+type MMRReranker struct {
+	// TopN 返回多少条,input["topN"]优先,默认5
+	TopN int `json:"top_n,omitempty"`
+	// Lambda 相关性的权重,范围(0,1],越大越注重相关性,越小越注重多样性,默认0.5
+	Lambda float32 `json:"lambda,omitempty"`
+	// EmbedderID 默认向量表的向量化组件ID,input["embedding"]的向量模型不一致时用于向量化问题,默认OpenAITextEmbedder,知识库指定了向量化组件时使用知识库的
+	EmbedderID string `json:"embedderID,omitempty"`
+}
+
+func (component *MMRReranker) Initialization(ctx context.Context, input map[string]any) error {
+	if component.TopN == 0 {
+		component.TopN = 5
+	}
+	if component.Lambda <= 0 || component.Lambda > 1 {
+		component.Lambda = 0.5
+	}
+	if component.EmbedderID == "" {
+		component.EmbedderID = "OpenAITextEmbedder"
+	}
+	return nil
+}
+
+func (component *MMRReranker) Run(ctx context.Context, input map[string]any) error {
+	if input["documentChunks"] == nil {
+		err := errors.New(funcT("input['documentChunks'] cannot be empty"))
+		input[errorKey] = err
+		return err
+	}
+	documentChunks := input["documentChunks"].([]DocumentChunk)
+	if len(documentChunks) < 1 {
+		return nil
+	}
+	topN := 0
+	if tId, has := input["topN"]; has && tId != nil {
+		topN = tId.(int)
+	}
+	if topN == 0 {
+		topN = component.TopN
+	}
+	knowledgeBaseID, _ := input["knowledgeBaseID"].(string)
+
+	// 检索结果可能包含子知识库的分块,子知识库可以使用不同的向量化组件.
+	// 按照分块的知识库分组,每组查询对应的向量表,只和同一个向量化组件的问题向量比较
+	spaceMap := make(map[string]mmrVectorSpace)
+	spaceChunks := make(map[mmrVectorSpace][]DocumentChunk)
+	for _, documentChunk := range documentChunks {
+		chunkKnowledgeBaseID := documentChunk.KnowledgeBaseID
+		if chunkKnowledgeBaseID == "" {
+			chunkKnowledgeBaseID = knowledgeBaseID
+		}
+		space, has := spaceMap[chunkKnowledgeBaseID]
+		if !has {
+			var err error
+			space, err = component.findVectorSpace(ctx, chunkKnowledgeBaseID)
+			if err != nil {
+				input[errorKey] = err
+				return err
+			}
+			spaceMap[chunkKnowledgeBaseID] = space
+		}
+		spaceChunks[space] = append(spaceChunks[space], documentChunk)
+	}
+	vectorMap := make(map[string][]float64, len(documentChunks))
+	relevanceMap := make(map[string]float64, len(documentChunks))
+	chunkSpaceMap := make(map[string]string, len(documentChunks))
+	for space, chunks := range spaceChunks {
+		embedding, err := component.queryEmbedding(ctx, input, space)
+		if err != nil {
+			input[errorKey] = err
+			return err
+		}
+		spaceVectorMap, err := findDocumentChunkVectors(ctx, space, chunks)
+		if err != nil {
+			input[errorKey] = err
+			return err
+		}
+		for id, vector := range spaceVectorMap {
+			if len(vector) != len(embedding) {
+				continue
+			}
+			vectorMap[id] = vector
+			relevanceMap[id] = cosineSimilarity(embedding, vector)
+			chunkSpaceMap[id] = space.TableName + "/" + space.EmbeddingModel
+		}
+	}
+
+	// 有向量的分块参与MMR,没有向量的分块按照原有顺序排在后面
+	candidates := make([]DocumentChunk, 0, len(documentChunks))
+	vectors := make([][]float64, 0, len(documentChunks))
+	relevances := make([]float64, 0, len(documentChunks))
+	spaces := make([]string, 0, len(documentChunks))
+	others := make([]DocumentChunk, 0)
+	for _, documentChunk := range documentChunks {
+		vector, has := vectorMap[documentChunk.Id]
+		if !has {
+			others = append(others, documentChunk)
+			continue
+		}
+		candidates = append(candidates, documentChunk)
+		vectors = append(vectors, vector)
+		relevances = append(relevances, relevanceMap[documentChunk.Id])
+		spaces = append(spaces, chunkSpaceMap[documentChunk.Id])
+	}
+
+	selected, relevances := mmrSelect(relevances, vectors, spaces, float64(component.Lambda), topN)
+	rerankerDCS := make([]DocumentChunk, 0, topN)
+	for i, index := range selected {
+		documentChunk := candidates[index]
+		documentChunk.Score = float32(relevances[i])
+		rerankerDCS = append(rerankerDCS, documentChunk)
+	}
+	for i := 0; i < len(others) && len(rerankerDCS) < topN; i++ {
+		rerankerDCS = append(rerankerDCS, others[i])
+	}
+	input["documentChunks"] = rerankerDCS
+	return nil
+}
+
+// mmrVectorSpace 分块向量所在的向量表,向量模型和向量化组件,不同向量空间的向量不能比较
+type mmrVectorSpace struct {
+	TableName      string
+	EmbeddingModel string
+	EmbedderID     string
+}
+
+// findVectorSpace 知识库的向量空间,知识库指定了向量化组件时使用对应的向量表和模型,否则使用默认的向量表和组件的EmbedderID
+func (component *MMRReranker) findVectorSpace(ctx context.Context, knowledgeBaseID string) (mmrVectorSpace, error) {
+	space := mmrVectorSpace{TableName: tableVecDocumentChunkName, EmbedderID: component.EmbedderID}
+	if knowledgeBaseID == "" {
+		return space, nil
+	}
+	knowledgeBase, err := findKnowledgeBaseById(ctx, knowledgeBaseID)
+	if err != nil {
+		return space, err
+	}
+	if knowledgeBase.EmbedderID != "" {
+		space = mmrVectorSpace{TableName: vecTableName(knowledgeBase.EmbeddingDimension), EmbeddingModel: knowledgeBase.EmbeddingModel, EmbedderID: knowledgeBase.EmbedderID}
+	}
+	return space, nil
+}
+
+// queryEmbedding 向量空间对应的问题向量,input["embedding"]和向量空间使用同一个向量模型时直接使用,否则使用向量空间的组件向量化问题
+func (component *MMRReranker) queryEmbedding(ctx context.Context, input map[string]any, space mmrVectorSpace) ([]float64, error) {
+	embedding, _ := input["embedding"].([]float64)
+	embeddingModel, _ := input["embeddingModel"].(string)
+	if len(embedding) > 0 && embeddingModel == space.EmbeddingModel {
+		return embedding, nil
+	}
+	embedder, err := findEmbedder(space.EmbedderID)
+	if err != nil {
+		return nil, err
+	}
+	if len(embedding) > 0 && embeddingModel == embedder.EmbeddingModel() {
+		return embedding, nil
+	}
+	query, _ := input["query"].(string)
+	if query == "" {
+		return nil, errors.New(funcT("input['query'] cannot be empty"))
+	}
+	embedding, _, err = embedder.Embedding(ctx, query)
+	return embedding, err
+}
+
+// findDocumentChunkVectors 查询分块保存在向量空间对应的vec表中的向量
+func findDocumentChunkVectors(ctx context.Context, space mmrVectorSpace, documentChunks []DocumentChunk) (map[string][]float64, error) {
+	ids := make([]string, 0, len(documentChunks))
+	for _, documentChunk := range documentChunks {
+		ids = append(ids, documentChunk.Id)
+	}
+	finder := zorm.NewSelectFinder(space.TableName, "id,embedding").Append("WHERE id IN (?)", ids)
+	if space.EmbeddingModel != "" {
+		finder.Append(" and embedding_model=?", space.EmbeddingModel)
+	}
+	finder.SelectTotalCount = false
+	vecDocumentChunks := make([]VecDocumentChunk, 0)
+	err := zorm.Query(ctx, finder, &vecDocumentChunks, nil)
+	if err != nil {
+		return nil, err
+	}
+	vectorMap := make(map[string][]float64, len(vecDocumentChunks))
+	for _, vecDocumentChunk := range vecDocumentChunks {
+		vector, err := vecDeserializeFloat64(vecDocumentChunk.Embedding)
+		if err != nil {
+			return nil, err
+		}
+		vectorMap[vecDocumentChunk.Id] = vector
+	}
+	return vectorMap, nil
+}
+
+// mmrSelect 最大边际相关性选择,relevances是每个候选和问题的余弦相似度,返回选中的索引和对应的相关性,按照选择顺序.
+// spaces是每个候选所属的向量空间,不同向量空间的向量不能比较,相似度按照0计算;为nil时都属于同一个向量空间
+func mmrSelect(relevances []float64, vectors [][]float64, spaces []string, lambda float64, topN int) ([]int, []float64) {
+	if topN > len(vectors) {
+		topN = len(vectors)
+	}
+	selected := make([]int, 0, topN)
+	selectedRelevances := make([]float64, 0, topN)
+	// maxSimilarities 每个候选和已选分块的最大相似度
+	maxSimilarities := make([]float64, len(vectors))
+	used := make([]bool, len(vectors))
+	for len(selected) < topN {
+		best := -1
+		bestScore := math.Inf(-1)
+		for i := 0; i < len(vectors); i++ {
+			if used[i] {
+				continue
+			}
+			score := lambda * relevances[i]
+			if len(selected) > 0 {
+				score -= (1 - lambda) * maxSimilarities[i]
+			}
+			if score > bestScore {
+				best = i
+				bestScore = score
+			}
+		}
+		used[best] = true
+		selected = append(selected, best)
+		selectedRelevances = append(selectedRelevances, relevances[best])
+		for i := 0; i < len(vectors); i++ {
+			if used[i] {
+				continue
+			}
+			similarity := 0.0
+			if spaces == nil || spaces[i] == spaces[best] {
+				similarity = cosineSimilarity(vectors[i], vectors[best])
+			}
+			if len(selected) == 1 || similarity > maxSimilarities[i] {
+				maxSimilarities[i] = similarity
+			}
+		}
+	}
+	return selected, selectedRelevances
+}
+
+// cosineSimilarity 两个向量的余弦相似度,长度不一致或者为零向量时返回0
+func cosineSimilarity(a []float64, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	dot, normA, normB := 0.0, 0.0, 0.0
+	for i := 0; i < len(a); i++ {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
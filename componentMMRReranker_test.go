@@ -0,0 +1,57 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestMMRSelect(t *testing.T) {
+	query := []float64{1, 0}
+	vectors := [][]float64{
+		{0.9, 0.1},  // 最相关
+		{0.89, 0.1}, // 和第一个几乎重复
+		{0.8, -0.6}, // 相关性低一些,但是不同
+	}
+	relevances := make([]float64, len(vectors))
+	for i, vector := range vectors {
+		relevances[i] = cosineSimilarity(query, vector)
+	}
+	// 只看相关性
+	selected, _ := mmrSelect(relevances, vectors, nil, 1, 2)
+	if len(selected) != 2 || selected[0] != 0 || selected[1] != 1 {
+		t.Errorf("unexpected selected: %v", selected)
+	}
+	// 兼顾多样性,跳过近似重复的分块
+	selected, selectedRelevances := mmrSelect(relevances, vectors, nil, 0.5, 2)
+	if len(selected) != 2 || selected[0] != 0 || selected[1] != 2 {
+		t.Errorf("unexpected selected: %v", selected)
+	}
+	if selectedRelevances[1] != cosineSimilarity(query, vectors[2]) {
+		t.Errorf("unexpected relevances: %v", selectedRelevances)
+	}
+	// topN大于候选数量
+	if selected, _ := mmrSelect(relevances, vectors, nil, 0.5, 10); len(selected) != 3 {
+		t.Errorf("unexpected selected: %v", selected)
+	}
+	// 第二个分块在子知识库的另一个向量空间,不和第一个分块比较相似度,不会因为向量相近被跳过
+	selected, _ = mmrSelect(relevances, vectors, []string{"vec_document_chunk/", "vec_document_chunk_1024/bge-m3", "vec_document_chunk/"}, 0.5, 2)
+	if len(selected) != 2 || selected[0] != 0 || selected[1] != 1 {
+		t.Errorf("vectors in different spaces should not be compared: %v", selected)
+	}
+}
@@ -26,12 +26,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"reflect"
 	"runtime/debug"
 	"slices"
 	"sort"
-	"strconv"
 	"strings"
 	"text/template"
 	"time"
@@ -48,34 +48,47 @@ const (
 	nextComponentKey string = "__next__"
 	endKey           string = "__end__"
 	ifEmptyStop      string = "__ifEmptyStop__"
+	traceKey         string = "__trace__"
 )
 
 // componentTypeMap 组件类型对照,key是类型名称,value是组件实例
 var componentTypeMap = map[string]IComponent{
-	"Pipeline":                     &Pipeline{},
-	"ChatMessageLogStore":          &ChatMessageLogStore{},
-	"OpenAIChatGenerator":          &OpenAIChatGenerator{},
-	"OpenAIChatMemory":             &OpenAIChatMemory{},
-	"PromptBuilder":                &PromptBuilder{},
-	"DocumentChunkReranker":        &DocumentChunkReranker{},
-	"QianFanDocumentChunkReranker": &QianFanDocumentChunkReranker{},
-	"BaiLianDocumentChunkReranker": &BaiLianDocumentChunkReranker{},
-	"LKEDocumentChunkReranker":     &LKEDocumentChunkReranker{},
-	"MarkdownRetriever":            &MarkdownRetriever{},
-	"FtsKeywordRetriever":          &FtsKeywordRetriever{},
-	"VecEmbeddingRetriever":        &VecEmbeddingRetriever{},
-	"OpenAITextEmbedder":           &OpenAITextEmbedder{},
-	"LKETextEmbedder":              &LKETextEmbedder{},
-	"WebSearch":                    &WebSearch{},
-	"SQLiteVecDocumentStore":       &SQLiteVecDocumentStore{},
-	"OpenAIDocumentEmbedder":       &OpenAIDocumentEmbedder{},
-	"LKEDocumentEmbedder":          &LKEDocumentEmbedder{},
-	"MarkdownIndex":                &MarkdownIndex{},
-	"DocumentSplitter":             &DocumentSplitter{},
-	"HtmlCleaner":                  &HtmlCleaner{},
-	"WebScraper":                   &WebScraper{},
-	"MarkdownConverter":            &MarkdownConverter{},
-	"TikaConverter":                &TikaConverter{},
+	"Pipeline":                       &Pipeline{},
+	"ChatMessageLogStore":            &ChatMessageLogStore{},
+	"OpenAIChatGenerator":            &OpenAIChatGenerator{},
+	"OpenAIChatMemory":               &OpenAIChatMemory{},
+	"PromptBuilder":                  &PromptBuilder{},
+	"DocumentChunkReranker":          &DocumentChunkReranker{},
+	"QianFanDocumentChunkReranker":   &QianFanDocumentChunkReranker{},
+	"BaiLianDocumentChunkReranker":   &BaiLianDocumentChunkReranker{},
+	"LKEDocumentChunkReranker":       &LKEDocumentChunkReranker{},
+	"MarkdownRetriever":              &MarkdownRetriever{},
+	"FtsKeywordRetriever":            &FtsKeywordRetriever{},
+	"HybridRetriever":                &HybridRetriever{},
+	"ContextExpander":                &ContextExpander{},
+	"HyDEEmbedder":                   &HyDEEmbedder{},
+	"MMRReranker":                    &MMRReranker{},
+	"GraphRetriever":                 &GraphRetriever{},
+	"QueryRewriter":                  &QueryRewriter{},
+	"VecEmbeddingRetriever":          &VecEmbeddingRetriever{},
+	"OpenAITextEmbedder":             &OpenAITextEmbedder{},
+	"LKETextEmbedder":                &LKETextEmbedder{},
+	"WebSearch":                      &WebSearch{},
+	"SQLiteVecDocumentStore":         &SQLiteVecDocumentStore{},
+	"OpenAIDocumentEmbedder":         &OpenAIDocumentEmbedder{},
+	"LKEDocumentEmbedder":            &LKEDocumentEmbedder{},
+	"MarkdownIndex":                  &MarkdownIndex{},
+	"DocumentSplitter":               &DocumentSplitter{},
+	"DocumentChunkDeduplicator":      &DocumentChunkDeduplicator{},
+	"DocumentChunkQuestionGenerator": &DocumentChunkQuestionGenerator{},
+	"DocumentChunkContextualizer":    &DocumentChunkContextualizer{},
+	"GraphExtractor":                 &GraphExtractor{},
+	"PIIRedactor":                    &PIIRedactor{},
+	"HtmlCleaner":                    &HtmlCleaner{},
+	"WebScraper":                     &WebScraper{},
+	"EmailConverter":                 &EmailConverter{},
+	"MarkdownConverter":              &MarkdownConverter{},
+	"TikaConverter":                  &TikaConverter{},
 }
 
 // baseComponentMap 基础组件的Map,从数据查询拼装参数
@@ -89,6 +102,14 @@ type IComponent interface {
 	Run(ctx context.Context, input map[string]any) error
 }
 
+// IEmbedder 向量化组件的接口,知识库可以指定使用的向量化组件
+type IEmbedder interface {
+	// EmbeddingModel 向量模型名称
+	EmbeddingModel() string
+	// Embedding 向量化文本,返回向量和序列化后的向量
+	Embedding(ctx context.Context, text string) ([]float64, []byte, error)
+}
+
 func init() {
 	initBaseComponentMap()
 }
@@ -340,9 +361,32 @@ type WebScraper struct {
 	Timeout         int      `json:"timeout,omitempty"`
 	// RemoteChromeAddress 远程的chrome地址,例如 "ws://10.0.0.131:9222/",建议使用 chromedp/headless-shell 镜像
 	RemoteChromeAddress string `json:"remoteChromeAddress,omitempty"`
-	chromedpOptions     []chromedp.ExecAllocatorOption
+	// SitemapURL sitemap地址,不为空时从sitemap抓取网页,遵守robots.txt,只重新索引变化的网页
+	SitemapURL string `json:"sitemapURL,omitempty"`
+	// AllowedHosts sitemap抓取时允许的域名,为空时只抓取sitemap所在的域名
+	AllowedHosts []string `json:"allowedHosts,omitempty"`
+	// PathPrefixes sitemap抓取时允许的路径前缀,为空不限制
+	PathPrefixes []string `json:"pathPrefixes,omitempty"`
+	// CrawlDelay 同一个域名两次请求的最小间隔,单位秒,默认1.robots.txt的Crawl-delay更大时使用Crawl-delay
+	CrawlDelay int `json:"crawlDelay,omitempty"`
+	// HostConcurrency 同一个域名的并发数,默认2
+	HostConcurrency int `json:"hostConcurrency,omitempty"`
+	// MaxPages sitemap抓取的最大网页数,默认1000
+	MaxPages int `json:"maxPages,omitempty"`
+	// FetchMode 抓取方式,chrome(默认)使用headless chrome渲染网页;http使用net/http请求并解析html,网页需要javascript渲染时再使用chrome
+	FetchMode       string `json:"fetchMode,omitempty"`
+	chromedpOptions []chromedp.ExecAllocatorOption
 }
 
+const (
+	// webFetchModeChrome 使用headless chrome抓取网页
+	webFetchModeChrome = "chrome"
+	// webFetchModeHTTP 使用net/http抓取网页,需要javascript渲染时使用chrome
+	webFetchModeHTTP = "http"
+	// webFetchMinTextLength http抓取的正文少于这个长度并且单页应用挂载的元素是空的,认为网页需要javascript渲染
+	webFetchMinTextLength = 200
+)
+
 var userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/141.0.0.0 Safari/537.36 Edg/141.0.0.0"
 
 func (component *WebScraper) Initialization(ctx context.Context, input map[string]any) error {
@@ -355,6 +399,18 @@ func (component *WebScraper) Initialization(ctx context.Context, input map[strin
 	if component.UserAgent == "" {
 		component.UserAgent = userAgent
 	}
+	if component.CrawlDelay <= 0 {
+		component.CrawlDelay = defaultCrawlDelay
+	}
+	if component.HostConcurrency <= 0 {
+		component.HostConcurrency = defaultHostConcurrency
+	}
+	if component.MaxPages <= 0 {
+		component.MaxPages = defaultCrawlMaxPages
+	}
+	if component.FetchMode == "" {
+		component.FetchMode = webFetchModeChrome
+	}
 
 	qs := make([]string, 0)
 	for i := 0; i < len(component.QuerySelector); i++ {
@@ -424,6 +480,141 @@ func (component *WebScraper) FetchPage(ctx context.Context, document *Document,
 		return nil, err
 	}
 
+	var title string
+	var hcs []string
+	var hrefs [][]string
+	var err error
+	if component.FetchMode == webFetchModeHTTP {
+		// 已经请求过的网页内容,例如sitemap抓取时的条件请求,不再重复请求
+		body, _ := input["webScraper_html"].([]byte)
+		var needsJavaScript bool
+		title, hcs, hrefs, needsJavaScript, err = component.fetchPageHTTP(ctx, webURL, body)
+		if err == nil && needsJavaScript {
+			// 需要javascript渲染,使用chrome重新抓取,chrome不可用时使用http的结果
+			chromeTitle, chromeHcs, chromeHrefs, chromeErr := component.fetchPageChrome(ctx, webURL)
+			if chromeErr == nil {
+				title, hcs, hrefs = chromeTitle, chromeHcs, chromeHrefs
+			} else {
+				FuncLogError(ctx, fmt.Errorf("fetch %s with chrome: %w", webURL, chromeErr))
+			}
+		}
+	} else {
+		title, hcs, hrefs, err = component.fetchPageChrome(ctx, webURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	document.Markdown = strings.Join(hcs, ".")
+	markdown, err := component.convertMarkdown(ctx, title, document.Markdown)
+	if markdown != "" {
+		document.Markdown = markdown
+	}
+	document.Name = title
+	hrefSlice := make([]string, 0)
+	for i := 0; i < len(hrefs); i++ {
+		for _, v := range hrefs[i] {
+			if slices.Contains(hrefSlice, v) {
+				continue
+			}
+			hrefSlice = append(hrefSlice, v)
+		}
+
+	}
+
+	return hrefSlice, nil
+}
+
+// fetchPageHTTP 使用net/http请求网页并解析html,返回标题、QuerySelector匹配元素的文本、超链接和是否需要javascript渲染
+func (component *WebScraper) fetchPageHTTP(ctx context.Context, webURL string, body []byte) (string, []string, [][]string, bool, error) {
+	baseURL, err := url.Parse(webURL)
+	if err != nil {
+		return "", nil, nil, false, err
+	}
+	if body == nil {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, webURL, nil)
+		if err != nil {
+			return "", nil, nil, false, err
+		}
+		req.Header.Set("User-Agent", component.UserAgent)
+		req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8")
+		client := &http.Client{Timeout: time.Duration(component.Timeout) * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", nil, nil, false, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return "", nil, nil, false, fmt.Errorf("fetch %s: http status %d", webURL, resp.StatusCode)
+		}
+		body, err = io.ReadAll(io.LimitReader(resp.Body, maxCrawlBodySize))
+		if err != nil {
+			return "", nil, nil, false, err
+		}
+		// 重定向之后的地址
+		baseURL = resp.Request.URL
+	}
+	root, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return "", nil, nil, false, err
+	}
+	// <base href> 修改超链接的相对路径
+	if bases, _ := htmlQuerySelectorAll(root, "base[href]"); len(bases) > 0 {
+		if u, err := baseURL.Parse(htmlAttr(bases[0], "href")); err == nil {
+			baseURL = u
+		}
+	}
+
+	hcs := make([]string, 0)
+	hrefs := make([][]string, len(component.QuerySelector))
+	for i := 0; i < len(component.QuerySelector); i++ {
+		nodes, err := htmlQuerySelectorAll(root, component.QuerySelector[i])
+		if err != nil {
+			continue
+		}
+		for _, n := range nodes {
+			hcs = append(hcs, htmlInnerText(n))
+			// 获取页面的超链接
+			if component.Depth > 1 {
+				hrefs[i] = append(hrefs[i], htmlLinks(n, baseURL)...)
+			}
+		}
+	}
+	return htmlTitle(root), hcs, hrefs, htmlNeedsJavaScript(root), nil
+}
+
+// htmlJavaScriptMounts 单页应用挂载的元素id
+var htmlJavaScriptMounts = []string{"root", "app", "__next", "__nuxt", "___gatsby"}
+
+// htmlNeedsJavaScript 网页是否需要javascript渲染:noscript提示需要启用javascript,或者正文很少并且单页应用挂载的元素是空的
+func htmlNeedsJavaScript(root *html.Node) bool {
+	noscripts, _ := htmlQuerySelectorAll(root, "noscript")
+	for _, n := range noscripts {
+		text := ""
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			text += c.Data
+		}
+		if strings.Contains(strings.ToLower(text), "javascript") {
+			return true
+		}
+	}
+	bodies, _ := htmlQuerySelectorAll(root, "body")
+	if len(bodies) < 1 {
+		return false
+	}
+	if len([]rune(htmlInnerText(bodies[0]))) >= webFetchMinTextLength {
+		return false
+	}
+	for _, id := range htmlJavaScriptMounts {
+		mounts, _ := htmlQuerySelectorAll(root, "#"+id)
+		if len(mounts) > 0 && htmlInnerText(mounts[0]) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchPageChrome 使用headless chrome渲染网页,返回标题、QuerySelector匹配元素的文本和超链接
+func (component *WebScraper) fetchPageChrome(ctx context.Context, webURL string) (string, []string, [][]string, error) {
 	var allocatorContext context.Context
 	var cancel1 context.CancelFunc
 
@@ -489,27 +680,7 @@ func (component *WebScraper) FetchPage(ctx context.Context, document *Document,
 		// 获取网页的title,放到最后再执行
 		chromedp.Title(&title),
 	})
-	if err != nil {
-		return nil, err
-	}
-	document.Markdown = strings.Join(hcs, ".")
-	markdown, err := component.convertMarkdown(ctx, title, document.Markdown)
-	if markdown != "" {
-		document.Markdown = markdown
-	}
-	document.Name = title
-	hrefSlice := make([]string, 0)
-	for i := 0; i < len(hrefs); i++ {
-		for _, v := range hrefs[i] {
-			if slices.Contains(hrefSlice, v) {
-				continue
-			}
-			hrefSlice = append(hrefSlice, v)
-		}
-
-	}
-
-	return hrefSlice, nil
+	return title, hcs, hrefs, err
 }
 
 // @TODO 也可以尝试网页截屏,然后让多模态大模型识别网页正文,方便去除广告.
@@ -807,39 +978,44 @@ func (component *OpenAIDocumentEmbedder) Initialization(ctx context.Context, inp
 	return nil
 }
 func (component *OpenAIDocumentEmbedder) Run(ctx context.Context, input map[string]any) error {
+	return runDocumentEmbedder(ctx, input, component)
+}
+
+// EmbeddingModel 向量模型名称
+func (component *OpenAIDocumentEmbedder) EmbeddingModel() string {
+	return component.Model
+}
+
+// Embedding 向量化文本,优先使用向量缓存
+func (component *OpenAIDocumentEmbedder) Embedding(ctx context.Context, text string) ([]float64, []byte, error) {
+	return embeddingWithCache(ctx, component.Model, component.BaseURL, text, func() ([]float64, error) {
+		return openAIEmbedding(&component.OpenAIChatGenerator, text)
+	})
+}
 
+// runDocumentEmbedder 向量化documentChunks,知识库指定了向量化组件时,使用知识库的组件
+func runDocumentEmbedder(ctx context.Context, input map[string]any, embedder IEmbedder) error {
 	if input["documentChunks"] == nil {
 		return errors.New(funcT("input['documentChunks'] cannot be empty"))
 	}
 	documentChunks := input["documentChunks"].([]DocumentChunk)
 	vecDocumentChunks := make([]VecDocumentChunk, 0)
-	for i := 0; i < len(documentChunks); i++ {
-		bodyMap := make(map[string]any, 0)
-		bodyMap["input"] = []string{documentChunks[i].Markdown}
-		bodyMap["model"] = component.Model
-		bodyMap["encoding_format"] = "float"
-		bodyByte, err := httpPostJsonBody(component.client, component.APIKey, component.BaseURL, component.DefaultHeaders, bodyMap)
-
+	if len(documentChunks) > 0 {
+		knowledgeBaseEmbedder, err := findKnowledgeBaseEmbedder(ctx, documentChunks[0].KnowledgeBaseID)
 		if err != nil {
 			input[errorKey] = err
 			return err
 		}
-		rs := struct {
-			Data []struct {
-				Embedding []float64 `json:"embedding,omitempty"`
-			} `json:"data,omitempty"`
-		}{}
-		err = json.Unmarshal(bodyByte, &rs)
-		if err != nil {
-			input[errorKey] = err
-			return err
+		if knowledgeBaseEmbedder != nil {
+			embedder = knowledgeBaseEmbedder
 		}
-		if len(rs.Data) < 1 {
-			err := errors.New("httpPostJsonBody data is empty")
-			input[errorKey] = err
-			return err
+	}
+	for i := 0; i < len(documentChunks); i++ {
+		// 关联到其他分块的重复分块不向量化
+		if documentChunks[i].DuplicateOf != "" {
+			continue
 		}
-		embedding, err := vecSerializeFloat64(rs.Data[0].Embedding)
+		_, embedding, err := embedder.Embedding(ctx, documentChunkEmbeddingText(documentChunks[i]))
 		if err != nil {
 			input[errorKey] = err
 			return err
@@ -862,8 +1038,10 @@ func (component *OpenAIDocumentEmbedder) Run(ctx context.Context, input map[stri
 	return nil
 }
 
-// SQLiteVecDocumentStore 更新文档和向量
+// SQLiteVecDocumentStore 更新文档和向量.文档内容变化时,旧版本和分块保存为历史版本
 type SQLiteVecDocumentStore struct {
+	// DisableVersion 不保存历史版本
+	DisableVersion bool `json:"disableVersion,omitempty"`
 }
 
 func (component *SQLiteVecDocumentStore) Initialization(ctx context.Context, input map[string]any) error {
@@ -886,8 +1064,26 @@ func (component *SQLiteVecDocumentStore) Run(ctx context.Context, input map[stri
 	if input["vecDocumentChunks"] != nil {
 		vecDocumentChunks = input["vecDocumentChunks"].([]VecDocumentChunk)
 	}
+	var documentChunkQuestions []DocumentChunkQuestion
+	if input["documentChunkQuestions"] != nil {
+		documentChunkQuestions = input["documentChunkQuestions"].([]DocumentChunkQuestion)
+	}
+	var graphs []GraphData
+	if input["graphData"] != nil {
+		graphs = input["graphData"].([]GraphData)
+	}
+
+	// 分块的语言,用于选择全文检索的分词方式
+	language := resolveDocumentLanguage(ctx, document)
 
 	_, err := zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		// 保存历史版本,设置当前版本的生效时间
+		now := time.Now().Format("2006-01-02 15:04:05")
+		if component.DisableVersion {
+			document.ValidFrom = now
+		} else if err := archiveDocumentVersion(ctx, document, now); err != nil {
+			return nil, err
+		}
 		//先删除,重新插入
 		zorm.Delete(ctx, document)
 		document.Status = 1
@@ -898,22 +1094,28 @@ func (component *SQLiteVecDocumentStore) Run(ctx context.Context, input map[stri
 		if err != nil {
 			return count, err
 		}
-		finderDeleteVec := zorm.NewDeleteFinder(tableVecDocumentChunkName).Append("WHERE document_id=?", document.Id)
-		count, err = zorm.UpdateFinder(ctx, finderDeleteVec)
+		// 删除分块的问题
+		finderDeleteQuestion := zorm.NewDeleteFinder(tableDocumentChunkQuestionName).Append("WHERE document_id=?", document.Id)
+		count, err = zorm.UpdateFinder(ctx, finderDeleteQuestion)
 		if err != nil {
 			return count, err
 		}
+		// 删除所有向量表中的数据
+		err = deleteVecDocumentChunk(ctx, document.Id)
+		if err != nil {
+			return nil, err
+		}
 
 		dcs := make([]zorm.IEntityStruct, 0)
-		vecdcs := make([]zorm.IEntityStruct, 0)
 		for i := 0; i < len(documentChunks); i++ {
 			documentChunks[i].Status = 1
+			// 分块继承文档的元数据,用于检索时过滤,分块自己的元数据优先
+			documentChunks[i].Metadata = mergeDocumentMetadata(document.Metadata, documentChunks[i].Metadata)
+			documentChunks[i].Language = language
 			dcs = append(dcs, &documentChunks[i])
-			if len(vecDocumentChunks) < 1 {
-				continue
-			}
+		}
+		for i := 0; i < len(vecDocumentChunks); i++ {
 			vecDocumentChunks[i].Status = 1
-			vecdcs = append(vecdcs, &vecDocumentChunks[i])
 		}
 		if len(dcs) > 0 {
 			count, err = zorm.InsertSlice(ctx, dcs)
@@ -921,16 +1123,33 @@ func (component *SQLiteVecDocumentStore) Run(ctx context.Context, input map[stri
 				return count, err
 			}
 		}
-		if len(vecdcs) > 0 {
-			count, err = zorm.InsertSlice(ctx, vecdcs)
+		questions := make([]zorm.IEntityStruct, 0, len(documentChunkQuestions))
+		for i := 0; i < len(documentChunkQuestions); i++ {
+			questions = append(questions, &documentChunkQuestions[i])
+		}
+		if len(questions) > 0 {
+			count, err = zorm.InsertSlice(ctx, questions)
 			if err != nil {
 				return count, err
 			}
 		}
-
-		return nil, nil
+		// 分块ID重新生成,替换文档的知识图谱
+		err = replaceDocumentGraph(ctx, document, graphs)
+		if err != nil {
+			return nil, err
+		}
+		// 保存到知识库对应的向量表
+		err = storeKnowledgeBaseVecDocumentChunks(ctx, document.KnowledgeBaseID, vecDocumentChunks)
+		return nil, err
 	})
 
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+
+	// 知识库正在重新向量化,同时向量化到新的向量表
+	err = migrateDocumentIfRunning(ctx, document)
 	if err != nil {
 		input[errorKey] = err
 	}
@@ -959,14 +1178,36 @@ func (component *OpenAITextEmbedder) Run(ctx context.Context, input map[string]a
 		return errors.New(funcT("input['query'] cannot be empty"))
 	}
 	query := input["query"].(string)
+	embedding, _, err := component.Embedding(ctx, query)
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+	input["embedding"] = embedding
+	return nil
+}
+
+// EmbeddingModel 向量模型名称
+func (component *OpenAITextEmbedder) EmbeddingModel() string {
+	return component.Model
+}
+
+// Embedding 向量化文本,优先使用向量缓存
+func (component *OpenAITextEmbedder) Embedding(ctx context.Context, text string) ([]float64, []byte, error) {
+	return embeddingWithCache(ctx, component.Model, component.BaseURL, text, func() ([]float64, error) {
+		return openAIEmbedding(&component.OpenAIChatGenerator, text)
+	})
+}
+
+// openAIEmbedding 调用OpenAI兼容的embeddings接口,向量化文本
+func openAIEmbedding(component *OpenAIChatGenerator, text string) ([]float64, error) {
 	bodyMap := make(map[string]any, 0)
-	bodyMap["input"] = []string{query}
+	bodyMap["input"] = []string{text}
 	bodyMap["model"] = component.Model
 	bodyMap["encoding_format"] = "float"
 	bodyByte, err := httpPostJsonBody(component.client, component.APIKey, component.BaseURL, component.DefaultHeaders, bodyMap)
 	if err != nil {
-		input[errorKey] = err
-		return err
+		return nil, err
 	}
 	rs := struct {
 		Data []struct {
@@ -975,16 +1216,12 @@ func (component *OpenAITextEmbedder) Run(ctx context.Context, input map[string]a
 	}{}
 	err = json.Unmarshal(bodyByte, &rs)
 	if err != nil {
-		input[errorKey] = err
-		return err
+		return nil, err
 	}
 	if len(rs.Data) < 1 {
-		err := errors.New("httpPostJsonBody data is empty")
-		input[errorKey] = err
-		return err
+		return nil, errors.New("httpPostJsonBody data is empty")
 	}
-	input["embedding"] = rs.Data[0].Embedding
-	return nil
+	return rs.Data[0].Embedding, nil
 }
 
 // VecEmbeddingRetriever 使用SQLite-Vec向量检索相似数据
@@ -997,8 +1234,12 @@ type VecEmbeddingRetriever struct {
 	Embedding []float64 `json:"embedding,omitempty"`
 	// TopN 检索多少条
 	TopN int `json:"top_n,omitempty"`
-	// Score 向量表的score匹配分数
+	// Score 向量表的score是向量距离,越小越相似.Score是最大距离,大于0时过滤距离更大的分块
 	Score float32 `json:"score,omitempty"`
+	// MetadataFilter 元数据过滤表达式,和input["metadataFilter"]使用 AND 合并
+	MetadataFilter string `json:"metadataFilter,omitempty"`
+	// AsOf 检索这个时间点有效的分块,包含历史版本,input["asOf"]优先
+	AsOf string `json:"asOf,omitempty"`
 }
 
 func (component *VecEmbeddingRetriever) Initialization(ctx context.Context, input map[string]any) error {
@@ -1017,11 +1258,6 @@ func (component *VecEmbeddingRetriever) Run(ctx context.Context, input map[strin
 	if embedding == nil {
 		embedding = component.Embedding
 	}
-	if embedding == nil {
-		err := errors.New(funcT("The embedding of VecEmbeddingRetriever cannot be empty"))
-		input[errorKey] = err
-		return err
-	}
 	dId, has := input["documentID"]
 	if has {
 		documentID = dId.(string)
@@ -1054,43 +1290,95 @@ func (component *VecEmbeddingRetriever) Run(ctx context.Context, input map[strin
 		score = component.Score
 	}
 
-	query, err := vecSerializeFloat64(embedding)
+	// 知识库ID是路径格式,检索包含下级知识库的所有知识库,和全文检索的 knowledge_base_id like 一致.
+	// sqlite-vec只支持等于,每个知识库单独检索再合并.知识库指定了向量化组件,使用对应的组件向量化query,并查询对应的向量表
+	queryText, _ := input["query"].(string)
+	embeddingModel, _ := input["embeddingModel"].(string)
+	targets, err := findVecSearchTargets(ctx, knowledgeBaseID, queryText, embedding, embeddingModel)
 	if err != nil {
 		input[errorKey] = err
 		return err
 	}
-	finder := zorm.NewSelectFinder(tableVecDocumentChunkName, "rowid,distance as score,*").Append("WHERE embedding MATCH ?", query)
-	if documentID != "" {
-		finder.Append(" and document_id=?", documentID)
+	if len(targets) < 1 {
+		err := errors.New(funcT("The embedding of VecEmbeddingRetriever cannot be empty"))
+		input[errorKey] = err
+		return err
 	}
 
-	if knowledgeBaseID != "" {
-		// Only one of EQUALS, GREATER_THAN, LESS_THAN_OR_EQUAL, LESS_THAN, GREATER_THAN_OR_EQUAL, NOT_EQUALS is allowed
-		// vec不支持 like
-		//finder.Append(" and knowledge_base_id like ?", knowledge_base_id+"%")
-		finder.Append(" and knowledge_base_id = ?", knowledgeBaseID)
-	}
 	// Only one of EQUALS, GREATER_THAN, LESS_THAN_OR_EQUAL, LESS_THAN, GREATER_THAN_OR_EQUAL, NOT_EQUALS is allowed
 	// vec不支持 范围查询
 	//if score > 0.0 {
 	//	finder.Append(" and score >= ?", score)
 	//}
-	finder.Append("ORDER BY score LIMIT " + strconv.Itoa(topN))
-	documentChunks := make([]DocumentChunk, 0)
-	err = zorm.Query(ctx, finder, &documentChunks, nil)
+	asOf, err := inputAsOf(input, component.AsOf)
 	if err != nil {
 		input[errorKey] = err
 		return err
 	}
+	// vec不支持元数据过滤,先多检索一些候选数据,再使用document_chunk的元数据过滤.过滤后不够topN时扩大候选数量重新检索,直到候选数据全部检索完
+	metadataFilter, err := inputMetadataFilter(input, component.MetadataFilter)
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+	// 分块的问题向量和分块向量指向同一个分块,多检索一些用于去重
+	limit := topN * 2
+	if metadataFilter != "" || asOf != "" {
+		limit = topN * 10
+	}
+	var documentChunks []DocumentChunk
+	for {
+		candidates, exhausted, err := searchVecTargets(ctx, targets, documentID, limit)
+		if err != nil {
+			input[errorKey] = err
+			return err
+		}
+		// 问题替换为对应的分块,并去重
+		candidates, err = resolveDocumentChunkQuestions(ctx, candidates)
+		if err != nil {
+			input[errorKey] = err
+			return err
+		}
+		candidates, err = filterDocumentChunkMetadata(ctx, candidates, metadataFilter)
+		if err != nil {
+			input[errorKey] = err
+			return err
+		}
+		// 只保留asOf时间点已经生效的当前版本
+		candidates, err = filterDocumentChunkValidAt(ctx, candidates, asOf)
+		if err != nil {
+			input[errorKey] = err
+			return err
+		}
+		documentChunks = candidates
+		if len(documentChunks) >= topN || exhausted || limit >= vecMaxKNNLimit {
+			break
+		}
+		limit = min(limit*4, vecMaxKNNLimit)
+	}
+	// 结果按照距离升序,过滤去重后每个向量空间保留距离最近的topN
+	spaces := vecTargetSpaces(targets)
+	documentChunks = limitVecDocumentChunks(documentChunks, spaces, topN)
 	//更新markdown内容
 	documentChunks, err = findDocumentChunkMarkDown(ctx, documentChunks)
 	if err != nil {
 		input[errorKey] = err
 		return err
 	}
+	// asOf时间点有效的历史版本分块
+	if asOf != "" {
+		for _, target := range targets {
+			versionChunks, err := findDocumentChunkVersionsByEmbedding(ctx, target.Query, target.EmbeddingModel, target.KnowledgeBaseID, documentID, metadataFilter, asOf, topN)
+			if err != nil {
+				input[errorKey] = err
+				return err
+			}
+			documentChunks = append(documentChunks, versionChunks...)
+		}
+	}
 
-	//重新排序
-	documentChunks = sortDocumentChunksScore(documentChunks, topN, score)
+	// 当前版本和历史版本合并,按照距离升序,保留距离最近的topN
+	documentChunks = sortDocumentChunksDistance(documentChunks, spaces, topN, score)
 
 	oldDcs, has := input["documentChunks"]
 	if has && oldDcs != nil {
@@ -1114,9 +1402,25 @@ type FtsKeywordRetriever struct {
 	TopN int `json:"top_n,omitempty"`
 	// Score BM25的FTS5实现在返回结果之前将结果乘以-1,得分越小(数值上更负),表示匹配越好
 	Score float32 `json:"score,omitempty"`
+	// MetadataFilter 元数据过滤表达式,和input["metadataFilter"]使用 AND 合并
+	MetadataFilter string `json:"metadataFilter,omitempty"`
+	// AsOf 检索这个时间点有效的分块,包含历史版本,input["asOf"]优先
+	AsOf string `json:"asOf,omitempty"`
+	// Mode 检索方式,tool(默认)把全文检索函数提供给大模型调用;direct直接使用BM25检索,结果追加到input["documentChunks"],用于不支持函数调用的模型和混合检索
+	Mode string `json:"mode,omitempty"`
 }
 
+const (
+	// ftsRetrieverModeTool 全文检索函数提供给大模型,由大模型决定是否调用
+	ftsRetrieverModeTool = "tool"
+	// ftsRetrieverModeDirect 组件直接执行全文检索
+	ftsRetrieverModeDirect = "direct"
+)
+
 func (component *FtsKeywordRetriever) Initialization(ctx context.Context, input map[string]any) error {
+	if component.Mode == "" {
+		component.Mode = ftsRetrieverModeTool
+	}
 	return nil
 }
 func (component *FtsKeywordRetriever) Run(ctx context.Context, input map[string]any) error {
@@ -1173,6 +1477,39 @@ func (component *FtsKeywordRetriever) Run(ctx context.Context, input map[string]
 		score = component.Score
 		input["score"] = score
 	}
+	// 函数调用时使用input中的元数据过滤表达式
+	metadataFilter, err := inputMetadataFilter(input, component.MetadataFilter)
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+	input["metadataFilter"] = metadataFilter
+	// 函数调用时使用input中的时间点
+	asOf, err := inputAsOf(input, component.AsOf)
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+	input["asOf"] = asOf
+
+	if component.Mode == ftsRetrieverModeDirect {
+		documentIDs := make([]string, 0, 1)
+		if documentID != "" {
+			documentIDs = append(documentIDs, documentID)
+		}
+		documentChunks, err := searchDocumentChunkByKeyword(ctx, query, documentIDs, input)
+		if err != nil {
+			input[errorKey] = err
+			return err
+		}
+		oldDcs, has := input["documentChunks"]
+		if has && oldDcs != nil {
+			oldDocumentChunks := oldDcs.([]DocumentChunk)
+			documentChunks = append(oldDocumentChunks, documentChunks...)
+		}
+		input["documentChunks"] = documentChunks
+		return nil
+	}
 
 	// input 中的tools对象
 	var tools []interface{}
@@ -1195,6 +1532,8 @@ type MarkdownRetriever struct {
 	Query string `json:"query,omitempty"`
 	// TopN 检索多少条
 	TopN int `json:"top_n,omitempty"`
+	// MetadataFilter 元数据过滤表达式,和input["metadataFilter"]使用 AND 合并
+	MetadataFilter string `json:"metadataFilter,omitempty"`
 
 	// 声明LLM大语言类型
 	OpenAIChatGenerator
@@ -1268,9 +1607,24 @@ func (component *MarkdownRetriever) Run(ctx context.Context, input map[string]an
 	if knowledgeBaseID != "" {
 		finder.Append(" and knowledge_base_id like ?", knowledgeBaseID+"%")
 	}
+	// 函数调用时使用input中的元数据过滤表达式
+	metadataFilter, err := inputMetadataFilter(input, component.MetadataFilter)
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+	input["metadataFilter"] = metadataFilter
+	whereSQL, whereValues, err := metadataFilterSQL(metadataFilter, "metadata")
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+	if whereSQL != "" {
+		finder.Append(" and ("+whereSQL+")", whereValues...)
+	}
 
 	documents := make([]Document, 0)
-	err := zorm.Query(ctx, finder, &documents, nil)
+	err = zorm.Query(ctx, finder, &documents, nil)
 	if err != nil {
 		input[errorKey] = err
 		return err
@@ -1319,13 +1673,20 @@ func (component *MarkdownRetriever) Run(ctx context.Context, input map[string]an
 
 // llmJSONResult 请求大模型获得json结果
 func llmJSONResult(ctx context.Context, component OpenAIChatGenerator, message string) (string, error) {
+	return llmChatResult(ctx, component, message, map[string]any{"response_format": map[string]string{"type": "json_object"}})
+}
+
+// llmChatResult 非流式请求大模型获得文本结果,parameters是额外的请求参数,例如response_format,max_tokens
+func llmChatResult(ctx context.Context, component OpenAIChatGenerator, message string, parameters map[string]any) (string, error) {
 	bodyMap := make(map[string]any)
 	bodyMap["messages"] = []ChatMessage{{Role: "user", Content: message}}
 	bodyMap["model"] = component.Model
 	if component.Temperature != 0 {
 		bodyMap["temperature"] = component.Temperature
 	}
-	bodyMap["response_format"] = map[string]string{"type": "json_object"}
+	for k, v := range parameters {
+		bodyMap[k] = v
+	}
 	//输出类型
 	bodyMap["stream"] = false
 	//请求大模型
@@ -1344,8 +1705,8 @@ func llmJSONResult(ctx context.Context, component OpenAIChatGenerator, message s
 		return "", nil
 	}
 	//获取第一个结果
-	resultJson := rs.Choices[0].Message.Content
-	return resultJson, nil
+	result := rs.Choices[0].Message.Content
+	return result, nil
 }
 
 // DocumentChunkReranker 对DocumentChunks进行重新排序
@@ -1495,6 +1856,38 @@ func sortDocumentChunksScore(documentChunks []DocumentChunk, topN int, score flo
 	return resultDCS
 }
 
+// sortDocumentChunksDistance 向量检索的score是距离,按照距离升序排序,保留距离最近的topN.maxDistance大于0时,过滤距离更大的分块.
+// spaces是知识库ID对应的向量空间,分块来自多个向量空间时距离不能直接比较,score换算为分块在各自向量空间中的排名(从0开始),按照排名合并
+func sortDocumentChunksDistance(documentChunks []DocumentChunk, spaces map[string]string, topN int, maxDistance float32) []DocumentChunk {
+	resultDCS := make([]DocumentChunk, 0, len(documentChunks))
+	spaceMap := make(map[string]bool)
+	for _, documentChunk := range documentChunks {
+		if maxDistance > 0 && documentChunk.Score > maxDistance {
+			continue
+		}
+		resultDCS = append(resultDCS, documentChunk)
+		spaceMap[spaces[documentChunk.KnowledgeBaseID]] = true
+	}
+	sort.SliceStable(resultDCS, func(i, j int) bool {
+		return resultDCS[i].Score < resultDCS[j].Score
+	})
+	if len(spaceMap) > 1 {
+		ranks := make(map[string]int, len(spaceMap))
+		for i := 0; i < len(resultDCS); i++ {
+			space := spaces[resultDCS[i].KnowledgeBaseID]
+			resultDCS[i].Score = float32(ranks[space])
+			ranks[space]++
+		}
+		sort.SliceStable(resultDCS, func(i, j int) bool {
+			return resultDCS[i].Score < resultDCS[j].Score
+		})
+	}
+	if len(resultDCS) > topN {
+		resultDCS = resultDCS[:topN]
+	}
+	return resultDCS
+}
+
 // WebSearch 联网搜索,基于网络爬虫扩展
 type WebSearch struct {
 	WebScraper
@@ -1524,7 +1917,11 @@ func (component *WebSearch) Run(ctx context.Context, input map[string]any) error
 
 // PromptBuilder 使用模板构建Prompt提示词
 type PromptBuilder struct {
-	PromptTemplate string             `json:"promptTemplate,omitempty"`
+	PromptTemplate string `json:"promptTemplate,omitempty"`
+	// Citation 开启引用,documentChunks的内容前面加上编号[n],要求大模型标注引用,回答后返回引用的来源
+	Citation bool `json:"citation,omitempty"`
+	// CitationPrompt 要求大模型标注引用的提示词,拼接到提示词后面
+	CitationPrompt string             `json:"citationPrompt,omitempty"`
 	t              *template.Template `json:"-"`
 }
 
@@ -1535,6 +1932,9 @@ func (component *PromptBuilder) Initialization(ctx context.Context, input map[st
 	if err != nil {
 		return err
 	}
+	if component.Citation && component.CitationPrompt == "" {
+		component.CitationPrompt = defaultCitationPrompt
+	}
 	return nil
 }
 func (component *PromptBuilder) Run(ctx context.Context, input map[string]any) error {
@@ -1552,13 +1952,34 @@ func (component *PromptBuilder) Run(ctx context.Context, input map[string]any) e
 		}
 	}
 
+	data := input
+	documentChunks, _ := input["documentChunks"].([]DocumentChunk)
+	citation := component.Citation && len(documentChunks) > 0
+	if citation {
+		sources, err := buildCitationSources(ctx, documentChunks)
+		if err != nil {
+			input[errorKey] = err
+			return err
+		}
+		input["citations"] = sources
+		// 使用带编号的分块渲染模板,不修改input中的documentChunks
+		data = make(map[string]any, len(input))
+		for k, v := range input {
+			data[k] = v
+		}
+		data["documentChunks"] = numberCitationChunks(documentChunks)
+	}
+
 	// 创建一个 bytes.Buffer 用于存储渲染后的 text 内容
 	var buf bytes.Buffer
 	// 执行模板并将结果写入到 bytes.Buffer
-	if err := component.t.Execute(&buf, input); err != nil {
+	if err := component.t.Execute(&buf, data); err != nil {
 		input[errorKey] = err
 		return err
 	}
+	if citation {
+		buf.WriteString(component.CitationPrompt)
+	}
 
 	// 获取编译后的内容
 	input["prompt"] = buf.String()
@@ -1623,15 +2044,8 @@ func (component *OpenAIChatMemory) Run(ctx context.Context, input map[string]any
 
 	conversationID, _ := input["conversationID"].(string)
 
-	messageLogs := make([]MessageLog, 0)
-	if conversationID != "" && component.MemoryLength > 0 {
-		finder := zorm.NewSelectFinder(tableMessageLogName).Append("WHERE conversation_id=? order by create_time desc", conversationID)
-		finder.SelectTotalCount = false
-		page := zorm.NewPage()
-		page.PageSize = component.MemoryLength
-		zorm.Query(ctx, finder, &messageLogs, page)
-	}
-	for i := len(messageLogs) - 1; i >= 0; i-- {
+	messageLogs, _ := findRecentMessageLogs(ctx, conversationID, component.MemoryLength)
+	for i := 0; i < len(messageLogs); i++ {
 		messageLog := messageLogs[i]
 		if messageLog.UserMessage != "" {
 			messages = append(messages, ChatMessage{Role: "user", Content: messageLog.UserMessage})
@@ -1649,6 +2063,26 @@ func (component *OpenAIChatMemory) Run(ctx context.Context, input map[string]any
 	return nil
 }
 
+// findRecentMessageLogs 查询聊天室最近的memoryLength条消息记录,按照时间升序
+func findRecentMessageLogs(ctx context.Context, conversationID string, memoryLength int) ([]MessageLog, error) {
+	messageLogs := make([]MessageLog, 0)
+	if conversationID == "" || memoryLength < 1 {
+		return messageLogs, nil
+	}
+	finder := zorm.NewSelectFinder(tableMessageLogName).Append("WHERE conversation_id=? order by create_time desc", conversationID)
+	finder.SelectTotalCount = false
+	page := zorm.NewPage()
+	page.PageSize = memoryLength
+	err := zorm.Query(ctx, finder, &messageLogs, page)
+	if err != nil {
+		return messageLogs, err
+	}
+	for i, j := 0, len(messageLogs)-1; i < j; i, j = i+1, j-1 {
+		messageLogs[i], messageLogs[j] = messageLogs[j], messageLogs[i]
+	}
+	return messageLogs, nil
+}
+
 type Choice struct {
 	FinishReason string      `json:"finish_reason,omitempty"`
 	Index        int         `json:"index,omitempty"`
@@ -1796,6 +2230,15 @@ func (component *OpenAIChatGenerator) Run(ctx context.Context, input map[string]
 			//获取第一个结果
 			choice := rs.Choices[0]
 			rsByte, _ := json.Marshal(rs)
+			// 开启引用时,返回结果增加引用的来源
+			if len(choice.Message.ToolCalls) == 0 {
+				if citations := inputCitations(input, choice.Message.Content); citations != nil {
+					rsByte, _ = json.Marshal(struct {
+						Choices   []Choice         `json:"choices,omitempty"`
+						Citations []CitationSource `json:"citations"`
+					}{Choices: rs.Choices, Citations: citations})
+				}
+			}
 			rsStr := string(rsByte)
 			//没有函数调用,把模型返回的choice放入到input["choice"],并输出
 			if len(choice.Message.ToolCalls) == 0 {
@@ -1883,6 +2326,15 @@ func (component *OpenAIChatGenerator) Run(ctx context.Context, input map[string]
 			if data == "[DONE]" { //结束符
 				// 没有需要调用的函数,就输出结束 DONE
 				if c != nil && len(toolCalls) == 0 {
+					// 开启引用时,在结束符之前输出引用的来源
+					if citations := inputCitations(input, messageContent.String()); citations != nil {
+						citationByte, _ := json.Marshal(struct {
+							Choices   []Choice         `json:"choices"`
+							Citations []CitationSource `json:"citations"`
+						}{Choices: []Choice{{}}, Citations: citations})
+						c.WriteString("data: " + string(citationByte) + "\n\n")
+						c.Flush()
+					}
 					c.WriteString("data: [DONE]\n\n")
 					c.Flush()
 				}
@@ -2052,6 +2504,10 @@ func (component *ChatMessageLogStore) Run(ctx context.Context, input map[string]
 	messageLog.UserID = userId
 	messageLog.UserMessage = query
 	messageLog.AIMessage = choice.Message.Content
+	// 保存回答中引用的分块
+	messageLog.Citations = citedSourcesJson(inputCitations(input, choice.Message.Content))
+	// 保存组件运行的中间结果,用于调试流水线
+	messageLog.Trace = traceJson(input)
 
 	finder := zorm.NewSelectFinder(tableConversationName).Append("WHERE id=?", conversationID)
 	conversation := &Conversation{}
@@ -3,8 +3,12 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"golang.org/x/net/html"
 )
 
 func TestHtmlCleaner(t *testing.T) {
@@ -43,6 +47,90 @@ func TestWebScraper(t *testing.T) {
 
 }
 
+func TestWebScraperHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/spa":
+			w.Write([]byte(`<html><head><title>SPA</title></head><body><div id="root"></div><script src="/app.js"></script></body></html>`))
+		default:
+			w.Write([]byte(`<html><head><title> 示例网页 </title><base href="/docs/"></head><body>
+<div class="nav"><a href="/">首页</a></div>
+<div class="content main"><h1>标题</h1><p>第一段<br>第二行</p><script>var x=1;</script>
+<ul><li><a href="a.html#top">A</a></li><li><a href="https://minrag.com/b">B</a></li><li><a href="javascript:void(0)">C</a></li></ul></div>
+<div class="content"><p>另一段</p></div>
+</body></html>`))
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	ws := WebScraper{QuerySelector: []string{"div.content.main", "body > div.content p"}, FetchMode: webFetchModeHTTP, Depth: 2}
+	ws.Initialization(ctx, nil)
+	document := &Document{}
+	input := map[string]any{"document": document, "webScraper_webURL": server.URL + "/index.html"}
+	hrefs, err := ws.FetchPage(ctx, document, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if document.Name != "示例网页" {
+		t.Errorf("title: %q", document.Name)
+	}
+	if !strings.Contains(document.Markdown, "标题\n第一段\n第二行") || !strings.Contains(document.Markdown, "另一段") || strings.Contains(document.Markdown, "var x") || strings.Contains(document.Markdown, "首页") {
+		t.Errorf("markdown: %q", document.Markdown)
+	}
+	want := []string{server.URL + "/docs/a.html#top", "https://minrag.com/b"}
+	if strings.Join(hrefs, ",") != strings.Join(want, ",") {
+		t.Errorf("hrefs: %v", hrefs)
+	}
+
+	// 需要javascript渲染,chrome不可用时使用http的结果
+	document = &Document{}
+	input = map[string]any{"document": document, "webScraper_webURL": server.URL + "/spa"}
+	ws.QuerySelector = []string{"body"}
+	ws.RemoteChromeAddress = "ws://127.0.0.1:1/"
+	ws.Timeout = 2
+	if _, err := ws.FetchPage(ctx, document, input); err != nil {
+		t.Fatal(err)
+	}
+	if document.Name != "SPA" {
+		t.Errorf("title: %q", document.Name)
+	}
+}
+
+func TestHTMLQuerySelectorAll(t *testing.T) {
+	root, err := html.Parse(strings.NewReader(`<div id="a" class="x"><p class="y">1</p><span><p class="y" data-k="v">2</p></span></div><p>3</p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"p":                "1,2,3",
+		"div p":            "1,2",
+		"div > p":          "1",
+		"#a .y":            "1,2",
+		"p[data-k=v]":      "2",
+		"span p, div>p.y":  "1,2",
+		"div.x.y":          "",
+		"*[data-k]":        "2",
+		"body > p:not(.y)": "error",
+	}
+	for selector, want := range tests {
+		nodes, err := htmlQuerySelectorAll(root, selector)
+		if err != nil {
+			if want != "error" {
+				t.Errorf("%s: %v", selector, err)
+			}
+			continue
+		}
+		texts := make([]string, 0)
+		for _, n := range nodes {
+			texts = append(texts, htmlInnerText(n))
+		}
+		if got := strings.Join(texts, ","); got != want {
+			t.Errorf("%s: got %q, want %q", selector, got, want)
+		}
+	}
+}
+
 func TestWebSearch(t *testing.T) {
 	ws := WebSearch{}
 	ws.Depth = 2
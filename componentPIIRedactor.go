@@ -0,0 +1,295 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// piiActionMask 部分遮盖,例如 138****5678
+	piiActionMask = "mask"
+	// piiActionHash 替换为 [类型:hash],相同的值hash相同,不泄露原文
+	piiActionHash = "hash"
+	// piiActionReject 发现敏感信息直接报错,停止流水线
+	piiActionReject = "reject"
+)
+
+// piiDetector 敏感信息检测器
+type piiDetector struct {
+	// name 检测器名称
+	name string
+	// re 匹配的正则表达式
+	re *regexp.Regexp
+	// digitBoundary 匹配结果的前后不能是数字,避免匹配到长数字的一部分
+	digitBoundary bool
+	// validate 校验匹配结果,例如身份证校验码和银行卡Luhn校验
+	validate func(string) bool
+}
+
+// piiBuiltinDetectors 内置的检测器,按照优先级排序,重叠的匹配保留优先级高的
+var piiBuiltinDetectors = []piiDetector{
+	{name: "email", re: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`)},
+	{name: "cn_id", re: regexp.MustCompile(`[1-9]\d{5}(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]`), digitBoundary: true, validate: validCNID},
+	{name: "bank_card", re: regexp.MustCompile(`[1-9]\d{3}(?:[ \-]\d{4}){2,3}(?:[ \-]\d{1,3})?|[1-9]\d{12,18}`), digitBoundary: true, validate: validLuhn},
+	{name: "cn_mobile", re: regexp.MustCompile(`(?:\+?86[ \-]?)?1[3-9]\d{9}`), digitBoundary: true},
+	{name: "phone", re: regexp.MustCompile(`\+[1-9]\d{0,2}[ \-]?\(?\d{1,4}\)?(?:[ \-]?\d{2,4}){2,3}|(?:\(0\d{2,3}\)|0\d{2,3}-)\d{7,8}`), digitBoundary: true},
+}
+
+// PIIPattern 自定义的敏感信息正则
+type PIIPattern struct {
+	// Name 名称,用于日志和hash替换
+	Name string `json:"name,omitempty"`
+	// Pattern 正则表达式
+	Pattern string `json:"pattern,omitempty"`
+	// Action 处理方式 mask,hash,reject.默认mask
+	Action string `json:"action,omitempty"`
+}
+
+// PIIRedactor 基于规则的敏感信息脱敏,检测手机号、电话、身份证、邮箱、银行卡和自定义正则.
+// 放到indexPipeline的SQLiteVecDocumentStore之前(建议放到向量化之前),脱敏document和documentChunks;
+// 放到聊天流水线的PromptBuilder之前,脱敏query、messages和documentChunks,避免发送到外部大模型
+type PIIRedactor struct {
+	// Detectors 内置检测器和处理方式,例如 {"cn_mobile":"mask","cn_id":"reject"},为空启用全部内置检测器,处理方式是mask
+	Detectors map[string]string `json:"detectors,omitempty"`
+	// Patterns 自定义的正则
+	Patterns []PIIPattern `json:"patterns,omitempty"`
+	// HashSalt hash的盐值
+	HashSalt string `json:"hashSalt,omitempty"`
+
+	detectors []piiDetector `json:"-"`
+	actions   []string      `json:"-"`
+}
+
+func (component *PIIRedactor) Initialization(ctx context.Context, input map[string]any) error {
+	component.detectors = make([]piiDetector, 0)
+	component.actions = make([]string, 0)
+	for _, detector := range piiBuiltinDetectors {
+		action := piiActionMask
+		if len(component.Detectors) > 0 {
+			a, has := component.Detectors[detector.name]
+			if !has {
+				continue
+			}
+			action = a
+		}
+		if err := validPIIAction(detector.name, action); err != nil {
+			return err
+		}
+		component.detectors = append(component.detectors, detector)
+		component.actions = append(component.actions, action)
+	}
+	for name := range component.Detectors {
+		found := false
+		for _, detector := range piiBuiltinDetectors {
+			found = found || detector.name == name
+		}
+		if !found {
+			return fmt.Errorf("Initialization PIIRedactor error:detector %s is not supported", name)
+		}
+	}
+	for _, pattern := range component.Patterns {
+		re, err := regexp.Compile(pattern.Pattern)
+		if err != nil {
+			return fmt.Errorf("Initialization PIIRedactor error:pattern %s %w", pattern.Name, err)
+		}
+		if pattern.Name == "" {
+			pattern.Name = "custom"
+		}
+		if pattern.Action == "" {
+			pattern.Action = piiActionMask
+		}
+		if err := validPIIAction(pattern.Name, pattern.Action); err != nil {
+			return err
+		}
+		component.detectors = append(component.detectors, piiDetector{name: pattern.Name, re: re})
+		component.actions = append(component.actions, pattern.Action)
+	}
+	return nil
+}
+
+func (component *PIIRedactor) Run(ctx context.Context, input map[string]any) error {
+	counts := make(map[string]int)
+	var err error
+	if query, ok := input["query"].(string); ok {
+		query, err = component.redact(query, counts)
+		if err != nil {
+			input[errorKey] = err
+			return err
+		}
+		input["query"] = query
+	}
+	if messages, ok := input["messages"].([]ChatMessage); ok {
+		for i := 0; i < len(messages); i++ {
+			messages[i].Content, err = component.redact(messages[i].Content, counts)
+			if err != nil {
+				input[errorKey] = err
+				return err
+			}
+		}
+	}
+	if document, ok := input["document"].(*Document); ok && document != nil {
+		document.Markdown, err = component.redact(document.Markdown, counts)
+		if err != nil {
+			input[errorKey] = err
+			return err
+		}
+	}
+	if documentChunks, ok := input["documentChunks"].([]DocumentChunk); ok {
+		for i := 0; i < len(documentChunks); i++ {
+			documentChunks[i].Markdown, err = component.redact(documentChunks[i].Markdown, counts)
+			if err != nil {
+				input[errorKey] = err
+				return err
+			}
+		}
+	}
+	if len(counts) > 0 {
+		input["piiRedactions"] = counts
+		names := make([]string, 0, len(counts))
+		for name, count := range counts {
+			names = append(names, fmt.Sprintf("%s=%d", name, count))
+		}
+		sort.Strings(names)
+		FuncLogInfo(ctx, "PIIRedactor redacted "+strings.Join(names, ","))
+	}
+	return nil
+}
+
+// piiMatch 敏感信息的匹配位置
+type piiMatch struct {
+	start, end int
+	index      int
+}
+
+// redact 脱敏文本,counts 累计每个检测器的脱敏数量.处理方式是reject时返回错误
+func (component *PIIRedactor) redact(text string, counts map[string]int) (string, error) {
+	if text == "" {
+		return text, nil
+	}
+	matches := make([]piiMatch, 0)
+	for index, detector := range component.detectors {
+		for _, loc := range detector.re.FindAllStringIndex(text, -1) {
+			if detector.digitBoundary && ((loc[0] > 0 && isASCIIDigit(text[loc[0]-1])) || (loc[1] < len(text) && isASCIIDigit(text[loc[1]]))) {
+				continue
+			}
+			if detector.validate != nil && !detector.validate(text[loc[0]:loc[1]]) {
+				continue
+			}
+			// 和优先级更高的匹配重叠,忽略
+			overlap := false
+			for _, m := range matches {
+				if loc[0] < m.end && m.start < loc[1] {
+					overlap = true
+					break
+				}
+			}
+			if overlap {
+				continue
+			}
+			if component.actions[index] == piiActionReject {
+				return text, errors.New(funcT("The content contains sensitive information") + ":" + detector.name)
+			}
+			matches = append(matches, piiMatch{start: loc[0], end: loc[1], index: index})
+		}
+	}
+	if len(matches) < 1 {
+		return text, nil
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		name := component.detectors[m.index].name
+		value := text[m.start:m.end]
+		sb.WriteString(text[last:m.start])
+		if component.actions[m.index] == piiActionHash {
+			sb.WriteString("[" + name + ":" + sha256hex(component.HashSalt + value)[:12] + "]")
+		} else {
+			sb.WriteString(maskPII(value))
+		}
+		counts[name]++
+		last = m.end
+	}
+	sb.WriteString(text[last:])
+	return sb.String(), nil
+}
+
+// validPIIAction 校验处理方式
+func validPIIAction(name string, action string) error {
+	if action != piiActionMask && action != piiActionHash && action != piiActionReject {
+		return fmt.Errorf("Initialization PIIRedactor error:action %s of %s is not supported", action, name)
+	}
+	return nil
+}
+
+// maskPII 遮盖敏感信息,邮箱保留用户名首字符和域名,其他保留前3位和后4位
+func maskPII(value string) string {
+	if local, domain, found := strings.Cut(value, "@"); found {
+		runes := []rune(local)
+		return string(runes[:1]) + "***@" + domain
+	}
+	runes := []rune(value)
+	if len(runes) <= 8 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[:3]) + strings.Repeat("*", len(runes)-7) + string(runes[len(runes)-4:])
+}
+
+// isASCIIDigit 是否是数字
+func isASCIIDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// validCNID 校验18位身份证号的校验码
+func validCNID(id string) bool {
+	if len(id) != 18 {
+		return false
+	}
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	sum := 0
+	for i := 0; i < 17; i++ {
+		sum += int(id[i]-'0') * weights[i]
+	}
+	return "10X98765432"[sum%11] == strings.ToUpper(id[17:])[0]
+}
+
+// validLuhn 使用Luhn算法校验银行卡号,忽略空格和横线
+func validLuhn(card string) bool {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(card)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPIIRedactor(t *testing.T) {
+	ctx := context.Background()
+	component := &PIIRedactor{}
+	if err := component.Initialization(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	text := "联系人手机13812345678,邮箱zhang.san@example.com,身份证11010519491231002X,银行卡6222 0212 3456 7899 012,座机(010)12345678,订单号123456789012345678"
+	input := map[string]any{"query": text}
+	if err := component.Run(ctx, input); err != nil {
+		t.Fatal(err)
+	}
+	query := input["query"].(string)
+	for _, s := range []string{"13812345678", "zhang.san", "11010519491231002X", "6222 0212 3456 7899 012", "12345678,"} {
+		if strings.Contains(query, s) {
+			t.Errorf("%q is not redacted: %s", s, query)
+		}
+	}
+	// 校验码错误的身份证和Luhn校验失败的长数字不脱敏
+	if !strings.Contains(query, "123456789012345678") {
+		t.Errorf("order number should not be redacted: %s", query)
+	}
+	if !strings.Contains(query, "138****5678") || !strings.Contains(query, "z***@example.com") {
+		t.Errorf("unexpected mask: %s", query)
+	}
+	counts := input["piiRedactions"].(map[string]int)
+	if counts["cn_mobile"] != 1 || counts["cn_id"] != 1 || counts["bank_card"] != 1 || counts["email"] != 1 || counts["phone"] != 1 {
+		t.Errorf("unexpected counts %v", counts)
+	}
+
+	component = &PIIRedactor{Detectors: map[string]string{"cn_mobile": piiActionHash}, Patterns: []PIIPattern{{Name: "employee", Pattern: `EMP\d{6}`, Action: piiActionReject}}}
+	if err := component.Initialization(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	chunks := []DocumentChunk{{Markdown: "手机 13812345678 和 13812345678"}}
+	input = map[string]any{"documentChunks": chunks, "document": &Document{Markdown: "邮箱 a@b.cn"}}
+	if err := component.Run(ctx, input); err != nil {
+		t.Fatal(err)
+	}
+	hash := "[cn_mobile:" + sha256hex("13812345678")[:12] + "]"
+	if chunks[0].Markdown != "手机 "+hash+" 和 "+hash {
+		t.Errorf("unexpected hash: %s", chunks[0].Markdown)
+	}
+	if input["document"].(*Document).Markdown != "邮箱 a@b.cn" {
+		t.Errorf("disabled detector should not redact")
+	}
+	if err := component.Run(ctx, map[string]any{"query": "工号EMP123456"}); err == nil {
+		t.Errorf("custom reject pattern should return error")
+	}
+	if err := (&PIIRedactor{Detectors: map[string]string{"unknown": piiActionMask}}).Initialization(ctx, nil); err == nil {
+		t.Errorf("unknown detector should return error")
+	}
+}
@@ -232,6 +232,29 @@ func runComponent(ctx context.Context, input map[string]any, currPipelineCompone
 	return nil
 }
 
+// appendTrace 记录组件运行的中间结果到input[traceKey],用于调试流水线,ChatMessageLogStore保存到message_log的trace字段
+func appendTrace(input map[string]any, componentName string, value any) {
+	var trace []map[string]any
+	if input[traceKey] != nil {
+		trace = input[traceKey].([]map[string]any)
+	}
+	trace = append(trace, map[string]any{"component": componentName, "value": value})
+	input[traceKey] = trace
+}
+
+// traceJson input[traceKey]转换为json,没有记录返回空字符串
+func traceJson(input map[string]any) string {
+	trace, ok := input[traceKey].([]map[string]any)
+	if !ok || len(trace) < 1 {
+		return ""
+	}
+	traceByte, err := json.Marshal(trace)
+	if err != nil {
+		return ""
+	}
+	return string(traceByte)
+}
+
 // findPipelineById 根据ID查找流水线组件
 func findPipelineById(ctx context.Context, pipelineId string, input map[string]any) (*Pipeline, error) {
 	// 流水线组件,以后有可以单独初始化一个,不用启动时全部初始化
@@ -0,0 +1,256 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// QueryRewriter 查询改写,使用聊天室最近的消息把追问改写为独立的问题,例如 "那怎么关闭它?" 改写为 "怎么关闭自动更新?",
+// 还可以生成多个不同的表述,每个查询都使用检索组件检索,结果使用倒数排名融合.
+// input["query"]保持原始问题,用于PromptBuilder和Reranker,改写后的查询保存到input["queryVariants"],并记录到运行轨迹
+type QueryRewriter struct {
+	OpenAIChatGenerator
+
+	// Prompt 改写查询的提示词
+	Prompt string `json:"prompt,omitempty"`
+	// MemoryLength 使用最近几条消息记录,默认5
+	MemoryLength int `json:"memoryLength,omitempty"`
+	// VariantCount 额外生成几个不同的表述,默认0,只改写为独立的问题
+	VariantCount int `json:"variantCount,omitempty"`
+	// KeepOriginal 是否同时使用原始问题检索,默认false
+	KeepOriginal bool `json:"keepOriginal,omitempty"`
+	// EmbedderID 向量化查询的组件ID,默认OpenAITextEmbedder,知识库指定了向量化组件时由向量检索组件处理
+	EmbedderID string `json:"embedderID,omitempty"`
+	// RetrieverIDs 检索组件的ID,默认VecEmbeddingRetriever和FtsKeywordRetriever
+	RetrieverIDs []string `json:"retrieverIDs,omitempty"`
+	// RRFK 倒数排名融合的平滑常数k,默认60
+	RRFK int `json:"rrfK,omitempty"`
+	// TopN 融合后返回多少条,input["topN"]优先,默认5
+	TopN int `json:"top_n,omitempty"`
+}
+
+// queryRewriteResult 大模型返回的改写结果
+type queryRewriteResult struct {
+	Query    string   `json:"query"`
+	Variants []string `json:"variants"`
+}
+
+func (component *QueryRewriter) Initialization(ctx context.Context, input map[string]any) error {
+	component.OpenAIChatGenerator.Initialization(ctx, input)
+	if component.Prompt == "" {
+		component.Prompt = `根据聊天记录,把用户的最新问题改写为不依赖聊天记录也能理解的独立问题,补全代词和省略的主语,不要回答问题.
+		同时生成%d个含义相同但是表述不同的问题,用于检索知识库.使用和用户问题相同的语言.
+		返回的json格式示例:{"query":"<改写后的问题>","variants":["<不同表述1>","<不同表述2>"]}
+		`
+	}
+	if component.MemoryLength == 0 {
+		component.MemoryLength = 5
+	}
+	if component.EmbedderID == "" {
+		component.EmbedderID = "OpenAITextEmbedder"
+	}
+	if len(component.RetrieverIDs) < 1 {
+		component.RetrieverIDs = []string{"VecEmbeddingRetriever", "FtsKeywordRetriever"}
+	}
+	if component.RRFK == 0 {
+		component.RRFK = 60
+	}
+	if component.TopN == 0 {
+		component.TopN = 5
+	}
+	return nil
+}
+
+func (component *QueryRewriter) Run(ctx context.Context, input map[string]any) error {
+	query, _ := input["query"].(string)
+	if query == "" {
+		err := errors.New(funcT("input['query'] cannot be empty"))
+		input[errorKey] = err
+		return err
+	}
+	topN := 0
+	if tId, has := input["topN"]; has && tId != nil {
+		topN = tId.(int)
+	}
+	if topN == 0 {
+		topN = component.TopN
+	}
+
+	conversationID, _ := input["conversationID"].(string)
+	messageLogs, err := findRecentMessageLogs(ctx, conversationID, component.MemoryLength)
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+	queries, err := component.rewriteQuery(ctx, query, messageLogs)
+	if err != nil {
+		// 改写失败不影响问答,使用原始问题检索
+		FuncLogError(ctx, fmt.Errorf("QueryRewriter: %w", err))
+		queries = []string{query}
+	}
+	input["queryVariants"] = queries
+	appendTrace(input, "QueryRewriter", map[string]any{"query": query, "queryVariants": queries})
+
+	documentChunks, err := component.retrieve(ctx, input, queries, topN)
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+	oldDcs, has := input["documentChunks"]
+	if has && oldDcs != nil {
+		oldDocumentChunks := oldDcs.([]DocumentChunk)
+		documentChunks = append(oldDocumentChunks, documentChunks...)
+	}
+	input["documentChunks"] = documentChunks
+	return nil
+}
+
+// rewriteQuery 改写为独立的问题和不同的表述,返回去重后的查询列表,第一个是改写后的问题
+func (component *QueryRewriter) rewriteQuery(ctx context.Context, query string, messageLogs []MessageLog) ([]string, error) {
+	// 没有聊天记录也不需要生成不同的表述,不用请求大模型
+	if len(messageLogs) < 1 && component.VariantCount < 1 {
+		return []string{query}, nil
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf(component.Prompt, component.VariantCount))
+	if len(messageLogs) > 0 {
+		sb.WriteString("聊天记录:\n")
+		for _, messageLog := range messageLogs {
+			if messageLog.UserMessage != "" {
+				sb.WriteString("user: " + messageLog.UserMessage + "\n")
+			}
+			if messageLog.AIMessage != "" {
+				sb.WriteString("assistant: " + truncateRunes(messageLog.AIMessage, 500) + "\n")
+			}
+		}
+	}
+	sb.WriteString("用户的最新问题:" + query)
+	resultJson, err := llmJSONResult(ctx, component.OpenAIChatGenerator, sb.String())
+	if err != nil {
+		return nil, err
+	}
+	result := queryRewriteResult{}
+	err = json.Unmarshal([]byte(resultJson), &result)
+	if err != nil {
+		return nil, err
+	}
+	return cleanQueryVariants(query, result, component.VariantCount, component.KeepOriginal), nil
+}
+
+// cleanQueryVariants 去掉空的和重复的查询,改写后的问题为空时使用原始问题
+func cleanQueryVariants(query string, result queryRewriteResult, variantCount int, keepOriginal bool) []string {
+	queries := make([]string, 0, variantCount+2)
+	seen := make(map[string]bool)
+	appendQuery := func(q string) {
+		q = strings.TrimSpace(q)
+		if q == "" || seen[q] {
+			return
+		}
+		seen[q] = true
+		queries = append(queries, q)
+	}
+	appendQuery(result.Query)
+	if keepOriginal || len(queries) < 1 {
+		appendQuery(query)
+	}
+	for i := 0; i < len(result.Variants) && i < variantCount; i++ {
+		appendQuery(result.Variants[i])
+	}
+	return queries
+}
+
+// retrieve 每个查询使用所有的检索组件检索,结果使用倒数排名融合
+func (component *QueryRewriter) retrieve(ctx context.Context, input map[string]any, queries []string, topN int) ([]DocumentChunk, error) {
+	retrievers := make([]IComponent, len(component.RetrieverIDs))
+	lowerBetters := make([]bool, len(component.RetrieverIDs))
+	for i, retrieverID := range component.RetrieverIDs {
+		retriever, lowerBetter, err := findRetrieverComponent(retrieverID)
+		if err != nil {
+			return nil, err
+		}
+		retrievers[i] = retriever
+		lowerBetters[i] = lowerBetter
+	}
+	// 知识库指定了向量化组件时,向量检索组件使用知识库的向量化组件
+	var embedder IEmbedder
+	if component.EmbedderID != "" {
+		knowledgeBaseID, _ := input["knowledgeBaseID"].(string)
+		kbEmbedder, err := findKnowledgeBaseEmbedder(ctx, knowledgeBaseID)
+		if err != nil {
+			return nil, err
+		}
+		if kbEmbedder == nil {
+			embedder, err = findEmbedder(component.EmbedderID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sources := make([]hybridSource, len(queries)*len(retrievers))
+	errs := make([]error, len(queries))
+	var wg sync.WaitGroup
+	for i := 0; i < len(queries); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			queryInput := copyRetrieverInput(input)
+			queryInput["query"] = queries[i]
+			if embedder != nil {
+				embedding, _, err := embedder.Embedding(ctx, queries[i])
+				if err != nil {
+					errs[i] = err
+					return
+				}
+				queryInput["embedding"] = embedding
+				queryInput["embeddingModel"] = embedder.EmbeddingModel()
+			} else {
+				// 知识库的向量化组件在向量检索组件中向量化每个查询,不能使用原始问题的向量
+				delete(queryInput, "embedding")
+				delete(queryInput, "embeddingModel")
+			}
+			for j, retriever := range retrievers {
+				retrieverInput := copyRetrieverInput(queryInput)
+				retrieverInput["topN"] = topN
+				err := retriever.Run(ctx, retrieverInput)
+				if err != nil {
+					errs[i] = err
+					return
+				}
+				source := hybridSource{Name: fmt.Sprintf("%s#%d", component.RetrieverIDs[j], i+1), Weight: 1, LowerBetter: lowerBetters[j]}
+				if retrieverInput["documentChunks"] != nil {
+					source.DocumentChunks = retrieverInput["documentChunks"].([]DocumentChunk)
+				}
+				sources[i*len(retrievers)+j] = source
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fuseDocumentChunks(sources, hybridFusionRRF, component.RRFK, topN), nil
+}
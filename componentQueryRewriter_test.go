@@ -0,0 +1,51 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCleanQueryVariants(t *testing.T) {
+	result := queryRewriteResult{Query: " 怎么关闭自动更新? ", Variants: []string{"如何禁用自动更新?", "怎么关闭自动更新?", "", "自动更新怎么停用?"}}
+	queries := cleanQueryVariants("那怎么关闭它?", result, 2, false)
+	if strings.Join(queries, "|") != "怎么关闭自动更新?|如何禁用自动更新?" {
+		t.Errorf("unexpected queries: %v", queries)
+	}
+	queries = cleanQueryVariants("那怎么关闭它?", result, 0, true)
+	if strings.Join(queries, "|") != "怎么关闭自动更新?|那怎么关闭它?" {
+		t.Errorf("unexpected queries: %v", queries)
+	}
+	// 改写结果为空时使用原始问题
+	queries = cleanQueryVariants("那怎么关闭它?", queryRewriteResult{}, 2, false)
+	if strings.Join(queries, "|") != "那怎么关闭它?" {
+		t.Errorf("unexpected queries: %v", queries)
+	}
+}
+
+func TestTraceJson(t *testing.T) {
+	input := map[string]any{}
+	if traceJson(input) != "" {
+		t.Errorf("empty trace should not be saved")
+	}
+	appendTrace(input, "QueryRewriter", map[string]any{"query": "怎么关闭自动更新?", "queryVariants": []string{"如何禁用自动更新?"}})
+	if got := traceJson(input); got != `[{"component":"QueryRewriter","value":{"query":"怎么关闭自动更新?","queryVariants":["如何禁用自动更新?"]}}]` {
+		t.Errorf("unexpected trace: %s", got)
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gitee.com/chunanyong/zorm"
+)
+
+// DocumentChunkQuestionGenerator 使用大模型为每个分块生成用户可能提出的问题,问题向量化后保存到向量表,ID是问题ID,通过document_chunk_question指向分块.
+// 用户的问题和问题比较相似度,弥补问题和分块内容在字面和语义上的差异.VecEmbeddingRetriever检索到问题时,返回对应的分块并去重.
+// 放到indexPipeline的向量化组件之后,SQLiteVecDocumentStore之前,例如 OpenAIDocumentEmbedder -> DocumentChunkQuestionGenerator -> SQLiteVecDocumentStore
+type DocumentChunkQuestionGenerator struct {
+	OpenAIChatGenerator
+
+	// Prompt 生成问题的提示词,%d是问题数量
+	Prompt string `json:"prompt,omitempty"`
+	// QuestionCount 每个分块生成的问题数量,默认3
+	QuestionCount int `json:"questionCount,omitempty"`
+	// MinLength 分块内容少于这个长度时不生成问题,默认50
+	MinLength int `json:"minLength,omitempty"`
+	// EmbedderID 向量化问题的组件ID,必须和向量化分块的组件一致.知识库指定了向量化组件时使用知识库的组件,默认 OpenAIDocumentEmbedder
+	EmbedderID string `json:"embedderID,omitempty"`
+}
+
+func (component *DocumentChunkQuestionGenerator) Initialization(ctx context.Context, input map[string]any) error {
+	component.OpenAIChatGenerator.Initialization(ctx, input)
+	if component.Prompt == "" {
+		component.Prompt = `根据提供的文档内容,生成%d个用户可能提出的问题,问题的答案必须在文档内容中,问题要简洁,不要重复,使用和文档内容相同的语言.
+		返回的json格式示例:{"questions":["问题1","问题2"]}
+		文档内容:
+		`
+	}
+	if component.QuestionCount == 0 {
+		component.QuestionCount = 3
+	}
+	if component.MinLength == 0 {
+		component.MinLength = 50
+	}
+	if component.EmbedderID == "" {
+		component.EmbedderID = "OpenAIDocumentEmbedder"
+	}
+	return nil
+}
+
+func (component *DocumentChunkQuestionGenerator) Run(ctx context.Context, input map[string]any) error {
+	if input["documentChunks"] == nil {
+		return nil
+	}
+	documentChunks := input["documentChunks"].([]DocumentChunk)
+	if len(documentChunks) < 1 {
+		return nil
+	}
+	var vecDocumentChunks []VecDocumentChunk
+	if input["vecDocumentChunks"] != nil {
+		vecDocumentChunks = input["vecDocumentChunks"].([]VecDocumentChunk)
+	}
+
+	// 和分块使用相同的向量化组件
+	embedder, err := findKnowledgeBaseEmbedder(ctx, documentChunks[0].KnowledgeBaseID)
+	if err != nil {
+		input[errorKey] = err
+		return err
+	}
+	if embedder == nil {
+		embedder, err = findEmbedder(component.EmbedderID)
+		if err != nil {
+			input[errorKey] = err
+			return err
+		}
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	questions := make([]DocumentChunkQuestion, 0)
+	for i := 0; i < len(documentChunks); i++ {
+		documentChunk := documentChunks[i]
+		// 重复的分块没有向量,不生成问题
+		if documentChunk.DuplicateOf != "" || runeLength(strings.TrimSpace(documentChunk.Markdown)) < component.MinLength {
+			continue
+		}
+		chunkQuestions, err := component.generateQuestions(ctx, documentChunk.Markdown)
+		if err != nil {
+			// 问题只是补充检索,生成失败不影响文档索引
+			FuncLogError(ctx, fmt.Errorf("DocumentChunkQuestionGenerator %s: %w", documentChunk.Id, err))
+			continue
+		}
+		for _, question := range chunkQuestions {
+			_, embedding, err := embedder.Embedding(ctx, question)
+			if err != nil {
+				input[errorKey] = err
+				return err
+			}
+			chunkQuestion := DocumentChunkQuestion{
+				Id:              FuncGenerateStringID(),
+				DocumentChunkID: documentChunk.Id,
+				DocumentID:      documentChunk.DocumentID,
+				KnowledgeBaseID: documentChunk.KnowledgeBaseID,
+				Question:        question,
+				SortNo:          documentChunk.SortNo,
+				CreateTime:      now,
+			}
+			questions = append(questions, chunkQuestion)
+
+			vecdc := VecDocumentChunk{}
+			vecdc.Id = chunkQuestion.Id
+			vecdc.DocumentID = chunkQuestion.DocumentID
+			vecdc.KnowledgeBaseID = chunkQuestion.KnowledgeBaseID
+			vecdc.SortNo = chunkQuestion.SortNo
+			vecdc.Status = 2
+			vecdc.Embedding = embedding
+			vecDocumentChunks = append(vecDocumentChunks, vecdc)
+		}
+	}
+	input["documentChunkQuestions"] = questions
+	input["vecDocumentChunks"] = vecDocumentChunks
+	return nil
+}
+
+// generateQuestions 请求大模型生成分块的问题,去掉空的和重复的问题
+func (component *DocumentChunkQuestionGenerator) generateQuestions(ctx context.Context, markdown string) ([]string, error) {
+	message := fmt.Sprintf(component.Prompt, component.QuestionCount) + markdown
+	resultJson, err := llmJSONResult(ctx, component.OpenAIChatGenerator, message)
+	if err != nil {
+		return nil, err
+	}
+	questionResult := struct {
+		Questions []string `json:"questions,omitempty"`
+	}{}
+	err = json.Unmarshal([]byte(resultJson), &questionResult)
+	if err != nil {
+		return nil, err
+	}
+	return cleanQuestions(questionResult.Questions, component.QuestionCount), nil
+}
+
+// cleanQuestions 去掉空的和重复的问题,最多保留count个
+func cleanQuestions(questions []string, count int) []string {
+	result := make([]string, 0, count)
+	seen := make(map[string]bool)
+	for _, question := range questions {
+		question = strings.TrimSpace(question)
+		if question == "" || seen[question] {
+			continue
+		}
+		seen[question] = true
+		result = append(result, question)
+		if len(result) >= count {
+			break
+		}
+	}
+	return result
+}
+
+// resolveDocumentChunkQuestions 向量检索结果中的问题替换为对应的分块,同一个分块只保留第一个(距离最近)的结果
+func resolveDocumentChunkQuestions(ctx context.Context, documentChunks []DocumentChunk) ([]DocumentChunk, error) {
+	if len(documentChunks) < 1 {
+		return documentChunks, nil
+	}
+	ids := make([]string, 0, len(documentChunks))
+	for i := 0; i < len(documentChunks); i++ {
+		ids = append(ids, documentChunks[i].Id)
+	}
+	finder := zorm.NewSelectFinder(tableDocumentChunkQuestionName, "id,document_chunk_id").Append("WHERE id IN (?)", ids)
+	finder.SelectTotalCount = false
+	questions := make([]DocumentChunkQuestion, 0)
+	err := zorm.Query(ctx, finder, &questions, nil)
+	if err != nil {
+		return documentChunks, err
+	}
+	questionChunkIDs := make(map[string]string, len(questions))
+	for _, question := range questions {
+		questionChunkIDs[question.Id] = question.DocumentChunkID
+	}
+	return dedupDocumentChunkQuestions(documentChunks, questionChunkIDs), nil
+}
+
+// dedupDocumentChunkQuestions 使用问题对应的分块ID替换ID,并按照分块ID去重,保持原有顺序
+func dedupDocumentChunkQuestions(documentChunks []DocumentChunk, questionChunkIDs map[string]string) []DocumentChunk {
+	resultDCS := make([]DocumentChunk, 0, len(documentChunks))
+	seen := make(map[string]bool, len(documentChunks))
+	for i := 0; i < len(documentChunks); i++ {
+		documentChunk := documentChunks[i]
+		if chunkID, has := questionChunkIDs[documentChunk.Id]; has {
+			documentChunk.Id = chunkID
+		}
+		if seen[documentChunk.Id] {
+			continue
+		}
+		seen[documentChunk.Id] = true
+		resultDCS = append(resultDCS, documentChunk)
+	}
+	return resultDCS
+}
+
+// migrateDocumentChunkQuestions 重新向量化文档的问题,用于知识库切换向量化组件
+func migrateDocumentChunkQuestions(ctx context.Context, documentID string, embedder IEmbedder) ([]VecDocumentChunk, error) {
+	finder := zorm.NewSelectFinder(tableDocumentChunkQuestionName).Append("WHERE document_id=? order by sortno asc", documentID)
+	finder.SelectTotalCount = false
+	questions := make([]DocumentChunkQuestion, 0)
+	err := zorm.Query(ctx, finder, &questions, nil)
+	if err != nil {
+		return nil, err
+	}
+	vecDocumentChunks := make([]VecDocumentChunk, 0, len(questions))
+	for i := 0; i < len(questions); i++ {
+		_, embedding, err := embedder.Embedding(ctx, questions[i].Question)
+		if err != nil {
+			return nil, fmt.Errorf("question %s: %w", questions[i].Id, err)
+		}
+		vecdc := VecDocumentChunk{}
+		vecdc.Id = questions[i].Id
+		vecdc.DocumentID = questions[i].DocumentID
+		vecdc.KnowledgeBaseID = questions[i].KnowledgeBaseID
+		vecdc.SortNo = questions[i].SortNo
+		vecdc.Status = 1
+		vecdc.Embedding = embedding
+		vecDocumentChunks = append(vecDocumentChunks, vecdc)
+	}
+	return vecDocumentChunks, nil
+}
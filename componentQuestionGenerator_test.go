@@ -0,0 +1,47 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCleanQuestions(t *testing.T) {
+	questions := cleanQuestions([]string{" 如何报销差旅费? ", "", "如何报销差旅费?", "报销需要哪些材料?", "报销多久到账?"}, 2)
+	if strings.Join(questions, "|") != "如何报销差旅费?|报销需要哪些材料?" {
+		t.Errorf("unexpected questions: %v", questions)
+	}
+}
+
+func TestDedupDocumentChunkQuestions(t *testing.T) {
+	// 按照距离升序的检索结果,q1和q2是c1的问题
+	documentChunks := []DocumentChunk{{Id: "q1", Score: 0.1}, {Id: "c2", Score: 0.2}, {Id: "c1", Score: 0.3}, {Id: "q2", Score: 0.4}, {Id: "q3", Score: 0.5}}
+	questionChunkIDs := map[string]string{"q1": "c1", "q2": "c1", "q3": "c3"}
+	result := dedupDocumentChunkQuestions(documentChunks, questionChunkIDs)
+	ids := make([]string, 0)
+	for _, documentChunk := range result {
+		ids = append(ids, documentChunk.Id)
+	}
+	if strings.Join(ids, ",") != "c1,c2,c3" {
+		t.Errorf("unexpected ids: %v", ids)
+	}
+	if result[0].Score != 0.1 {
+		t.Errorf("should keep the nearest score, got %v", result[0].Score)
+	}
+}
@@ -58,6 +58,51 @@ const (
 	// 消息日志
 	tableMessageLogName = "message_log"
 
+	// 向量缓存
+	tableEmbeddingCacheName = "embedding_cache"
+
+	// 目录同步
+	tableFolderSyncName = "folder_sync"
+
+	// 目录同步的文件
+	tableFolderSyncFileName = "folder_sync_file"
+
+	// git仓库同步
+	tableGitSyncName = "git_sync"
+
+	// 网页抓取记录
+	tableWebCrawlPageName = "web_crawl_page"
+
+	// 分块的相似度签名
+	tableDocumentChunkSignatureName = "document_chunk_signature"
+
+	// 重复的分块
+	tableDocumentChunkDuplicateName = "document_chunk_duplicate"
+
+	// 分块的假设问题,问题的向量指向分块
+	tableDocumentChunkQuestionName = "document_chunk_question"
+
+	// simple分词的全文检索表,用于中文,日文,韩文
+	tableFtsDocumentChunkName = "fts_document_chunk"
+
+	// porter分词的全文检索表,用于英文等拉丁语系
+	tableFtsDocumentChunkPorterName = "fts_document_chunk_porter"
+
+	// 文档的历史版本
+	tableDocumentVersionName = "document_version"
+
+	// 历史版本的分块
+	tableDocumentChunkVersionName = "document_chunk_version"
+
+	// 知识图谱的节点
+	tableKnowledgeGraphNodeName = "knowledge_graph_node"
+
+	// 知识图谱节点之间的关系
+	tableKnowledgeGraphEdgeName = "knowledge_graph_edge"
+
+	// 知识图谱节点关联的分块
+	tableKnowledgeGraphNodeChunkName = "knowledge_graph_node_chunk"
+
 	//---------------------------//
 
 	// 模板的路径
@@ -73,6 +73,7 @@ var funcMap = template.FuncMap{
 	"componentType":     funcComponentType,
 	"knowledgeBases":    funcKnowledgeBases,
 	"pipelineIDs":       funcPipelineIDs,
+	"embedderIDs":       funcEmbedderIDs,
 }
 
 // funcBasePath 基础路径,前端所有的资源请求必须带上 {{basePath}}
@@ -214,11 +215,13 @@ func funcSelectList(urlPathParam string, q string, pageNo int, pageSize int, sql
 
 		i := strings.Index(whereSQL, " where ")
 		// fst5 搜索相关性排序 ORDER BY rank; 后期再进行修改调整,先按照sortno排序
+		// 根据搜索关键字的语言选择全文检索表和分词方式
+		ftsTable, matchSQL, matchValue := ftsMatch(ftsQueryLanguage(context.Background(), "", q), q)
 		if i < 0 { // 没有where
 			finder.Append(sql, values...)
-			finder.Append(" where id in (select document_id from fts_document_chunk where fts_document_chunk match jieba_query(?) ) ", q)
+			finder.Append(" where id in (select document_id from "+ftsTable+" where "+matchSQL+" ) ", matchValue)
 		} else {
-			finder.Append(sql[:i+7]+" id in (select document_id from fts_document_chunk where fts_document_chunk match jieba_query(?) ) and ", q)
+			finder.Append(sql[:i+7]+" id in (select document_id from "+ftsTable+" where "+matchSQL+" ) and ", matchValue)
 			finder.Append(sql[i+7:], values...)
 		}
 		finder.Append(orderBy)
@@ -256,6 +259,26 @@ func funcSelectList(urlPathParam string, q string, pageNo int, pageSize int, sql
 		data := make([]Document, 0)
 		zorm.Query(ctx, finder, &data, page)
 		responseData.Data = data
+	case tableFolderSyncName:
+		data := make([]FolderSync, 0)
+		zorm.Query(ctx, finder, &data, page)
+		responseData.Data = data
+	case tableGitSyncName:
+		data := make([]GitSync, 0)
+		zorm.Query(ctx, finder, &data, page)
+		responseData.Data = data
+	case tableDocumentChunkDuplicateName:
+		data := make([]DocumentChunkDuplicate, 0)
+		zorm.Query(ctx, finder, &data, page)
+		responseData.Data = data
+	case tableDocumentVersionName:
+		data := make([]DocumentVersion, 0)
+		zorm.Query(ctx, finder, &data, page)
+		responseData.Data = data
+	case tableKnowledgeGraphNodeName:
+		data := make([]KnowledgeGraphNode, 0)
+		zorm.Query(ctx, finder, &data, page)
+		responseData.Data = data
 	case "": // 对象为空查询map
 		data, err := zorm.QueryMap(ctx, finder, page)
 		responseData.Data = data
@@ -344,6 +367,22 @@ func funcSelectOne(urlPathParam string, sql string, values ...interface{}) (inte
 		} else {
 			selectOneData = Agent{}
 		}
+	case tableFolderSyncName:
+		data := make([]FolderSync, 0)
+		zorm.Query(ctx, finder, &data, page)
+		if len(data) > 0 {
+			selectOneData = data[0]
+		} else {
+			selectOneData = FolderSync{}
+		}
+	case tableGitSyncName:
+		data := make([]GitSync, 0)
+		zorm.Query(ctx, finder, &data, page)
+		if len(data) > 0 {
+			selectOneData = data[0]
+		} else {
+			selectOneData = GitSync{}
+		}
 	case "": // 对象为空查询map
 		selectOneData, _ = zorm.QueryRowMap(ctx, finder)
 	default:
@@ -533,3 +572,15 @@ func funcPipelineIDs() []string {
 	zorm.Query(context.Background(), finder, &pipelineIDs, nil)
 	return pipelineIDs
 }
+
+// funcEmbedderIDs 可用的向量化组件ID
+func funcEmbedderIDs() []string {
+	embedderIDs := make([]string, 0)
+	for id, component := range baseComponentMap {
+		if _, ok := component.(IEmbedder); ok {
+			embedderIDs = append(embedderIDs, id)
+		}
+	}
+	sort.Strings(embedderIDs)
+	return embedderIDs
+}
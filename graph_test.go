@@ -29,30 +29,6 @@ s.graphEngine.AddGraph(ctx,
 
 **/
 
-type GraphEntity struct {
-	Entity           string   `json:"entity,omitempty"`
-	EntityAttributes []string `json:"entity_attributes,omitempty"`
-	Entity1          string   `json:"entity1,omitempty"`
-	Entity2          string   `json:"entity2,omitempty"`
-	Relation         string   `json:"relation,omitempty"`
-}
-type GraphData struct {
-	Text     string          `json:"text,omitempty"`
-	Node     []GraphNode     `json:"node,omitempty"`
-	Relation []GraphRelation `json:"relation,omitempty"`
-}
-
-type GraphNode struct {
-	Name       string   `json:"name,omitempty"`
-	Chunks     []string `json:"chunks,omitempty"`
-	Attributes []string `json:"attributes,omitempty"`
-}
-type GraphRelation struct {
-	Node1 string `json:"node1,omitempty"`
-	Node2 string `json:"node2,omitempty"`
-	Type  string `json:"type,omitempty"`
-}
-
 const api_key = "A4FTACZVPGAIV8PZCKIBEUGV7ZBMXTIBEGUGNC11"
 const api_url = "https://ai.gitee.com/v1/chat/completions"
 const model_name = "DeepSeek-V3.2"
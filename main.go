@@ -18,6 +18,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -71,6 +72,13 @@ func main() {
 	message += "\n" + funcT("Open the back-end in the browser") + ": " + httpServerPath + "admin/login"
 	fmt.Println(message)
 
+	// 开启目录同步和git同步,继续没有完成的重新向量化
+	if installed {
+		initFolderSync(context.Background())
+		initGitSync(context.Background())
+		initKnowledgeBaseMigration(context.Background())
+	}
+
 	// 启动服务
 	h.Spin()
 }
@@ -62,6 +62,9 @@ type Config struct {
 	// LLMModel 默认的LLM模型
 	LLMModel string `column:"llm_model" json:"llmModel,omitempty"`
 
+	// EmbeddingCacheSize 向量缓存的最大条数,0使用默认值,小于0禁用缓存
+	EmbeddingCacheSize int `column:"embedding_cache_size" json:"embeddingCacheSize,omitempty"`
+
 	// CreateTime 创建时间
 	CreateTime string `column:"create_time" json:"createTime,omitempty"`
 
@@ -109,6 +112,27 @@ type KnowledgeBase struct {
 	// KnowledgeBaseType 知识库类型
 	KnowledgeBaseType int `column:"knowledge_base_type" json:"knowledgeBaseType,omitempty"`
 
+	// EmbedderID 向量化组件ID,为空使用indexPipeline的默认组件和vec_document_chunk表
+	EmbedderID string `column:"embedder_id" json:"embedderID,omitempty"`
+
+	// EmbeddingModel 向量模型
+	EmbeddingModel string `column:"embedding_model" json:"embeddingModel,omitempty"`
+
+	// EmbeddingDimension 向量维度,对应 vec_document_chunk_{维度} 表
+	EmbeddingDimension int `column:"embedding_dimension" json:"embeddingDimension,omitempty"`
+
+	// MigrateEmbedderID 重新向量化的目标组件ID
+	MigrateEmbedderID string `column:"migrate_embedder_id" json:"migrateEmbedderID,omitempty"`
+
+	// MigrateStatus 重新向量化的状态 无(0),迁移中(1),迁移失败(2)
+	MigrateStatus int `column:"migrate_status" json:"migrateStatus,omitempty"`
+
+	// MigrateMessage 重新向量化的进度或者错误信息
+	MigrateMessage string `column:"migrate_message" json:"migrateMessage,omitempty"`
+
+	// Language 知识库的语言 zh,ja,ko,en,为空在索引时自动检测
+	Language string `column:"language" json:"language,omitempty"`
+
 	// CreateTime 创建时间
 	CreateTime string `column:"create_time" json:"createTime,omitempty"`
 
@@ -177,6 +201,15 @@ type Document struct {
 	// FileExt 文档后缀
 	FileExt string `column:"file_ext" json:"fileExt,omitempty"`
 
+	// Metadata 元数据,json对象格式,例如 {"lang":"zh","version":3},用于检索时过滤
+	Metadata string `column:"metadata" json:"metadata,omitempty"`
+
+	// Language 文档的语言 zh,ja,ko,en,为空使用知识库的设置
+	Language string `column:"language" json:"language,omitempty"`
+
+	// ValidFrom 当前版本的生效时间,重新上传内容变化时,旧版本保存到document_version
+	ValidFrom string `column:"valid_from" json:"validFrom,omitempty"`
+
 	// CreateTime 创建时间
 	CreateTime string `column:"create_time" json:"createTime,omitempty"`
 
@@ -239,6 +272,18 @@ type DocumentChunk struct {
 	// Markdown Markdown内容
 	Markdown string `column:"markdown" json:"markdown,omitempty"`
 
+	// Metadata 元数据,从文档复制,用于检索时过滤
+	Metadata string `column:"metadata" json:"metadata,omitempty"`
+
+	// DuplicateOf 近似重复的分块ID,不为空时分块不向量化
+	DuplicateOf string `column:"duplicate_of" json:"duplicateOf,omitempty"`
+
+	// Language 分块的语言,索引时根据文档和知识库的设置或者自动检测,用于选择全文检索的分词方式
+	Language string `column:"language" json:"language,omitempty"`
+
+	// Context 大模型生成的分块在文档中的上下文,向量化和全文检索时拼接到内容前面,不影响Markdown的展示
+	Context string `column:"context" json:"context,omitempty"`
+
 	// CreateTime 创建时间
 	CreateTime string `column:"create_time" json:"createTime,omitempty"`
 
@@ -261,6 +306,8 @@ type DocumentChunk struct {
 	RowID int `json:"rowID,omitempty"`
 	// Score 向量表的score匹配分数
 	Score float32 `json:"score,omitempty"`
+	// SourceScores 混合检索时各个检索器的原始分数,key是来源名称,用于调试
+	SourceScores map[string]float32 `json:"sourceScores,omitempty"`
 
 	Nodes []*DocumentChunk `json:"nodes,omitempty"` // 子节点
 }
@@ -410,6 +457,9 @@ type Agent struct {
 	// MemoryLength 上下文记忆的长度
 	MemoryLength int `column:"memory_length" json:"memoryLength,omitempty"`
 
+	// MetadataFilter 元数据过滤表达式,例如 lang = "zh" AND version >= 3
+	MetadataFilter string `column:"metadata_filter" json:"metadataFilter,omitempty"`
+
 	// CreateTime 创建时间
 	CreateTime string `column:"create_time" json:"createTime,omitempty"`
 
@@ -509,6 +559,12 @@ type MessageLog struct {
 	// AIMessage AI发送的信息
 	AIMessage string `column:"ai_message" json:"aiMessage,omitempty"`
 
+	// Citations 回答中引用的来源json
+	Citations string `column:"citations" json:"citations,omitempty"`
+
+	// Trace 流水线组件运行的中间结果json,例如改写的查询
+	Trace string `column:"trace" json:"trace,omitempty"`
+
 	// UserID 用户ID
 	UserID string `column:"user_id" json:"userID,omitempty"`
 
@@ -530,6 +586,638 @@ func (entity *MessageLog) GetPKColumnName() string {
 	return "id"
 }
 
+// EmbeddingCache 向量缓存,使用 (model, base_url, sha256(text)) 作为唯一标识
+type EmbeddingCache struct {
+	// 引入默认的struct,隔离IEntityStruct的方法改动
+	zorm.EntityStruct
+
+	// ID sha256(model+base_url+text_hash)
+	Id string `column:"id" json:"id,omitempty"`
+
+	// Model 向量模型
+	Model string `column:"model" json:"model,omitempty"`
+
+	// BaseURL 向量模型的API地址
+	BaseURL string `column:"base_url" json:"baseURL,omitempty"`
+
+	// TextHash 文本的sha256
+	TextHash string `column:"text_hash" json:"textHash,omitempty"`
+
+	// Embedding vecSerializeFloat64序列化后的向量
+	Embedding []byte `column:"embedding" json:"embedding,omitempty"`
+
+	// Dimension 向量维度
+	Dimension int `column:"dimension" json:"dimension,omitempty"`
+
+	// HitCount 命中次数
+	HitCount int `column:"hit_count" json:"hitCount,omitempty"`
+
+	// CreateTime 创建时间
+	CreateTime string `column:"create_time" json:"createTime,omitempty"`
+
+	// UpdateTime 更新时间,命中时也会更新,用于淘汰最久未使用的缓存
+	UpdateTime string `column:"update_time" json:"updateTime,omitempty"`
+}
+
+// GetTableName 获取表名称
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *EmbeddingCache) GetTableName() string {
+	return tableEmbeddingCacheName
+}
+
+// GetPKColumnName 获取数据库表的主键字段名称.因为要兼容Map,只能是数据库的字段名称
+// 不支持联合主键,变通认为无主键,业务控制实现(艰难取舍)
+// 如果没有主键,也需要实现这个方法, return "" 即可
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *EmbeddingCache) GetPKColumnName() string {
+	return "id"
+}
+
+// FolderSync 目录同步,把本地目录镜像到知识库,子目录对应子知识库
+type FolderSync struct {
+	// 引入默认的struct,隔离IEntityStruct的方法改动
+	zorm.EntityStruct
+
+	// ID
+	Id string `column:"id" json:"id,omitempty"`
+
+	// Name 名称
+	Name string `column:"name" json:"name,omitempty"`
+
+	// RootPath 同步的本地目录
+	RootPath string `column:"root_path" json:"rootPath,omitempty"`
+
+	// IncludeGlobs 包含的文件,多个使用逗号或换行隔开,例如 **/*.md,docs/**/*.pdf
+	IncludeGlobs string `column:"include_globs" json:"includeGlobs,omitempty"`
+
+	// ExcludeGlobs 排除的文件或目录,多个使用逗号或换行隔开,例如 **/.git/**
+	ExcludeGlobs string `column:"exclude_globs" json:"excludeGlobs,omitempty"`
+
+	// KnowledgeBaseID 目标知识库ID
+	KnowledgeBaseID string `column:"knowledge_base_id" json:"knowledgeBaseID,omitempty"`
+
+	// SyncMode 同步方式 定时扫描(0),监听文件变化(1)
+	SyncMode int `column:"sync_mode" json:"syncMode,omitempty"`
+
+	// SyncInterval 定时扫描的间隔,单位秒.监听文件变化时也会定时扫描,避免遗漏
+	SyncInterval int `column:"sync_interval" json:"syncInterval,omitempty"`
+
+	// SyncStatus 同步状态 空闲(0),同步中(1),同步失败(2)
+	SyncStatus int `column:"sync_status" json:"syncStatus,omitempty"`
+
+	// SyncMessage 最近一次同步的结果
+	SyncMessage string `column:"sync_message" json:"syncMessage,omitempty"`
+
+	// LastSyncTime 最近一次同步的时间
+	LastSyncTime string `column:"last_sync_time" json:"lastSyncTime,omitempty"`
+
+	// CreateTime 创建时间
+	CreateTime string `column:"create_time" json:"createTime,omitempty"`
+
+	// UpdateTime 更新时间
+	UpdateTime string `column:"update_time" json:"updateTime,omitempty"`
+
+	// CreateUser 创建人,初始化 system
+	CreateUser string `column:"create_user" json:"createUser,omitempty"`
+
+	// SortNo 排序
+	SortNo int `column:"sortno" json:"sortno,omitempty"`
+
+	// Status 状态 禁用(0),可用(1)
+	Status int `column:"status" json:"status,omitempty"`
+}
+
+// GetTableName 获取表名称
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *FolderSync) GetTableName() string {
+	return tableFolderSyncName
+}
+
+// GetPKColumnName 获取数据库表的主键字段名称.因为要兼容Map,只能是数据库的字段名称
+// 不支持联合主键,变通认为无主键,业务控制实现(艰难取舍)
+// 如果没有主键,也需要实现这个方法, return "" 即可
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *FolderSync) GetPKColumnName() string {
+	return "id"
+}
+
+// FolderSyncFile 目录同步的文件,记录文件的修改时间和hash,用于检测文件变化
+type FolderSyncFile struct {
+	// 引入默认的struct,隔离IEntityStruct的方法改动
+	zorm.EntityStruct
+
+	// ID sha256(folder_sync_id+file_path)
+	Id string `column:"id" json:"id,omitempty"`
+
+	// FolderSyncID 目录同步ID
+	FolderSyncID string `column:"folder_sync_id" json:"folderSyncID,omitempty"`
+
+	// FilePath 相对于同步目录的文件路径
+	FilePath string `column:"file_path" json:"filePath,omitempty"`
+
+	// DocumentID 对应的文档ID
+	DocumentID string `column:"document_id" json:"documentID,omitempty"`
+
+	// FileSize 文件大小,单位字节
+	FileSize int64 `column:"file_size" json:"fileSize,omitempty"`
+
+	// ModTime 文件的修改时间,UnixNano.索引失败时为0,下次同步重新处理
+	ModTime int64 `column:"mod_time" json:"modTime,omitempty"`
+
+	// FileHash 文件内容的sha256
+	FileHash string `column:"file_hash" json:"fileHash,omitempty"`
+
+	// UpdateTime 更新时间
+	UpdateTime string `column:"update_time" json:"updateTime,omitempty"`
+}
+
+// GetTableName 获取表名称
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *FolderSyncFile) GetTableName() string {
+	return tableFolderSyncFileName
+}
+
+// GetPKColumnName 获取数据库表的主键字段名称.因为要兼容Map,只能是数据库的字段名称
+// 不支持联合主键,变通认为无主键,业务控制实现(艰难取舍)
+// 如果没有主键,也需要实现这个方法, return "" 即可
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *FolderSyncFile) GetPKColumnName() string {
+	return "id"
+}
+
+// GitSync git仓库同步,增量索引仓库中的文本文件
+type GitSync struct {
+	// 引入默认的struct,隔离IEntityStruct的方法改动
+	zorm.EntityStruct
+
+	// ID
+	Id string `column:"id" json:"id,omitempty"`
+
+	// Name 名称
+	Name string `column:"name" json:"name,omitempty"`
+
+	// RepoURL 仓库地址,可以是本地路径或者远程地址
+	RepoURL string `column:"repo_url" json:"repoURL,omitempty"`
+
+	// Branch 分支,为空使用默认分支
+	Branch string `column:"branch" json:"branch,omitempty"`
+
+	// Extensions 需要索引的文件后缀,多个使用逗号隔开,例如 .md,.txt
+	Extensions string `column:"extensions" json:"extensions,omitempty"`
+
+	// KnowledgeBaseID 目标知识库ID
+	KnowledgeBaseID string `column:"knowledge_base_id" json:"knowledgeBaseID,omitempty"`
+
+	// SourceURLTemplate 源文件的URL模板,支持 {commit} {path} {branch},例如 https://github.com/org/repo/blob/{commit}/{path}
+	SourceURLTemplate string `column:"source_url_template" json:"sourceURLTemplate,omitempty"`
+
+	// LastCommit 最近一次索引的commit
+	LastCommit string `column:"last_commit" json:"lastCommit,omitempty"`
+
+	// SyncInterval 定时同步的间隔,单位秒
+	SyncInterval int `column:"sync_interval" json:"syncInterval,omitempty"`
+
+	// SyncStatus 同步状态 空闲(0),同步中(1),同步失败(2)
+	SyncStatus int `column:"sync_status" json:"syncStatus,omitempty"`
+
+	// SyncMessage 最近一次同步的结果
+	SyncMessage string `column:"sync_message" json:"syncMessage,omitempty"`
+
+	// LastSyncTime 最近一次同步的时间
+	LastSyncTime string `column:"last_sync_time" json:"lastSyncTime,omitempty"`
+
+	// CreateTime 创建时间
+	CreateTime string `column:"create_time" json:"createTime,omitempty"`
+
+	// UpdateTime 更新时间
+	UpdateTime string `column:"update_time" json:"updateTime,omitempty"`
+
+	// CreateUser 创建人,初始化 system
+	CreateUser string `column:"create_user" json:"createUser,omitempty"`
+
+	// SortNo 排序
+	SortNo int `column:"sortno" json:"sortno,omitempty"`
+
+	// Status 状态 禁用(0),可用(1)
+	Status int `column:"status" json:"status,omitempty"`
+}
+
+// GetTableName 获取表名称
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *GitSync) GetTableName() string {
+	return tableGitSyncName
+}
+
+// GetPKColumnName 获取数据库表的主键字段名称.因为要兼容Map,只能是数据库的字段名称
+// 不支持联合主键,变通认为无主键,业务控制实现(艰难取舍)
+// 如果没有主键,也需要实现这个方法, return "" 即可
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *GitSync) GetPKColumnName() string {
+	return "id"
+}
+
+// DocumentChunkSignature 分块的相似度签名,用于检测知识库中近似重复的分块
+type DocumentChunkSignature struct {
+	// 引入默认的struct,隔离IEntityStruct的方法改动
+	zorm.EntityStruct
+
+	// ID 分块ID
+	Id string `column:"id" json:"id,omitempty"`
+
+	// DocumentID 文档ID
+	DocumentID string `column:"document_id" json:"documentID,omitempty"`
+
+	// KnowledgeBaseID 知识库ID
+	KnowledgeBaseID string `column:"knowledge_base_id" json:"knowledgeBaseID,omitempty"`
+
+	// Algorithm 签名算法 simhash 或者 minhash
+	Algorithm string `column:"algorithm" json:"algorithm,omitempty"`
+
+	// Signature 十六进制的签名
+	Signature string `column:"signature" json:"signature,omitempty"`
+}
+
+// GetTableName 获取表名称
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *DocumentChunkSignature) GetTableName() string {
+	return tableDocumentChunkSignatureName
+}
+
+// GetPKColumnName 获取数据库表的主键字段名称.因为要兼容Map,只能是数据库的字段名称
+// 不支持联合主键,变通认为无主键,业务控制实现(艰难取舍)
+// 如果没有主键,也需要实现这个方法, return "" 即可
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *DocumentChunkSignature) GetPKColumnName() string {
+	return "id"
+}
+
+// DocumentChunkDuplicate 近似重复的分块,记录重复分块的来源文档
+type DocumentChunkDuplicate struct {
+	// 引入默认的struct,隔离IEntityStruct的方法改动
+	zorm.EntityStruct
+
+	// ID 重复的分块ID
+	Id string `column:"id" json:"id,omitempty"`
+
+	// KnowledgeBaseID 知识库ID
+	KnowledgeBaseID string `column:"knowledge_base_id" json:"knowledgeBaseID,omitempty"`
+
+	// DocumentID 重复分块所在的文档ID
+	DocumentID string `column:"document_id" json:"documentID,omitempty"`
+
+	// DocumentName 重复分块所在的文档名称
+	DocumentName string `column:"document_name" json:"documentName,omitempty"`
+
+	// DuplicateOf 相似的分块ID
+	DuplicateOf string `column:"duplicate_of" json:"duplicateOf,omitempty"`
+
+	// DuplicateOfDocumentID 相似分块所在的文档ID
+	DuplicateOfDocumentID string `column:"duplicate_of_document_id" json:"duplicateOfDocumentID,omitempty"`
+
+	// DuplicateOfDocumentName 相似分块所在的文档名称
+	DuplicateOfDocumentName string `column:"duplicate_of_document_name" json:"duplicateOfDocumentName,omitempty"`
+
+	// Similarity 相似度
+	Similarity float64 `column:"similarity" json:"similarity,omitempty"`
+
+	// Action 处理方式 drop 或者 link
+	Action string `column:"action" json:"action,omitempty"`
+
+	// Markdown 重复分块的内容摘要
+	Markdown string `column:"markdown" json:"markdown,omitempty"`
+
+	// CreateTime 创建时间
+	CreateTime string `column:"create_time" json:"createTime,omitempty"`
+}
+
+// GetTableName 获取表名称
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *DocumentChunkDuplicate) GetTableName() string {
+	return tableDocumentChunkDuplicateName
+}
+
+// GetPKColumnName 获取数据库表的主键字段名称.因为要兼容Map,只能是数据库的字段名称
+// 不支持联合主键,变通认为无主键,业务控制实现(艰难取舍)
+// 如果没有主键,也需要实现这个方法, return "" 即可
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *DocumentChunkDuplicate) GetPKColumnName() string {
+	return "id"
+}
+
+// DocumentChunkQuestion 大模型为分块生成的假设问题,问题的向量保存到向量表,检索时指向分块
+type DocumentChunkQuestion struct {
+	// 引入默认的struct,隔离IEntityStruct的方法改动
+	zorm.EntityStruct
+
+	// Id 问题ID,也是向量表中的ID
+	Id string `column:"id" json:"id,omitempty"`
+
+	// DocumentChunkID 问题对应的分块ID
+	DocumentChunkID string `column:"document_chunk_id" json:"documentChunkID,omitempty"`
+
+	// DocumentID 文档ID
+	DocumentID string `column:"document_id" json:"documentID,omitempty"`
+
+	// KnowledgeBaseID 知识库ID
+	KnowledgeBaseID string `column:"knowledge_base_id" json:"knowledgeBaseID,omitempty"`
+
+	// Question 问题
+	Question string `column:"question" json:"question,omitempty"`
+
+	// SortNo 排序,和分块的排序一致
+	SortNo int `column:"sortno" json:"sortno,omitempty"`
+
+	// CreateTime 创建时间
+	CreateTime string `column:"create_time" json:"createTime,omitempty"`
+}
+
+// GetTableName 获取表名称
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *DocumentChunkQuestion) GetTableName() string {
+	return tableDocumentChunkQuestionName
+}
+
+// GetPKColumnName 获取数据库表的主键字段名称.因为要兼容Map,只能是数据库的字段名称
+// 不支持联合主键,变通认为无主键,业务控制实现(艰难取舍)
+// 如果没有主键,也需要实现这个方法, return "" 即可
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *DocumentChunkQuestion) GetPKColumnName() string {
+	return "id"
+}
+
+// KnowledgeGraphNode 知识图谱的实体节点,同一个知识库中名称唯一,多个文档的同名实体是同一个节点
+type KnowledgeGraphNode struct {
+	// 引入默认的struct,隔离IEntityStruct的方法改动
+	zorm.EntityStruct
+
+	// Id 主键
+	Id string `column:"id" json:"id,omitempty"`
+
+	// KnowledgeBaseID 知识库ID
+	KnowledgeBaseID string `column:"knowledge_base_id" json:"knowledgeBaseID,omitempty"`
+
+	// Name 实体名称
+	Name string `column:"name" json:"name,omitempty"`
+
+	// Attributes 实体属性的json数组
+	Attributes string `column:"attributes" json:"attributes,omitempty"`
+
+	// CreateTime 创建时间
+	CreateTime string `column:"create_time" json:"createTime,omitempty"`
+
+	// UpdateTime 更新时间
+	UpdateTime string `column:"update_time" json:"updateTime,omitempty"`
+}
+
+// GetTableName 获取表名称
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *KnowledgeGraphNode) GetTableName() string {
+	return tableKnowledgeGraphNodeName
+}
+
+// GetPKColumnName 获取数据库表的主键字段名称.因为要兼容Map,只能是数据库的字段名称
+// 不支持联合主键,变通认为无主键,业务控制实现(艰难取舍)
+// 如果没有主键,也需要实现这个方法, return "" 即可
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *KnowledgeGraphNode) GetPKColumnName() string {
+	return "id"
+}
+
+// KnowledgeGraphEdge 知识图谱节点之间的关系,记录抽取关系的分块,删除文档时一起删除
+type KnowledgeGraphEdge struct {
+	// 引入默认的struct,隔离IEntityStruct的方法改动
+	zorm.EntityStruct
+
+	// Id 主键
+	Id string `column:"id" json:"id,omitempty"`
+
+	// KnowledgeBaseID 知识库ID
+	KnowledgeBaseID string `column:"knowledge_base_id" json:"knowledgeBaseID,omitempty"`
+
+	// DocumentID 文档ID
+	DocumentID string `column:"document_id" json:"documentID,omitempty"`
+
+	// DocumentChunkID 抽取关系的分块ID
+	DocumentChunkID string `column:"document_chunk_id" json:"documentChunkID,omitempty"`
+
+	// SourceNodeID 关系的起始节点ID
+	SourceNodeID string `column:"source_node_id" json:"sourceNodeID,omitempty"`
+
+	// TargetNodeID 关系的目标节点ID
+	TargetNodeID string `column:"target_node_id" json:"targetNodeID,omitempty"`
+
+	// Relation 关系类型
+	Relation string `column:"relation" json:"relation,omitempty"`
+
+	// CreateTime 创建时间
+	CreateTime string `column:"create_time" json:"createTime,omitempty"`
+}
+
+// GetTableName 获取表名称
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *KnowledgeGraphEdge) GetTableName() string {
+	return tableKnowledgeGraphEdgeName
+}
+
+// GetPKColumnName 获取数据库表的主键字段名称.因为要兼容Map,只能是数据库的字段名称
+// 不支持联合主键,变通认为无主键,业务控制实现(艰难取舍)
+// 如果没有主键,也需要实现这个方法, return "" 即可
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *KnowledgeGraphEdge) GetPKColumnName() string {
+	return "id"
+}
+
+// KnowledgeGraphNodeChunk 知识图谱节点关联的分块,检索到节点时返回对应的分块
+type KnowledgeGraphNodeChunk struct {
+	// 引入默认的struct,隔离IEntityStruct的方法改动
+	zorm.EntityStruct
+
+	// Id 主键
+	Id string `column:"id" json:"id,omitempty"`
+
+	// KnowledgeBaseID 知识库ID
+	KnowledgeBaseID string `column:"knowledge_base_id" json:"knowledgeBaseID,omitempty"`
+
+	// DocumentID 文档ID
+	DocumentID string `column:"document_id" json:"documentID,omitempty"`
+
+	// DocumentChunkID 分块ID
+	DocumentChunkID string `column:"document_chunk_id" json:"documentChunkID,omitempty"`
+
+	// NodeID 节点ID
+	NodeID string `column:"node_id" json:"nodeID,omitempty"`
+}
+
+// GetTableName 获取表名称
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *KnowledgeGraphNodeChunk) GetTableName() string {
+	return tableKnowledgeGraphNodeChunkName
+}
+
+// GetPKColumnName 获取数据库表的主键字段名称.因为要兼容Map,只能是数据库的字段名称
+// 不支持联合主键,变通认为无主键,业务控制实现(艰难取舍)
+// 如果没有主键,也需要实现这个方法, return "" 即可
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *KnowledgeGraphNodeChunk) GetPKColumnName() string {
+	return "id"
+}
+
+// DocumentVersion 文档的历史版本,有效期是 [ValidFrom,ValidTo)
+type DocumentVersion struct {
+	// 引入默认的struct,隔离IEntityStruct的方法改动
+	zorm.EntityStruct
+
+	// ID 版本ID
+	Id string `column:"id" json:"id,omitempty"`
+
+	// DocumentID 文档ID
+	DocumentID string `column:"document_id" json:"documentID,omitempty"`
+
+	// KnowledgeBaseID 知识库ID
+	KnowledgeBaseID string `column:"knowledge_base_id" json:"knowledgeBaseID,omitempty"`
+
+	// Name 文档名称
+	Name string `column:"name" json:"name,omitempty"`
+
+	// Markdown Markdown内容
+	Markdown string `column:"markdown" json:"markdown,omitempty"`
+
+	// FilePath 文件路径
+	FilePath string `column:"file_path" json:"filePath,omitempty"`
+
+	// Metadata 元数据
+	Metadata string `column:"metadata" json:"metadata,omitempty"`
+
+	// Version 版本号,从1开始
+	Version int `column:"version" json:"version,omitempty"`
+
+	// ValidFrom 生效时间
+	ValidFrom string `column:"valid_from" json:"validFrom,omitempty"`
+
+	// ValidTo 失效时间,也就是下一个版本的生效时间
+	ValidTo string `column:"valid_to" json:"validTo,omitempty"`
+
+	// CreateTime 创建时间
+	CreateTime string `column:"create_time" json:"createTime,omitempty"`
+}
+
+// GetTableName 获取表名称
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *DocumentVersion) GetTableName() string {
+	return tableDocumentVersionName
+}
+
+// GetPKColumnName 获取数据库表的主键字段名称.因为要兼容Map,只能是数据库的字段名称
+// 不支持联合主键,变通认为无主键,业务控制实现(艰难取舍)
+// 如果没有主键,也需要实现这个方法, return "" 即可
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *DocumentVersion) GetPKColumnName() string {
+	return "id"
+}
+
+// DocumentChunkVersion 历史版本的分块,保存向量用于按时间点检索
+type DocumentChunkVersion struct {
+	// 引入默认的struct,隔离IEntityStruct的方法改动
+	zorm.EntityStruct
+
+	// ID 分块ID
+	Id string `column:"id" json:"id,omitempty"`
+
+	// VersionID 文档版本ID
+	VersionID string `column:"version_id" json:"versionID,omitempty"`
+
+	// DocumentID 文档ID
+	DocumentID string `column:"document_id" json:"documentID,omitempty"`
+
+	// KnowledgeBaseID 知识库ID
+	KnowledgeBaseID string `column:"knowledge_base_id" json:"knowledgeBaseID,omitempty"`
+
+	// Title 标题
+	Title string `column:"title" json:"title,omitempty"`
+
+	// Markdown Markdown内容
+	Markdown string `column:"markdown" json:"markdown,omitempty"`
+
+	// Metadata 元数据
+	Metadata string `column:"metadata" json:"metadata,omitempty"`
+
+	// SortNo 排序
+	SortNo int `column:"sortno" json:"sortno,omitempty"`
+
+	// EmbeddingModel 向量模型,默认向量表为空字符串
+	EmbeddingModel string `column:"embedding_model" json:"embeddingModel,omitempty"`
+
+	// Embedding 向量的二进制
+	Embedding []byte `column:"embedding" json:"-"`
+
+	// ValidFrom 生效时间
+	ValidFrom string `column:"valid_from" json:"validFrom,omitempty"`
+
+	// ValidTo 失效时间
+	ValidTo string `column:"valid_to" json:"validTo,omitempty"`
+}
+
+// GetTableName 获取表名称
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *DocumentChunkVersion) GetTableName() string {
+	return tableDocumentChunkVersionName
+}
+
+// GetPKColumnName 获取数据库表的主键字段名称.因为要兼容Map,只能是数据库的字段名称
+// 不支持联合主键,变通认为无主键,业务控制实现(艰难取舍)
+// 如果没有主键,也需要实现这个方法, return "" 即可
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *DocumentChunkVersion) GetPKColumnName() string {
+	return "id"
+}
+
+// WebCrawlPage 网页抓取记录,保存ETag、Last-Modified和内容hash,重新抓取时只索引变化的网页
+type WebCrawlPage struct {
+	// 引入默认的struct,隔离IEntityStruct的方法改动
+	zorm.EntityStruct
+
+	// ID 和网页对应的文档ID相同
+	Id string `column:"id" json:"id,omitempty"`
+
+	// KnowledgeBaseID 知识库ID
+	KnowledgeBaseID string `column:"knowledge_base_id" json:"knowledgeBaseID,omitempty"`
+
+	// URL 网页地址
+	URL string `column:"url" json:"url,omitempty"`
+
+	// DocumentID 对应的文档ID
+	DocumentID string `column:"document_id" json:"documentID,omitempty"`
+
+	// ETag 响应头中的ETag
+	ETag string `column:"etag" json:"etag,omitempty"`
+
+	// LastModified 响应头中的Last-Modified
+	LastModified string `column:"last_modified" json:"lastModified,omitempty"`
+
+	// ContentHash 网页内容的sha256.索引失败时为空,下次抓取重新处理
+	ContentHash string `column:"content_hash" json:"contentHash,omitempty"`
+
+	// StatusCode 最近一次抓取的http状态码
+	StatusCode int `column:"status_code" json:"statusCode,omitempty"`
+
+	// CrawlTime 最近一次抓取的时间
+	CrawlTime string `column:"crawl_time" json:"crawlTime,omitempty"`
+}
+
+// GetTableName 获取表名称
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *WebCrawlPage) GetTableName() string {
+	return tableWebCrawlPageName
+}
+
+// GetPKColumnName 获取数据库表的主键字段名称.因为要兼容Map,只能是数据库的字段名称
+// 不支持联合主键,变通认为无主键,业务控制实现(艰难取舍)
+// 如果没有主键,也需要实现这个方法, return "" 即可
+// IEntityStruct 接口的方法,实体类需要实现!!!
+func (entity *WebCrawlPage) GetPKColumnName() string {
+	return "id"
+}
+
 // Site 站点信息
 type Site struct {
 	// 引入默认的struct,隔离IEntityStruct的方法改动
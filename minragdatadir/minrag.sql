@@ -11,6 +11,7 @@ CREATE TABLE IF NOT EXISTS config (
 		ai_base_url        TEXT,
 		ai_api_key         TEXT,
 		llm_model         TEXT,
+		embedding_cache_size INT,
 		create_time       TEXT,
 		update_time       TEXT,
 		create_user       TEXT,
@@ -35,6 +36,13 @@ CREATE TABLE IF NOT EXISTS knowledge_base (
 		name              TEXT  NOT NULL,
 		pid               TEXT,
         knowledge_base_type INT NOT NULL,
+		embedder_id        TEXT,
+		embedding_model    TEXT,
+		embedding_dimension INT,
+		migrate_embedder_id TEXT,
+		migrate_status     INT,
+		migrate_message    TEXT,
+		language           TEXT,
 		create_time        TEXT,
 		update_time        TEXT,
 		create_user        TEXT,
@@ -55,6 +63,9 @@ CREATE TABLE IF NOT EXISTS document (
 		file_path          TEXT,
 		file_size          INT,
 		file_ext           TEXT,
+		metadata           TEXT,
+		language           TEXT,
+		valid_from         TEXT,
 		create_time        TEXT,
 		update_time        TEXT,
 		create_user        TEXT,
@@ -72,6 +83,10 @@ CREATE TABLE IF NOT EXISTS document_chunk (
 		pre_id             TEXT,
 		next_id            TEXT,
 		level             INT,
+		metadata          TEXT,
+		duplicate_of      TEXT,
+		language          TEXT,
+		context           TEXT,
 		create_time        TEXT,
 		update_time        TEXT,
 		create_user        TEXT,
@@ -79,6 +94,135 @@ CREATE TABLE IF NOT EXISTS document_chunk (
 		status            INT NOT NULL
 	 ) strict ;
 
+CREATE TABLE IF NOT EXISTS document_version (
+		id TEXT PRIMARY KEY NOT NULL,
+		document_id        TEXT NOT NULL,
+		knowledge_base_id  TEXT,
+		name               TEXT,
+		markdown           TEXT,
+		file_path          TEXT,
+		metadata           TEXT,
+		version            INT NOT NULL,
+		valid_from         TEXT,
+		valid_to           TEXT NOT NULL,
+		create_time        TEXT
+	 ) strict ;
+CREATE INDEX IF NOT EXISTS idx_document_version_document_id ON document_version (document_id);
+
+CREATE TABLE IF NOT EXISTS document_chunk_version (
+		id TEXT PRIMARY KEY NOT NULL,
+		version_id         TEXT NOT NULL,
+		document_id        TEXT NOT NULL,
+		knowledge_base_id  TEXT NOT NULL,
+		title              TEXT,
+		markdown           TEXT,
+		metadata           TEXT,
+		sortno             INT,
+		embedding_model    TEXT,
+		embedding          BLOB,
+		valid_from         TEXT,
+		valid_to           TEXT NOT NULL
+	 ) strict ;
+CREATE INDEX IF NOT EXISTS idx_document_chunk_version_document_id ON document_chunk_version (document_id);
+CREATE INDEX IF NOT EXISTS idx_document_chunk_version_knowledge_base_id ON document_chunk_version (knowledge_base_id);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS fts_document_chunk_version USING fts5 (
+    id UNINDEXED,
+    document_id UNINDEXED,
+    knowledge_base_id UNINDEXED,
+	title,
+    markdown,
+    valid_from UNINDEXED,
+    valid_to UNINDEXED,
+    tokenize = 'simple 0',
+    content='document_chunk_version',
+    content_rowid='rowid'
+);
+
+CREATE TRIGGER trigger_document_chunk_version_insert AFTER INSERT ON document_chunk_version
+BEGIN
+    INSERT INTO fts_document_chunk_version(rowid, id, document_id, knowledge_base_id, title, markdown, valid_from, valid_to)
+    VALUES (new.rowid, new.id, new.document_id, new.knowledge_base_id, new.title, new.markdown, new.valid_from, new.valid_to);
+END;
+
+CREATE TRIGGER trigger_document_chunk_version_delete AFTER DELETE ON document_chunk_version
+BEGIN
+    INSERT INTO fts_document_chunk_version(fts_document_chunk_version, rowid, id, document_id, knowledge_base_id, title, markdown, valid_from, valid_to)
+    VALUES ('delete', old.rowid, old.id, old.document_id, old.knowledge_base_id, old.title, old.markdown, old.valid_from, old.valid_to);
+END;
+
+CREATE TABLE IF NOT EXISTS document_chunk_signature (
+		id TEXT PRIMARY KEY NOT NULL,
+		document_id        TEXT NOT NULL,
+		knowledge_base_id  TEXT NOT NULL,
+		algorithm          TEXT NOT NULL,
+		signature          TEXT NOT NULL
+	 ) strict ;
+CREATE INDEX IF NOT EXISTS idx_document_chunk_signature_knowledge_base_id ON document_chunk_signature (knowledge_base_id);
+CREATE INDEX IF NOT EXISTS idx_document_chunk_signature_document_id ON document_chunk_signature (document_id);
+
+CREATE TABLE IF NOT EXISTS document_chunk_duplicate (
+		id TEXT PRIMARY KEY NOT NULL,
+		knowledge_base_id  TEXT NOT NULL,
+		document_id        TEXT NOT NULL,
+		document_name      TEXT,
+		duplicate_of       TEXT NOT NULL,
+		duplicate_of_document_id TEXT NOT NULL,
+		duplicate_of_document_name TEXT,
+		similarity         REAL,
+		action             TEXT,
+		markdown           TEXT,
+		create_time        TEXT
+	 ) strict ;
+CREATE INDEX IF NOT EXISTS idx_document_chunk_duplicate_knowledge_base_id ON document_chunk_duplicate (knowledge_base_id);
+CREATE INDEX IF NOT EXISTS idx_document_chunk_duplicate_document_id ON document_chunk_duplicate (document_id);
+
+CREATE TABLE IF NOT EXISTS document_chunk_question (
+		id TEXT PRIMARY KEY NOT NULL,
+		document_chunk_id  TEXT NOT NULL,
+		document_id        TEXT NOT NULL,
+		knowledge_base_id  TEXT NOT NULL,
+		question           TEXT NOT NULL,
+		sortno             INT NOT NULL,
+		create_time        TEXT
+	 ) strict ;
+CREATE INDEX IF NOT EXISTS idx_document_chunk_question_document_id ON document_chunk_question (document_id);
+CREATE INDEX IF NOT EXISTS idx_document_chunk_question_document_chunk_id ON document_chunk_question (document_chunk_id);
+
+CREATE TABLE IF NOT EXISTS knowledge_graph_node (
+		id TEXT PRIMARY KEY NOT NULL,
+		knowledge_base_id  TEXT NOT NULL,
+		name               TEXT NOT NULL,
+		attributes         TEXT,
+		create_time        TEXT,
+		update_time        TEXT
+	 ) strict ;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_knowledge_graph_node_name ON knowledge_graph_node (knowledge_base_id,name);
+
+CREATE TABLE IF NOT EXISTS knowledge_graph_edge (
+		id TEXT PRIMARY KEY NOT NULL,
+		knowledge_base_id  TEXT NOT NULL,
+		document_id        TEXT NOT NULL,
+		document_chunk_id  TEXT NOT NULL,
+		source_node_id     TEXT NOT NULL,
+		target_node_id     TEXT NOT NULL,
+		relation           TEXT,
+		create_time        TEXT
+	 ) strict ;
+CREATE INDEX IF NOT EXISTS idx_knowledge_graph_edge_document_id ON knowledge_graph_edge (document_id);
+CREATE INDEX IF NOT EXISTS idx_knowledge_graph_edge_source_node_id ON knowledge_graph_edge (source_node_id);
+CREATE INDEX IF NOT EXISTS idx_knowledge_graph_edge_target_node_id ON knowledge_graph_edge (target_node_id);
+
+CREATE TABLE IF NOT EXISTS knowledge_graph_node_chunk (
+		id TEXT PRIMARY KEY NOT NULL,
+		knowledge_base_id  TEXT NOT NULL,
+		document_id        TEXT NOT NULL,
+		document_chunk_id  TEXT NOT NULL,
+		node_id            TEXT NOT NULL
+	 ) strict ;
+CREATE INDEX IF NOT EXISTS idx_knowledge_graph_node_chunk_document_id ON knowledge_graph_node_chunk (document_id);
+CREATE INDEX IF NOT EXISTS idx_knowledge_graph_node_chunk_node_id ON knowledge_graph_node_chunk (node_id);
+
 
 CREATE TABLE IF NOT EXISTS component (
 		id TEXT PRIMARY KEY NOT NULL,
@@ -129,6 +273,7 @@ CREATE TABLE IF NOT EXISTS agent (
 		welcome           TEXT,
 		tools             TEXT,
 		memory_length     INT,
+		metadata_filter   TEXT,
 		create_time       TEXT,
 		update_time       TEXT,
 		create_user       TEXT,
@@ -155,11 +300,91 @@ CREATE TABLE IF NOT EXISTS message_log (
 		knowledge_base_id  TEXT NOT NULL,
 		user_message       TEXT NOT NULL,
 		ai_message         TEXT NOT NULL,
+		citations          TEXT,
+		trace              TEXT,
 		user_id            TEXT,
 		create_time        TEXT NOT NULL
 	 ) strict ;
 
 
+CREATE TABLE IF NOT EXISTS embedding_cache (
+		id TEXT PRIMARY KEY NOT NULL,
+		model              TEXT NOT NULL,
+		base_url           TEXT NOT NULL,
+		text_hash          TEXT NOT NULL,
+		embedding          BLOB NOT NULL,
+		dimension          INT NOT NULL,
+		hit_count          INT NOT NULL,
+		create_time        TEXT,
+		update_time        TEXT
+	 ) strict ;
+CREATE INDEX IF NOT EXISTS idx_embedding_cache_update_time ON embedding_cache (update_time);
+
+CREATE TABLE IF NOT EXISTS folder_sync (
+		id TEXT PRIMARY KEY NOT NULL,
+		name               TEXT NOT NULL,
+		root_path          TEXT NOT NULL,
+		include_globs      TEXT,
+		exclude_globs      TEXT,
+		knowledge_base_id  TEXT NOT NULL,
+		sync_mode          INT,
+		sync_interval      INT,
+		sync_status        INT,
+		sync_message       TEXT,
+		last_sync_time     TEXT,
+		create_time        TEXT,
+		update_time        TEXT,
+		create_user        TEXT,
+		sortno             INT NOT NULL,
+		status             INT NOT NULL
+	 ) strict ;
+
+CREATE TABLE IF NOT EXISTS folder_sync_file (
+		id TEXT PRIMARY KEY NOT NULL,
+		folder_sync_id     TEXT NOT NULL,
+		file_path          TEXT NOT NULL,
+		document_id        TEXT NOT NULL,
+		file_size          INT,
+		mod_time           INT,
+		file_hash          TEXT,
+		update_time        TEXT
+	 ) strict ;
+CREATE INDEX IF NOT EXISTS idx_folder_sync_file_folder_sync_id ON folder_sync_file (folder_sync_id);
+
+CREATE TABLE IF NOT EXISTS git_sync (
+		id TEXT PRIMARY KEY NOT NULL,
+		name               TEXT NOT NULL,
+		repo_url           TEXT NOT NULL,
+		branch             TEXT,
+		extensions         TEXT,
+		knowledge_base_id  TEXT NOT NULL,
+		source_url_template TEXT,
+		last_commit        TEXT,
+		sync_interval      INT,
+		sync_status        INT,
+		sync_message       TEXT,
+		last_sync_time     TEXT,
+		create_time        TEXT,
+		update_time        TEXT,
+		create_user        TEXT,
+		sortno             INT NOT NULL,
+		status             INT NOT NULL
+	 ) strict ;
+
+CREATE TABLE IF NOT EXISTS web_crawl_page (
+		id TEXT PRIMARY KEY NOT NULL,
+		knowledge_base_id  TEXT NOT NULL,
+		url                TEXT NOT NULL,
+		document_id        TEXT NOT NULL,
+		etag               TEXT,
+		last_modified      TEXT,
+		content_hash       TEXT,
+		status_code        INT,
+		crawl_time         TEXT
+	 ) strict ;
+CREATE INDEX IF NOT EXISTS idx_web_crawl_page_knowledge_base_id ON web_crawl_page (knowledge_base_id);
+
+
 CREATE TABLE IF NOT EXISTS site (
 		id TEXT PRIMARY KEY NOT NULL,
 		title         TEXT NOT NULL,
@@ -189,6 +414,7 @@ CREATE VIRTUAL TABLE IF NOT EXISTS fts_document_chunk USING fts5 (
     knowledge_base_id UNINDEXED,
 	title,
     markdown,
+    context,
     sortno UNINDEXED,
     status UNINDEXED,
     tokenize = 'simple 0',
@@ -198,22 +424,56 @@ CREATE VIRTUAL TABLE IF NOT EXISTS fts_document_chunk USING fts5 (
 
 CREATE TRIGGER trigger_document_chunk_insert AFTER INSERT ON document_chunk
 BEGIN
-    INSERT INTO fts_document_chunk(rowid, id, document_id, knowledge_base_id, title, markdown, sortno, status)
-    VALUES (new.rowid,new.id, new.document_id, new.knowledge_base_id, new.title, new.markdown, new.sortno, new.status);
+    INSERT INTO fts_document_chunk(rowid, id, document_id, knowledge_base_id, title, markdown, context, sortno, status)
+    VALUES (new.rowid, new.id, new.document_id, new.knowledge_base_id, new.title, new.markdown, new.context, new.sortno, new.status);
 END;
 
 CREATE TRIGGER trigger_document_chunk_delete AFTER DELETE ON document_chunk
 BEGIN
-    INSERT INTO fts_document_chunk(fts_document_chunk, id, document_id, knowledge_base_id, title, markdown, sortno, status)
-    VALUES ('delete', old.id, old.document_id, old.knowledge_base_id, old.title, old.markdown, old.sortno, old.status);
+    INSERT INTO fts_document_chunk(fts_document_chunk, rowid, id, document_id, knowledge_base_id, title, markdown, context, sortno, status)
+    VALUES ('delete', old.rowid, old.id, old.document_id, old.knowledge_base_id, old.title, old.markdown, old.context, old.sortno, old.status);
 END;
 
 CREATE TRIGGER trigger_document_chunk_update AFTER UPDATE ON document_chunk
 BEGIN
-    INSERT INTO fts_document_chunk(fts_document_chunk, rowid, id, document_id, knowledge_base_id, title, markdown, sortno, status)
-    VALUES ('delete',old.rowid, old.id, old.document_id, old.knowledge_base_id, old.title, old.markdown, old.sortno, old.status);
-    INSERT INTO fts_document_chunk(rowid, id, document_id, knowledge_base_id, title, markdown, sortno, status)
-    VALUES (new.rowid, new.id, new.document_id, new.knowledge_base_id, new.title, new.markdown, new.sortno, new.status);
+    INSERT INTO fts_document_chunk(fts_document_chunk, rowid, id, document_id, knowledge_base_id, title, markdown, context, sortno, status)
+    VALUES ('delete', old.rowid, old.id, old.document_id, old.knowledge_base_id, old.title, old.markdown, old.context, old.sortno, old.status);
+    INSERT INTO fts_document_chunk(rowid, id, document_id, knowledge_base_id, title, markdown, context, sortno, status)
+    VALUES (new.rowid, new.id, new.document_id, new.knowledge_base_id, new.title, new.markdown, new.context, new.sortno, new.status);
+END;
+
+CREATE VIRTUAL TABLE IF NOT EXISTS fts_document_chunk_porter USING fts5 (
+    id UNINDEXED,
+    document_id UNINDEXED,
+    knowledge_base_id UNINDEXED,
+	title,
+    markdown,
+    context,
+    sortno UNINDEXED,
+    status UNINDEXED,
+    tokenize = 'porter unicode61 remove_diacritics 2',
+    content='document_chunk',
+    content_rowid='rowid'
+);
+
+CREATE TRIGGER trigger_document_chunk_porter_insert AFTER INSERT ON document_chunk WHEN new.language='en'
+BEGIN
+    INSERT INTO fts_document_chunk_porter(rowid, id, document_id, knowledge_base_id, title, markdown, context, sortno, status)
+    VALUES (new.rowid, new.id, new.document_id, new.knowledge_base_id, new.title, new.markdown, new.context, new.sortno, new.status);
+END;
+
+CREATE TRIGGER trigger_document_chunk_porter_delete AFTER DELETE ON document_chunk WHEN old.language='en'
+BEGIN
+    INSERT INTO fts_document_chunk_porter(fts_document_chunk_porter, rowid, id, document_id, knowledge_base_id, title, markdown, context, sortno, status)
+    VALUES ('delete', old.rowid, old.id, old.document_id, old.knowledge_base_id, old.title, old.markdown, old.context, old.sortno, old.status);
+END;
+
+CREATE TRIGGER trigger_document_chunk_porter_update AFTER UPDATE ON document_chunk
+BEGIN
+    INSERT INTO fts_document_chunk_porter(fts_document_chunk_porter, rowid, id, document_id, knowledge_base_id, title, markdown, context, sortno, status)
+    SELECT 'delete', old.rowid, old.id, old.document_id, old.knowledge_base_id, old.title, old.markdown, old.context, old.sortno, old.status WHERE old.language='en';
+    INSERT INTO fts_document_chunk_porter(rowid, id, document_id, knowledge_base_id, title, markdown, context, sortno, status)
+    SELECT new.rowid, new.id, new.document_id, new.knowledge_base_id, new.title, new.markdown, new.context, new.sortno, new.status WHERE new.language='en';
 END;
 
 
@@ -81,6 +81,8 @@ func init() {
 	adminGroup.POST("/upload", funcUploadFile)
 	//上传文档文件
 	adminGroup.POST("/document/uploadDocument", funcUploadDocument)
+	//导入文档压缩包
+	adminGroup.POST("/document/importZip", funcImportDocumentZip)
 	//上传主题文件
 	adminGroup.POST("/themeTemplate/uploadTheme", funcUploadTheme)
 
@@ -90,6 +92,16 @@ func init() {
 	adminGroup.GET("/themeTemplate/list", funcListThemeTemplate)
 	// 查询Document列表,根据KnowledgeBaseId like
 	adminGroup.GET("/document/list", funcDocumentList)
+	// 查询近似重复分块报告,根据KnowledgeBaseId like
+	adminGroup.GET("/document_chunk_duplicate/list", funcDocumentChunkDuplicateList)
+	// 查询知识图谱的节点,根据KnowledgeBaseId like
+	adminGroup.GET("/knowledge_graph_node/list", funcKnowledgeGraphNodeList)
+	// 查看知识图谱节点的关系和关联的分块
+	adminGroup.GET("/knowledge_graph_node/look", funcKnowledgeGraphNodeLook)
+	// 查询文档的历史版本
+	adminGroup.GET("/document_version/list", funcDocumentVersionList)
+	// 历史版本和下一个版本的差异
+	adminGroup.GET("/document_version/diff", funcDocumentVersionDiff)
 	// 查询Component列表
 	adminGroup.GET("/component/list", funcComponentList)
 	// 查询Agent列表
@@ -116,6 +128,10 @@ func init() {
 	adminGroup.POST("/agent/update", funcUpdateAgent)
 	// 修改ThemeTemplate
 	adminGroup.POST("/themeTemplate/update", funcUpdateThemeTemplate)
+	// 修改FolderSync
+	adminGroup.POST("/folder_sync/update", funcUpdateFolderSync)
+	// 修改GitSync
+	adminGroup.POST("/git_sync/update", funcUpdateGitSync)
 
 	//跳转到保存页面
 	adminGroup.GET("/:urlPathParam/save", funcSavePre)
@@ -127,11 +143,19 @@ func init() {
 	adminGroup.POST("/component/save", funcSaveComponent)
 	//保存Agent
 	adminGroup.POST("/agent/save", funcSaveAgent)
+	//保存FolderSync
+	adminGroup.POST("/folder_sync/save", funcSaveFolderSync)
+	//保存GitSync
+	adminGroup.POST("/git_sync/save", funcSaveGitSync)
 
 	//ajax POST删除数据
 	adminGroup.POST("/:urlPathParam/delete", funcDelete)
 	//ajax POST删除Document
 	adminGroup.POST("/document/delete", funcDeleteDocument)
+	//ajax POST删除FolderSync
+	adminGroup.POST("/folder_sync/delete", funcDeleteFolderSync)
+	//ajax POST删除GitSync
+	adminGroup.POST("/git_sync/delete", funcDeleteGitSync)
 
 	//ajax POST执行更新语句
 	adminGroup.POST("/updatesql", funcUpdateSQL)
@@ -139,6 +163,20 @@ func init() {
 	//ajax POST 抓取网页
 	adminGroup.POST("/webscraper", funcWebScraper)
 
+	//ajax POST 重新向量化知识库
+	adminGroup.POST("/knowledgeBase/migrate", funcMigrateKnowledgeBase)
+
+	//ajax POST 立即执行目录同步
+	adminGroup.POST("/folder_sync/sync", funcRunFolderSync)
+
+	//ajax POST 立即执行git同步
+	adminGroup.POST("/git_sync/sync", funcRunGitSync)
+
+	//ajax GET 向量缓存统计
+	adminGroup.GET("/embeddingCache/stats", funcEmbeddingCacheStats)
+	//ajax POST 清空向量缓存
+	adminGroup.POST("/embeddingCache/purge", funcPurgeEmbeddingCache)
+
 }
 
 // funcAdminInstallPre 跳转到安装界面
@@ -317,6 +355,51 @@ func funcUploadDocument(ctx context.Context, c *app.RequestContext) {
 	c.JSON(http.StatusOK, ResponseData{StatusCode: 1, Data: filePath})
 }
 
+// funcImportDocumentZip 导入文档压缩包,目录对应子知识库,返回导入,跳过和失败的文件清单
+func funcImportDocumentZip(ctx context.Context, c *app.RequestContext) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: err.Error()})
+		c.Abort() // 终止后续调用
+		return
+	}
+	if strings.ToLower(filepath.Ext(fileHeader.Filename)) != ".zip" {
+		c.JSON(http.StatusBadRequest, ResponseData{StatusCode: 0, Message: funcT("Only zip format is supported")})
+		c.Abort() // 终止后续调用
+		return
+	}
+	knowledgeBaseID := string(c.FormValue("knowledgeBaseID"))
+	if knowledgeBaseID == "" {
+		c.JSON(http.StatusBadRequest, ResponseData{StatusCode: 0, Message: funcT("Knowledge base cannot be empty")})
+		c.Abort() // 终止后续调用
+		return
+	}
+	err = os.MkdirAll(datadir+"upload/import", 0755)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: err.Error()})
+		c.Abort() // 终止后续调用
+		return
+	}
+	zipPath := datadir + "upload/import/" + FuncGenerateStringID() + ".zip"
+	err = c.SaveUploadedFile(fileHeader, zipPath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: err.Error()})
+		c.Abort() // 终止后续调用
+		return
+	}
+	defer func() {
+		_ = os.Remove(zipPath)
+	}()
+	manifest, err := importDocumentZip(ctx, zipPath, knowledgeBaseID, string(c.FormValue("extensions")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: err.Error(), Data: manifest})
+		c.Abort() // 终止后续调用
+		FuncLogError(ctx, err)
+		return
+	}
+	c.JSON(http.StatusOK, ResponseData{StatusCode: 1, Data: manifest})
+}
+
 // funcUploadFilePath 上传文件,返回文件的path路径
 func funcUploadFilePath(c *app.RequestContext, baseDir string) (string, string, error) {
 	fileHeader, err := c.FormFile("file")
@@ -464,6 +547,129 @@ func funcDocumentList(ctx context.Context, c *app.RequestContext) {
 	cHtmlAdmin(c, http.StatusOK, listFile, responseData)
 }
 
+// funcDocumentChunkDuplicateList 查询近似重复分块报告,根据KnowledgeBaseId like
+func funcDocumentChunkDuplicateList(ctx context.Context, c *app.RequestContext) {
+	urlPathParam := tableDocumentChunkDuplicateName
+	pageNo, _ := strconv.Atoi(c.DefaultQuery("pageNo", "1"))
+	id := strings.TrimSpace(c.Query("id"))
+	var responseData ResponseData
+	var err error
+	if id != "" {
+		responseData, err = funcSelectList(urlPathParam, "", pageNo, defaultPageSize, " * from document_chunk_duplicate where knowledge_base_id like ? order by create_time desc ", id+"%")
+	} else {
+		responseData, err = funcSelectList(urlPathParam, "", pageNo, defaultPageSize, " * from document_chunk_duplicate order by create_time desc ")
+	}
+	responseData.UrlPathParam = urlPathParam
+	if err != nil {
+		c.Redirect(http.StatusOK, cRedirecURI("admin/error"))
+		c.Abort() // 终止后续调用
+		return
+	}
+	listFile := "admin/" + urlPathParam + "/list.html"
+	cHtmlAdmin(c, http.StatusOK, listFile, responseData)
+}
+
+// funcKnowledgeGraphNodeList 查询知识图谱的节点,根据KnowledgeBaseId like,参数name模糊查询节点名称
+func funcKnowledgeGraphNodeList(ctx context.Context, c *app.RequestContext) {
+	urlPathParam := tableKnowledgeGraphNodeName
+	pageNo, _ := strconv.Atoi(c.DefaultQuery("pageNo", "1"))
+	id := strings.TrimSpace(c.Query("id"))
+	name := strings.TrimSpace(c.Query("name"))
+	responseData, err := funcSelectList(urlPathParam, "", pageNo, defaultPageSize, " * from knowledge_graph_node where knowledge_base_id like ? and name like ? order by update_time desc ", id+"%", "%"+name+"%")
+	responseData.UrlPathParam = urlPathParam
+	if err != nil {
+		c.Redirect(http.StatusOK, cRedirecURI("admin/error"))
+		c.Abort() // 终止后续调用
+		return
+	}
+	responseData.ExtMap = map[string]any{"name": name}
+	listFile := "admin/" + urlPathParam + "/list.html"
+	cHtmlAdmin(c, http.StatusOK, listFile, responseData)
+}
+
+// funcKnowledgeGraphNodeLook 查看知识图谱节点,显示节点的关系,相邻节点和关联的分块,点击相邻节点继续浏览
+func funcKnowledgeGraphNodeLook(ctx context.Context, c *app.RequestContext) {
+	urlPathParam := tableKnowledgeGraphNodeName
+	node := &KnowledgeGraphNode{}
+	finder := zorm.NewSelectFinder(tableKnowledgeGraphNodeName).Append("WHERE id=?", c.Query("id"))
+	has, err := zorm.QueryRow(ctx, finder, node)
+	if err != nil || !has {
+		c.Redirect(http.StatusOK, cRedirecURI("admin/error"))
+		c.Abort() // 终止后续调用
+		return
+	}
+	neighbors, err := findKnowledgeGraphNodeNeighbors(ctx, node.Id)
+	if err != nil {
+		c.Redirect(http.StatusOK, cRedirecURI("admin/error"))
+		c.Abort() // 终止后续调用
+		return
+	}
+	documentChunks, err := findKnowledgeGraphNodeDocumentChunks(ctx, node.Id)
+	if err != nil {
+		c.Redirect(http.StatusOK, cRedirecURI("admin/error"))
+		c.Abort() // 终止后续调用
+		return
+	}
+	responseData := ResponseData{StatusCode: 1, UrlPathParam: urlPathParam, Data: node}
+	responseData.ExtMap = map[string]any{"attributes": knowledgeGraphNodeAttributes(node), "neighbors": neighbors, "documentChunks": documentChunks}
+	cHtmlAdmin(c, http.StatusOK, "admin/"+urlPathParam+"/look.html", responseData)
+}
+
+// funcDocumentVersionList 查询文档的历史版本
+func funcDocumentVersionList(ctx context.Context, c *app.RequestContext) {
+	urlPathParam := tableDocumentVersionName
+	pageNo, _ := strconv.Atoi(c.DefaultQuery("pageNo", "1"))
+	documentID := strings.TrimSpace(c.Query("documentID"))
+	document := &Document{}
+	finder := zorm.NewSelectFinder(tableDocumentName, "id,name,knowledge_base_id,file_path,valid_from,create_time,update_time").Append("WHERE id=?", documentID)
+	has, err := zorm.QueryRow(ctx, finder, document)
+	if err != nil || !has {
+		c.Redirect(http.StatusOK, cRedirecURI("admin/error"))
+		c.Abort() // 终止后续调用
+		return
+	}
+	responseData, err := funcSelectList(urlPathParam, "", pageNo, defaultPageSize, " id,document_id,knowledge_base_id,name,file_path,version,valid_from,valid_to,create_time from document_version where document_id=? order by version desc ", documentID)
+	if err != nil {
+		c.Redirect(http.StatusOK, cRedirecURI("admin/error"))
+		c.Abort() // 终止后续调用
+		return
+	}
+	responseData.UrlPathParam = urlPathParam
+	responseData.ExtMap = map[string]any{"document": document}
+	cHtmlAdmin(c, http.StatusOK, "admin/"+urlPathParam+"/list.html", responseData)
+}
+
+// funcDocumentVersionDiff 比较历史版本和下一个版本,最后一个历史版本和当前版本比较
+func funcDocumentVersionDiff(ctx context.Context, c *app.RequestContext) {
+	urlPathParam := tableDocumentVersionName
+	version := &DocumentVersion{}
+	finder := zorm.NewSelectFinder(tableDocumentVersionName).Append("WHERE id=?", c.Query("id"))
+	has, err := zorm.QueryRow(ctx, finder, version)
+	if err != nil || !has {
+		c.Redirect(http.StatusOK, cRedirecURI("admin/error"))
+		c.Abort() // 终止后续调用
+		return
+	}
+	next := &DocumentVersion{}
+	finder = zorm.NewSelectFinder(tableDocumentVersionName).Append("WHERE document_id=? and version=?", version.DocumentID, version.Version+1)
+	has, err = zorm.QueryRow(ctx, finder, next)
+	if err == nil && !has {
+		document := &Document{}
+		finder = zorm.NewSelectFinder(tableDocumentName).Append("WHERE id=?", version.DocumentID)
+		has, err = zorm.QueryRow(ctx, finder, document)
+		next = &DocumentVersion{DocumentID: document.Id, Name: document.Name, Markdown: document.Markdown, ValidFrom: document.ValidFrom}
+	}
+	if err != nil || !has {
+		c.Redirect(http.StatusOK, cRedirecURI("admin/error"))
+		c.Abort() // 终止后续调用
+		return
+	}
+	responseData := ResponseData{StatusCode: 1, UrlPathParam: urlPathParam}
+	responseData.Data = diffLines(version.Markdown, next.Markdown)
+	responseData.ExtMap = map[string]any{"version": version, "next": next}
+	cHtmlAdmin(c, http.StatusOK, "admin/"+urlPathParam+"/diff.html", responseData)
+}
+
 // funcListThemeTemplate 所有的主题文件列表
 func funcListThemeTemplate(ctx context.Context, c *app.RequestContext) {
 	urlPathParam := "themeTemplate"
@@ -681,9 +887,174 @@ func funcUpdateKnowledgeBase(ctx context.Context, c *app.RequestContext) {
 	if !ok {
 		return
 	}
+	if !validLanguage(entity.Language) {
+		c.JSON(http.StatusBadRequest, ResponseData{StatusCode: 0, Message: funcT("Unsupported language")})
+		c.Abort() // 终止后续调用
+		return
+	}
+	// 向量化组件只能通过重新向量化修改,避免新旧向量混用
+	knowledgeBase, err := findKnowledgeBaseById(ctx, entity.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: funcT("ID does not exist")})
+		c.Abort() // 终止后续调用
+		FuncLogError(ctx, err)
+		return
+	}
+	entity.EmbedderID = knowledgeBase.EmbedderID
+	entity.EmbeddingModel = knowledgeBase.EmbeddingModel
+	entity.EmbeddingDimension = knowledgeBase.EmbeddingDimension
+	entity.MigrateEmbedderID = knowledgeBase.MigrateEmbedderID
+	entity.MigrateStatus = knowledgeBase.MigrateStatus
+	entity.MigrateMessage = knowledgeBase.MigrateMessage
 	funcUpdate(ctx, c, entity, entity.Id)
 }
 
+// funcMigrateKnowledgeBase 使用新的向量化组件,后台重新向量化知识库
+func funcMigrateKnowledgeBase(ctx context.Context, c *app.RequestContext) {
+	migrateMap := make(map[string]string, 0)
+	c.Bind(&migrateMap)
+	id := migrateMap["id"]
+	embedderID := migrateMap["embedderID"]
+	if id == "" || embedderID == "" {
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: funcT("ID cannot be empty")})
+		c.Abort() // 终止后续调用
+		return
+	}
+	err := startKnowledgeBaseMigration(ctx, id, embedderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: err.Error()})
+		c.Abort() // 终止后续调用
+		FuncLogError(ctx, err)
+		return
+	}
+	c.JSON(http.StatusOK, ResponseData{StatusCode: 1, Message: funcT("Re-embedding has started in the background")})
+}
+
+// funcUpdateFolderSync 更新目录同步,并重新开启后台任务
+func funcUpdateFolderSync(ctx context.Context, c *app.RequestContext) {
+	entity := &FolderSync{}
+	ok := funcUpdateInit(ctx, c, entity)
+	if !ok {
+		return
+	}
+	err := validateFolderSync(ctx, entity)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ResponseData{StatusCode: 0, Message: err.Error()})
+		c.Abort() // 终止后续调用
+		return
+	}
+	entity.UpdateTime = time.Now().Format("2006-01-02 15:04:05")
+	_, err = zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		return zorm.Update(ctx, entity)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: funcT("Failed to update data")})
+		c.Abort() // 终止后续调用
+		FuncLogError(ctx, err)
+		return
+	}
+	startFolderSync(context.Background(), entity)
+	c.JSON(http.StatusOK, ResponseData{StatusCode: 1, UrlPathParam: tableFolderSyncName})
+}
+
+// funcRunFolderSync 后台立即执行一次目录同步
+func funcRunFolderSync(ctx context.Context, c *app.RequestContext) {
+	id := c.PostForm("id")
+	folderSync, err := findFolderSyncById(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: err.Error()})
+		c.Abort() // 终止后续调用
+		return
+	}
+	go runFolderSync(context.Background(), folderSync.Id)
+	c.JSON(http.StatusOK, ResponseData{StatusCode: 1, Message: funcT("Folder sync has started in the background")})
+}
+
+// funcDeleteFolderSync 删除目录同步,已经同步的文档保留在知识库中
+func funcDeleteFolderSync(ctx context.Context, c *app.RequestContext) {
+	id := c.PostForm("id")
+	if id == "" { //没有id,终止调用
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: funcT("ID cannot be empty")})
+		c.Abort() // 终止后续调用
+		return
+	}
+	err := deleteFolderSync(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: funcT("Failed to delete data")})
+		c.Abort() // 终止后续调用
+		FuncLogError(ctx, err)
+		return
+	}
+	c.JSON(http.StatusOK, ResponseData{StatusCode: 1, Message: funcT("Data deleted successfully")})
+}
+
+// funcUpdateGitSync 更新git同步,仓库地址或者分支修改后重新全量索引,并重新开启后台任务
+func funcUpdateGitSync(ctx context.Context, c *app.RequestContext) {
+	entity := &GitSync{}
+	ok := funcUpdateInit(ctx, c, entity)
+	if !ok {
+		return
+	}
+	err := validateGitSync(ctx, entity)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ResponseData{StatusCode: 0, Message: err.Error()})
+		c.Abort() // 终止后续调用
+		return
+	}
+	old, err := findGitSyncById(ctx, entity.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: err.Error()})
+		c.Abort() // 终止后续调用
+		return
+	}
+	if old.RepoURL != entity.RepoURL || old.Branch != entity.Branch {
+		entity.LastCommit = ""
+	}
+	entity.UpdateTime = time.Now().Format("2006-01-02 15:04:05")
+	_, err = zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		return zorm.Update(ctx, entity)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: funcT("Failed to update data")})
+		c.Abort() // 终止后续调用
+		FuncLogError(ctx, err)
+		return
+	}
+	startGitSync(context.Background(), entity)
+	c.JSON(http.StatusOK, ResponseData{StatusCode: 1, UrlPathParam: tableGitSyncName})
+}
+
+// funcRunGitSync 后台立即执行一次git同步
+func funcRunGitSync(ctx context.Context, c *app.RequestContext) {
+	id := c.PostForm("id")
+	gitSync, err := findGitSyncById(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: err.Error()})
+		c.Abort() // 终止后续调用
+		return
+	}
+	go runGitSync(context.Background(), gitSync.Id)
+	c.JSON(http.StatusOK, ResponseData{StatusCode: 1, Message: funcT("Git sync has started in the background")})
+}
+
+// funcDeleteGitSync 删除git同步,已经同步的文档保留在知识库中
+func funcDeleteGitSync(ctx context.Context, c *app.RequestContext) {
+	id := c.PostForm("id")
+	if id == "" { //没有id,终止调用
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: funcT("ID cannot be empty")})
+		c.Abort() // 终止后续调用
+		return
+	}
+	err := deleteGitSync(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: funcT("Failed to delete data")})
+		c.Abort() // 终止后续调用
+		FuncLogError(ctx, err)
+		return
+	}
+	c.JSON(http.StatusOK, ResponseData{StatusCode: 1, Message: funcT("Data deleted successfully")})
+}
+
 // funcUpdateDocument 更新内容
 func funcUpdateDocument(ctx context.Context, c *app.RequestContext) {
 	entity := &Document{}
@@ -691,6 +1062,16 @@ func funcUpdateDocument(ctx context.Context, c *app.RequestContext) {
 	if !ok {
 		return
 	}
+	if !validLanguage(entity.Language) {
+		c.JSON(http.StatusBadRequest, ResponseData{StatusCode: 0, Message: funcT("Unsupported language")})
+		c.Abort() // 终止后续调用
+		return
+	}
+	if err := validateDocumentMetadata(entity.Metadata); err != nil {
+		c.JSON(http.StatusBadRequest, ResponseData{StatusCode: 0, Message: err.Error()})
+		c.Abort() // 终止后续调用
+		return
+	}
 	now := time.Now().Format("2006-01-02 15:04:05")
 	entity.UpdateTime = now
 	go updateDocumentChunk(ctx, entity)
@@ -727,6 +1108,11 @@ func funcUpdateAgent(ctx context.Context, c *app.RequestContext) {
 	if !ok {
 		return
 	}
+	if _, _, err := metadataFilterSQL(entity.MetadataFilter, "metadata"); err != nil {
+		c.JSON(http.StatusBadRequest, ResponseData{StatusCode: 0, Message: funcT("Metadata filter error") + ":" + err.Error()})
+		c.Abort() // 终止后续调用
+		return
+	}
 	_, err := zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
 		return zorm.Update(ctx, entity)
 	})
@@ -802,6 +1188,11 @@ func funcSaveKnowledgeBase(ctx context.Context, c *app.RequestContext) {
 		FuncLogError(ctx, err)
 		return
 	}
+	if !validLanguage(entity.Language) {
+		c.JSON(http.StatusBadRequest, ResponseData{StatusCode: 0, Message: funcT("Unsupported language")})
+		c.Abort() // 终止后续调用
+		return
+	}
 	now := time.Now().Format("2006-01-02 15:04:05")
 	if entity.CreateTime == "" {
 		entity.CreateTime = now
@@ -820,6 +1211,14 @@ func funcSaveKnowledgeBase(ctx context.Context, c *app.RequestContext) {
 		c.Abort() // 终止后续调用
 		return
 	}
+	// 记录向量化组件的模型和维度
+	err = initKnowledgeBaseEmbedder(ctx, entity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: err.Error()})
+		c.Abort() // 终止后续调用
+		FuncLogError(ctx, err)
+		return
+	}
 	count, err := zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
 		return zorm.Insert(ctx, entity)
 	})
@@ -833,6 +1232,75 @@ func funcSaveKnowledgeBase(ctx context.Context, c *app.RequestContext) {
 	c.JSON(http.StatusOK, ResponseData{StatusCode: count.(int), Message: funcT("Saved successfully!")})
 }
 
+// funcSaveFolderSync 保存目录同步,并开启后台任务
+func funcSaveFolderSync(ctx context.Context, c *app.RequestContext) {
+	entity := &FolderSync{}
+	err := c.Bind(entity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: funcT("JSON data conversion error")})
+		c.Abort() // 终止后续调用
+		FuncLogError(ctx, err)
+		return
+	}
+	err = validateFolderSync(ctx, entity)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ResponseData{StatusCode: 0, Message: err.Error()})
+		c.Abort() // 终止后续调用
+		return
+	}
+	now := time.Now().Format("2006-01-02 15:04:05")
+	entity.Id = FuncGenerateStringID()
+	entity.SyncStatus = folderSyncStatusIdle
+	entity.CreateTime = now
+	entity.UpdateTime = now
+	count, err := zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		return zorm.Insert(ctx, entity)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: funcT("Failed to save data")})
+		c.Abort() // 终止后续调用
+		FuncLogError(ctx, err)
+		return
+	}
+	startFolderSync(context.Background(), entity)
+	c.JSON(http.StatusOK, ResponseData{StatusCode: count.(int), Message: funcT("Saved successfully!")})
+}
+
+// funcSaveGitSync 保存git同步,并开启后台任务
+func funcSaveGitSync(ctx context.Context, c *app.RequestContext) {
+	entity := &GitSync{}
+	err := c.Bind(entity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: funcT("JSON data conversion error")})
+		c.Abort() // 终止后续调用
+		FuncLogError(ctx, err)
+		return
+	}
+	err = validateGitSync(ctx, entity)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ResponseData{StatusCode: 0, Message: err.Error()})
+		c.Abort() // 终止后续调用
+		return
+	}
+	now := time.Now().Format("2006-01-02 15:04:05")
+	entity.Id = FuncGenerateStringID()
+	entity.LastCommit = ""
+	entity.SyncStatus = folderSyncStatusIdle
+	entity.CreateTime = now
+	entity.UpdateTime = now
+	count, err := zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		return zorm.Insert(ctx, entity)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: funcT("Failed to save data")})
+		c.Abort() // 终止后续调用
+		FuncLogError(ctx, err)
+		return
+	}
+	startGitSync(context.Background(), entity)
+	c.JSON(http.StatusOK, ResponseData{StatusCode: count.(int), Message: funcT("Saved successfully!")})
+}
+
 // funcSaveDocument 保存内容
 func funcSaveDocument(ctx context.Context, c *app.RequestContext) {
 	entity := &Document{}
@@ -843,6 +1311,11 @@ func funcSaveDocument(ctx context.Context, c *app.RequestContext) {
 		FuncLogError(ctx, err)
 		return
 	}
+	if err := validateDocumentMetadata(entity.Metadata); err != nil {
+		c.JSON(http.StatusBadRequest, ResponseData{StatusCode: 0, Message: err.Error()})
+		c.Abort() // 终止后续调用
+		return
+	}
 	now := time.Now().Format("2006-01-02 15:04:05")
 	// 构建ID
 	entity.Id = entity.KnowledgeBaseID + entity.Id
@@ -909,6 +1382,11 @@ func funcSaveAgent(ctx context.Context, c *app.RequestContext) {
 		FuncLogError(ctx, err)
 		return
 	}
+	if _, _, err := metadataFilterSQL(entity.MetadataFilter, "metadata"); err != nil {
+		c.JSON(http.StatusBadRequest, ResponseData{StatusCode: 0, Message: funcT("Metadata filter error") + ":" + err.Error()})
+		c.Abort() // 终止后续调用
+		return
+	}
 	now := time.Now().Format("2006-01-02 15:04:05")
 	entity.CreateTime = now
 	entity.UpdateTime = now
@@ -996,6 +1474,24 @@ func funcUpdateSQL(ctx context.Context, c *app.RequestContext) {
 	c.JSON(http.StatusOK, ResponseData{StatusCode: 1, Message: fmt.Sprintf(funcT("Updated %d records"), count)})
 }
 
+// funcEmbeddingCacheStats 向量缓存的统计信息
+func funcEmbeddingCacheStats(ctx context.Context, c *app.RequestContext) {
+	stats := findEmbeddingCacheStats(ctx)
+	c.JSON(http.StatusOK, ResponseData{StatusCode: 1, Data: stats})
+}
+
+// funcPurgeEmbeddingCache 清空向量缓存
+func funcPurgeEmbeddingCache(ctx context.Context, c *app.RequestContext) {
+	err := purgeEmbeddingCache(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseData{StatusCode: 0, Message: funcT("Failed to delete data")})
+		c.Abort() // 终止后续调用
+		FuncLogError(ctx, err)
+		return
+	}
+	c.JSON(http.StatusOK, ResponseData{StatusCode: 1, Message: funcT("Data deleted successfully")})
+}
+
 // funcWebScraper 抓取网页
 func funcWebScraper(ctx context.Context, c *app.RequestContext) {
 	webScraper := &WebScraper{}
@@ -1008,6 +1504,24 @@ func funcWebScraper(ctx context.Context, c *app.RequestContext) {
 		FuncLogError(ctx, err)
 		return
 	}
+	// sitemap抓取模式
+	if webScraper.SitemapURL != "" {
+		go func() {
+			result, err := crawlSitemap(context.Background(), webScraper)
+			if err != nil {
+				FuncLogError(ctx, err)
+			} else if result.Failed > 0 {
+				FuncLogError(ctx, fmt.Errorf("crawl sitemap %s: %s", webScraper.SitemapURL, result.String()))
+			}
+		}()
+		c.JSON(http.StatusOK, ResponseData{StatusCode: 1, Message: funcT("Sitemap crawling has started in the background")})
+		return
+	}
+	if webScraper.WebURL == "" {
+		c.JSON(http.StatusBadRequest, ResponseData{StatusCode: 0, Message: funcT("The webScraper_webURL of WebScraper cannot be empty")})
+		c.Abort() // 终止后续调用
+		return
+	}
 	webScraperDocuments := make([]Document, 0)
 	webScraperHrefMap := make(map[string]bool, 0)
 	webScraperHrefMap[""] = true
@@ -1023,29 +1537,11 @@ func funcWebScraper(ctx context.Context, c *app.RequestContext) {
 				continue
 			}
 			doc.SortNo = maxSortNo + i
-			input := make(map[string]any, 0)
-			input["document"] = &doc
-			//清洗html标签
-			hc := &HtmlCleaner{}
-			hc.Initialization(ctx, input)
-			err := hc.Run(ctx, input)
-			if err != nil {
-				continue
-			}
 			doc.CreateTime = now
-			doc.UpdateTime = now
-			f := zorm.NewSelectFinder(tableKnowledgeBaseName, "name as knowledge_base_name").Append(" where id =?", doc.KnowledgeBaseID)
-			zorm.QueryRow(ctx, f, &doc)
-			_, err = zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
-				zorm.Delete(ctx, &doc) //先删除
-				return zorm.Insert(ctx, &doc)
-			})
+			err := saveWebScraperDocument(ctx, &doc)
 			if err != nil {
 				FuncLogError(ctx, err)
 			}
-
-			// 文档分块,分析处理
-			updateDocumentChunk(ctx, &doc)
 		}
 	}()
 
@@ -1083,6 +1579,7 @@ func recursiveScraper(ctx context.Context, documents *[]Document, webScraperHref
 		ws.KnowledgeBaseID = webScraper.KnowledgeBaseID
 		ws.UserAgent = webScraper.UserAgent
 		ws.QuerySelector = webScraper.QuerySelector
+		ws.FetchMode = webScraper.FetchMode
 		recursiveScraper(ctx, documents, webScraperHrefs, ws)
 	}
 
@@ -45,6 +45,8 @@ func init() {
 
 	// 兼容OpenAI模型接口,api_key是agentID,user是conversationID
 	h.POST("/v1/chat/completions", funcChatCompletions)
+	// 只检索不生成的接口,作为搜索后端使用
+	h.POST("/v1/retrieve", funcRetrieve)
 }
 
 // funcIndex 模板首页
@@ -66,6 +68,41 @@ func funcAgentPre(ctx context.Context, c *app.RequestContext) {
 	cHtml(c, http.StatusOK, "agent.html", data)
 }
 
+// funcRetrieve 检索接口,只运行检索和重排组件,返回带分数的文档片段.鉴权方式和chat接口一致,api_key是agentID
+func funcRetrieve(ctx context.Context, c *app.RequestContext) {
+	agentID := strings.TrimPrefix(string(c.GetHeader("Authorization")), "Bearer ")
+	if agentID == "" {
+		c.JSON(http.StatusUnauthorized, ResponseData{StatusCode: 0, Message: "Authorization is empty"})
+		c.Abort()
+		return
+	}
+	retrieveRequestBody := &RetrieveRequestBody{}
+	err := c.BindJSON(retrieveRequestBody)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ResponseData{StatusCode: 0, Message: fmt.Sprintf("body is error:%v", err)})
+		c.Abort()
+		return
+	}
+	if retrieveRequestBody.Query == "" {
+		c.JSON(http.StatusBadRequest, ResponseData{StatusCode: 0, Message: "query is empty"})
+		c.Abort()
+		return
+	}
+	agent, err := findAgentByID(ctx, agentID)
+	if err != nil || agent.Id == "" {
+		c.JSON(http.StatusUnauthorized, ResponseData{StatusCode: 0, Message: "Authorization is error"})
+		c.Abort()
+		return
+	}
+	results, err := retrieveDocumentChunks(ctx, agent, retrieveRequestBody)
+	if err != nil {
+		c.JSON(http.StatusOK, ResponseData{StatusCode: 0, Message: err.Error()})
+		c.Abort()
+		return
+	}
+	c.JSON(http.StatusOK, ResponseData{StatusCode: 1, Data: results})
+}
+
 // funcChatCompletions 兼容OpenAI模型接口,api_key是agentID,user是conversationID
 func funcChatCompletions(ctx context.Context, c *app.RequestContext) {
 
@@ -172,6 +209,27 @@ func funcChatCompletions(ctx context.Context, c *app.RequestContext) {
 	}
 
 	input["knowledgeBaseID"] = agent.KnowledgeBaseID
+	// 元数据过滤,请求的过滤表达式只能进一步缩小智能体的检索范围
+	metadataFilter, err := joinMetadataFilter(agent.MetadataFilter, agentRequestBody.MetadataFilter)
+	if err != nil {
+		if stream {
+			c.WriteString(warpOpenAIJsonMessage(stream, err.Error()))
+			c.Flush()
+			c.WriteString("data: [DONE]\n\n")
+			c.Flush()
+		} else {
+			c.WriteString(err.Error())
+			c.Flush()
+		}
+		c.Abort()
+		return
+	}
+	if metadataFilter != "" {
+		input["metadataFilter"] = metadataFilter
+	}
+	if agentRequestBody.AsOf != "" {
+		input["asOf"] = agentRequestBody.AsOf
+	}
 	//查找流水线
 	pipeline, err := findPipelineById(ctx, agent.PipelineID, input)
 	if err != nil {
@@ -29,6 +29,10 @@ type AgentRequestBody struct {
 	Messages []ChatMessage `json:"messages,omitempty"`
 	Stream   bool          `json:"stream,omitempty"`
 	User     string        `json:"user,omitempty"`
+	// MetadataFilter 本次请求的元数据过滤表达式,和智能体的过滤表达式同时生效
+	MetadataFilter string `json:"metadata_filter,omitempty"`
+	// AsOf 本次请求检索的时间点,例如 2025-03-01,检索这个时间点有效的文档版本
+	AsOf string `json:"as_of,omitempty"`
 }
 
 // findAllAgentList 查询所有的智能体
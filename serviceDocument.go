@@ -45,11 +45,77 @@ func updateDocumentChunk(ctx context.Context, document *Document) (bool, error)
 	if has || errObj != nil {
 		return false, errObj.(error)
 	}
+	// 分块ID已经重新生成,重新索引去重时指向这个文档的其他文档
+	reindexDuplicateDocuments(ctx, document.Id)
 
 	return true, nil
 
 }
 
+// indexDocumentsInBackground 后台依次执行indexPipeline,避免同时调用大量的模型接口,处理失败的文档标记为处理失败
+func indexDocumentsInBackground(documents []*Document) {
+	if len(documents) < 1 {
+		return
+	}
+	go func() {
+		ctx := context.Background()
+		for _, document := range documents {
+			_, err := updateDocumentChunk(ctx, document)
+			if err == nil {
+				continue
+			}
+			FuncLogError(ctx, err)
+			// 和上传文档一样,标记为处理失败
+			zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+				finder := zorm.NewUpdateFinder(tableDocumentName).Append("status=3 WHERE id=?", document.Id)
+				return zorm.UpdateFinder(ctx, finder)
+			})
+		}
+	}()
+}
+
+// reindexedDocumentsContextKey 级联重新索引时已经处理过的文档ID,避免互相重复的文档循环索引
+type reindexedDocumentsContextKey struct{}
+
+// reindexDuplicateDocuments 文档重新索引或者删除后,重新索引分块重复指向这个文档的其他文档.
+// 被丢弃的重复分块重新和现有分块比较,不再重复时恢复;关联的分块指向新的分块ID
+func reindexDuplicateDocuments(ctx context.Context, documentID string) {
+	reindexed, _ := ctx.Value(reindexedDocumentsContextKey{}).(map[string]bool)
+	if reindexed == nil {
+		reindexed = make(map[string]bool)
+		ctx = context.WithValue(ctx, reindexedDocumentsContextKey{}, reindexed)
+	}
+	reindexed[documentID] = true
+
+	finder := zorm.NewSelectFinder(tableDocumentChunkDuplicateName, "DISTINCT document_id").Append("WHERE duplicate_of_document_id=? and document_id!=?", documentID, documentID)
+	documentIDs := make([]string, 0)
+	err := zorm.Query(ctx, finder, &documentIDs, nil)
+	if err != nil {
+		FuncLogError(ctx, err)
+		return
+	}
+	for _, id := range documentIDs {
+		if reindexed[id] {
+			continue
+		}
+		reindexed[id] = true
+		document := &Document{}
+		f := zorm.NewSelectFinder(tableDocumentName).Append("WHERE id=?", id)
+		has, err := zorm.QueryRow(ctx, f, document)
+		if err != nil {
+			FuncLogError(ctx, err)
+			continue
+		}
+		if !has {
+			continue
+		}
+		_, err = updateDocumentChunk(ctx, document)
+		if err != nil {
+			FuncLogError(ctx, err)
+		}
+	}
+}
+
 // findDocumentIdByFilePath 根据文档路径查询文档ID
 func findDocumentIdByFilePath(ctx context.Context, filePath string) (string, error) {
 	finder := zorm.NewSelectFinder(tableDocumentName, "id").Append("WHERE file_path=?", filePath)
@@ -74,7 +140,37 @@ func findDocumentChunkMarkDown(ctx context.Context, documentChunks []DocumentChu
 	return documentChunks, nil
 }
 
-// funcDeleteDocumentById 根据文档ID删除 Document,DocumentChunk,VecDocumentChunk
+// filterDocumentChunkMetadata 使用元数据过滤表达式过滤DocumentChunk,只保留匹配的分块,保持原有顺序
+func filterDocumentChunkMetadata(ctx context.Context, documentChunks []DocumentChunk, metadataFilter string) ([]DocumentChunk, error) {
+	whereSQL, whereValues, err := metadataFilterSQL(metadataFilter, "metadata")
+	if err != nil || whereSQL == "" || len(documentChunks) < 1 {
+		return documentChunks, err
+	}
+	ids := make([]string, 0, len(documentChunks))
+	for i := 0; i < len(documentChunks); i++ {
+		ids = append(ids, documentChunks[i].Id)
+	}
+	finder := zorm.NewSelectFinder(tableDocumentChunkName, "id").Append("WHERE id IN (?)", ids)
+	finder.Append(" and ("+whereSQL+")", whereValues...)
+	matchIDs := make([]string, 0)
+	err = zorm.Query(ctx, finder, &matchIDs, nil)
+	if err != nil {
+		return documentChunks, err
+	}
+	matchMap := make(map[string]bool, len(matchIDs))
+	for _, id := range matchIDs {
+		matchMap[id] = true
+	}
+	resultDCS := make([]DocumentChunk, 0, len(matchIDs))
+	for i := 0; i < len(documentChunks); i++ {
+		if matchMap[documentChunks[i].Id] {
+			resultDCS = append(resultDCS, documentChunks[i])
+		}
+	}
+	return resultDCS, nil
+}
+
+// funcDeleteDocumentById 根据文档ID删除 Document,DocumentChunk,VecDocumentChunk,去重记录,历史版本,知识图谱和邮件线程文档
 func funcDeleteDocumentById(ctx context.Context, id string) error {
 	_, err := zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
 		f1 := zorm.NewDeleteFinder(tableDocumentName).Append("WHERE id=?", id)
@@ -87,10 +183,43 @@ func funcDeleteDocumentById(ctx context.Context, id string) error {
 		if err != nil {
 			return count, err
 		}
-		f3 := zorm.NewDeleteFinder(tableVecDocumentChunkName).Append("WHERE document_id=?", id)
-		return zorm.UpdateFinder(ctx, f3)
+		// 删除分块的去重签名,重复记录和问题
+		for _, tableName := range []string{tableDocumentChunkSignatureName, tableDocumentChunkDuplicateName, tableDocumentChunkQuestionName} {
+			f3 := zorm.NewDeleteFinder(tableName).Append("WHERE document_id=?", id)
+			count, err = zorm.UpdateFinder(ctx, f3)
+			if err != nil {
+				return count, err
+			}
+		}
+		// 删除历史版本
+		err = deleteDocumentVersions(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		// 删除知识图谱的关系和节点关联
+		err = deleteDocumentGraph(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return nil, deleteVecDocumentChunk(ctx, id)
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	// 重复分块指向被删除文档的其他文档重新索引,恢复被丢弃的分块
+	go reindexDuplicateDocuments(context.WithoutCancel(ctx), id)
+	// 删除邮件归档生成的线程文档
+	threadIDs, err := findEmailThreadDocumentIDs(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, threadID := range threadIDs {
+		err = funcDeleteDocumentById(ctx, threadID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // runeLength 计算字符串的符文长度（一个中文汉字长度算1）
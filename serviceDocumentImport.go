@@ -0,0 +1,251 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gitee.com/chunanyong/zorm"
+)
+
+const (
+	// defaultDocumentImportExtensions 压缩包导入默认支持的文件后缀
+	defaultDocumentImportExtensions = ".md,.markdown,.txt,.csv,.json,.xml,.html,.htm,.pdf,.doc,.docx,.xls,.xlsx,.ppt,.pptx,.eml,.mbox"
+	// maxDocumentImportEntries 压缩包最多的文件数量
+	maxDocumentImportEntries = 10000
+	// maxDocumentImportFileSize 单个文件解压后的最大字节数,和上传文档的限制一致
+	maxDocumentImportFileSize = 100 << 20
+	// maxDocumentImportTotalSize 压缩包解压后的最大总字节数
+	maxDocumentImportTotalSize = 2 << 30
+	// maxDocumentImportRatio 单个文件的最大压缩比,超过认为是zip炸弹
+	maxDocumentImportRatio = 100
+	// documentImportRatioMinSize 小于这个大小的文件不检查压缩比
+	documentImportRatioMinSize = 1 << 20
+)
+
+// documentImportEntry 压缩包中一个文件的导入结果
+type documentImportEntry struct {
+	Path            string `json:"path"`
+	KnowledgeBaseID string `json:"knowledgeBaseID,omitempty"`
+	DocumentID      string `json:"documentID,omitempty"`
+	Size            int64  `json:"size,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+}
+
+// documentImportManifest 压缩包的导入清单
+type documentImportManifest struct {
+	Imported []documentImportEntry `json:"imported"`
+	Skipped  []documentImportEntry `json:"skipped"`
+	Failed   []documentImportEntry `json:"failed"`
+}
+
+// importDocumentZip 导入压缩包到知识库,目录对应子知识库,每个支持的文件新增或者更新一个文档.
+// 文件解压到 upload/知识库ID/ 目录,和上传文档的路径一致,重复导入时更新文档.文档在后台依次执行indexPipeline
+func importDocumentZip(ctx context.Context, zipPath string, knowledgeBaseID string, extensions string) (*documentImportManifest, error) {
+	manifest := &documentImportManifest{
+		Imported: make([]documentImportEntry, 0),
+		Skipped:  make([]documentImportEntry, 0),
+		Failed:   make([]documentImportEntry, 0),
+	}
+	knowledgeBaseName, err := findKnowledgeBaseNameById(ctx, knowledgeBaseID)
+	if err != nil {
+		return manifest, err
+	}
+	if knowledgeBaseName == "" {
+		return manifest, errors.New(funcT("Knowledge base does not exist"))
+	}
+	if extensions == "" {
+		extensions = defaultDocumentImportExtensions
+	}
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return manifest, err
+	}
+	defer r.Close()
+	if len(r.File) > maxDocumentImportEntries {
+		return manifest, fmt.Errorf(funcT("The zip file contains more than %d entries"), maxDocumentImportEntries)
+	}
+
+	documents := make([]*Document, 0)
+	var totalSize int64
+	for _, f := range r.File {
+		name := strings.ToValidUTF8(strings.ReplaceAll(f.Name, "\\", "/"), "_")
+		entry := documentImportEntry{Path: name, Size: int64(f.UncompressedSize64)}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if reason := documentImportSkipReason(f, name, extensions); reason != "" {
+			entry.Reason = reason
+			manifest.Skipped = append(manifest.Skipped, entry)
+			continue
+		}
+		// 先按照声明的大小检查,解压时再按照实际读取的字节数检查
+		if f.UncompressedSize64 > maxDocumentImportFileSize {
+			entry.Reason = funcT("File size exceeds the limit")
+			manifest.Failed = append(manifest.Failed, entry)
+			continue
+		}
+		if f.UncompressedSize64 > documentImportRatioMinSize && f.UncompressedSize64 > f.CompressedSize64*maxDocumentImportRatio {
+			entry.Reason = funcT("Compression ratio is too high")
+			manifest.Failed = append(manifest.Failed, entry)
+			continue
+		}
+		if totalSize+int64(f.UncompressedSize64) > maxDocumentImportTotalSize {
+			entry.Reason = funcT("Total size of the zip file exceeds the limit")
+			manifest.Failed = append(manifest.Failed, entry)
+			continue
+		}
+
+		document, size, err := importDocumentZipEntry(ctx, f, name, knowledgeBaseID)
+		totalSize += size
+		entry.Size = size
+		if err != nil {
+			FuncLogError(ctx, fmt.Errorf("import zip %s: %w", name, err))
+			entry.Reason = err.Error()
+			manifest.Failed = append(manifest.Failed, entry)
+			continue
+		}
+		entry.KnowledgeBaseID = document.KnowledgeBaseID
+		entry.DocumentID = document.Id
+		manifest.Imported = append(manifest.Imported, entry)
+		documents = append(documents, document)
+	}
+
+	// 后台依次执行indexPipeline,避免同时调用大量的模型接口
+	indexDocumentsInBackground(documents)
+	return manifest, nil
+}
+
+// documentImportSkipReason 不需要导入的文件,返回跳过的原因
+func documentImportSkipReason(f *zip.File, name string, extensions string) string {
+	if f.Mode()&os.ModeSymlink != 0 || !f.Mode().IsRegular() {
+		return funcT("Not a regular file")
+	}
+	// 系统生成的隐藏文件,例如 __MACOSX/ .DS_Store
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return funcT("Hidden file")
+		}
+	}
+	if !gitSyncFileMatch(extensions, name) {
+		return funcT("Unsupported file type")
+	}
+	return ""
+}
+
+// importDocumentZipEntry 解压一个文件并保存文档,返回文档和实际解压的字节数
+func importDocumentZipEntry(ctx context.Context, f *zip.File, name string, rootKnowledgeBaseID string) (*Document, int64, error) {
+	relDir := path.Dir(name)
+	// 先校验路径,避免创建知识库后才发现是非法路径
+	if _, err := zipEntryPath(datadir+"upload", name); err != nil {
+		return nil, 0, err
+	}
+	knowledgeBaseID, err := folderSyncKnowledgeBaseID(ctx, rootKnowledgeBaseID, relDir)
+	if err != nil {
+		return nil, 0, err
+	}
+	knowledgeBaseName, err := findKnowledgeBaseNameById(ctx, knowledgeBaseID)
+	if err != nil {
+		return nil, 0, err
+	}
+	fileName := path.Base(name)
+	// 和上传文档的路径一致,upload/知识库ID/文件名
+	filePath := "upload" + knowledgeBaseID + fileName
+	destPath, err := zipEntryPath(datadir+"upload", strings.TrimPrefix(knowledgeBaseID, "/")+fileName)
+	if err != nil {
+		return nil, 0, err
+	}
+	size, err := extractDocumentZipEntry(f, destPath)
+	if err != nil {
+		return nil, size, err
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	documentID, err := findDocumentIdByFilePath(ctx, filePath)
+	if err != nil {
+		return nil, size, err
+	}
+	document := &Document{}
+	if documentID != "" {
+		finder := zorm.NewSelectFinder(tableDocumentName).Append("WHERE id=?", documentID)
+		_, err = zorm.QueryRow(ctx, finder, document)
+		if err != nil {
+			return nil, size, err
+		}
+	} else {
+		document.Id = FuncGenerateStringID()
+		document.SortNo = funcMaxSortNo(tableDocumentName)
+		document.CreateTime = now
+	}
+	document.Name = fileName
+	document.FileExt = filepath.Ext(fileName)
+	document.FilePath = filePath
+	document.FileSize = int(size)
+	document.KnowledgeBaseID = knowledgeBaseID
+	document.KnowledgeBaseName = knowledgeBaseName
+	// 清空内容,由转换组件重新读取文件
+	document.Markdown = ""
+	document.Toc = ""
+	document.Summary = ""
+	document.Status = 2
+	document.UpdateTime = now
+	if documentID == "" {
+		_, err = zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+			return zorm.Insert(ctx, document)
+		})
+	}
+	return document, size, err
+}
+
+// extractDocumentZipEntry 解压文件,按照实际读取的字节数限制大小,不信任压缩包中声明的大小
+func extractDocumentZipEntry(f *zip.File, destPath string) (int64, error) {
+	err := os.MkdirAll(filepath.Dir(destPath), 0755)
+	if err != nil {
+		return 0, err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	// 先写入临时文件,成功后再替换,避免覆盖已有的文件后解压失败
+	tmpPath := destPath + ".importing"
+	outFile, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, err
+	}
+	size, err := io.Copy(outFile, io.LimitReader(rc, maxDocumentImportFileSize+1))
+	outFile.Close()
+	if err == nil && size > maxDocumentImportFileSize {
+		err = errors.New(funcT("File size exceeds the limit"))
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return size, err
+	}
+	return size, os.Rename(tmpPath, destPath)
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestZipEntryPath(t *testing.T) {
+	destDir := t.TempDir()
+	for _, name := range []string{"../evil.md", "a/../../evil.md", "/etc/passwd", "..\\evil.md", "C:/evil.md", ""} {
+		if _, err := zipEntryPath(destDir, name); err == nil {
+			t.Errorf("zipEntryPath(%q) should return error", name)
+		}
+	}
+	filePath, err := zipEntryPath(destDir, "docs/a/../b.md")
+	if err != nil || filePath != filepath.Join(destDir, "docs", "b.md") {
+		t.Errorf("zipEntryPath=%q,%v", filePath, err)
+	}
+}
+
+func TestDocumentImportZipEntry(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for _, name := range []string{"guide/intro.md", "__MACOSX/guide/._intro.md", "guide/.DS_Store", "guide/logo.png"} {
+		f, _ := w.Create(name)
+		f.Write([]byte("# Intro"))
+	}
+	w.Close()
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reasons := make([]string, 0)
+	for _, f := range r.File {
+		reasons = append(reasons, documentImportSkipReason(f, f.Name, defaultDocumentImportExtensions))
+	}
+	if reasons[0] != "" || reasons[1] == "" || reasons[2] == "" || reasons[3] == "" {
+		t.Errorf("unexpected skip reasons: %q", reasons)
+	}
+
+	destPath := filepath.Join(t.TempDir(), "guide", "intro.md")
+	size, err := extractDocumentZipEntry(r.File[0], destPath)
+	if err != nil || size != 7 {
+		t.Fatalf("extract size=%d err=%v", size, err)
+	}
+	if data, _ := os.ReadFile(destPath); string(data) != "# Intro" {
+		t.Errorf("unexpected content: %q", data)
+	}
+}
@@ -0,0 +1,324 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitee.com/chunanyong/zorm"
+)
+
+// documentValidFromSQL 当前版本的生效时间,升级前的文档没有valid_from,使用创建时间
+const documentValidFromSQL = "coalesce(nullif(valid_from,''),create_time,'')"
+
+// parseAsOf 解析检索的时间点,支持 2006-01-02 15:04:05, RFC3339 和 2006-01-02
+func parseAsOf(asOf string) (string, error) {
+	asOf = strings.TrimSpace(asOf)
+	if asOf == "" {
+		return "", nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", asOf, time.Local); err == nil {
+		return t.Format("2006-01-02 15:04:05"), nil
+	}
+	if t, err := time.Parse(time.RFC3339, asOf); err == nil {
+		return t.In(time.Local).Format("2006-01-02 15:04:05"), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", asOf, time.Local); err == nil {
+		return t.Format("2006-01-02 15:04:05"), nil
+	}
+	return "", errors.New(funcT("asOf format error, please use 2006-01-02 15:04:05") + ":" + asOf)
+}
+
+// inputAsOf 获取检索的时间点,input["asOf"]优先,然后是组件的参数
+func inputAsOf(input map[string]any, asOf string) (string, error) {
+	if value, ok := input["asOf"].(string); ok && value != "" {
+		asOf = value
+	}
+	return parseAsOf(asOf)
+}
+
+// archiveDocumentVersion 文档内容变化时,把当前版本和分块保存为历史版本,并设置新版本的生效时间.需要在事务中调用
+func archiveDocumentVersion(ctx context.Context, document *Document, now string) error {
+	old := &Document{}
+	finder := zorm.NewSelectFinder(tableDocumentName).Append("WHERE id=?", document.Id)
+	has, err := zorm.QueryRow(ctx, finder, old)
+	if err != nil {
+		return err
+	}
+	if !has {
+		document.ValidFrom = now
+		return nil
+	}
+	validFrom := old.ValidFrom
+	if validFrom == "" {
+		validFrom = old.CreateTime
+	}
+	// 内容没有变化,只是重新索引,保持原来的生效时间
+	if old.Markdown == document.Markdown {
+		document.ValidFrom = validFrom
+		return nil
+	}
+	document.ValidFrom = now
+
+	count := 0
+	finder = zorm.NewSelectFinder(tableDocumentVersionName, "count(*)").Append("WHERE document_id=?", document.Id)
+	_, err = zorm.QueryRow(ctx, finder, &count)
+	if err != nil {
+		return err
+	}
+	version := &DocumentVersion{
+		Id:              FuncGenerateStringID(),
+		DocumentID:      old.Id,
+		KnowledgeBaseID: old.KnowledgeBaseID,
+		Name:            old.Name,
+		Markdown:        old.Markdown,
+		FilePath:        old.FilePath,
+		Metadata:        old.Metadata,
+		Version:         count + 1,
+		ValidFrom:       validFrom,
+		ValidTo:         now,
+		CreateTime:      now,
+	}
+	_, err = zorm.Insert(ctx, version)
+	if err != nil {
+		return err
+	}
+
+	documentChunks := make([]DocumentChunk, 0)
+	finder = zorm.NewSelectFinder(tableDocumentChunkName, "id,document_id,knowledge_base_id,title,markdown,metadata,sortno").Append("WHERE document_id=?", old.Id)
+	finder.SelectTotalCount = false
+	err = zorm.Query(ctx, finder, &documentChunks, nil)
+	if err != nil || len(documentChunks) < 1 {
+		return err
+	}
+	// 保存向量,用于按时间点的向量检索
+	tableName, embeddingModel := knowledgeBaseVecTable(ctx, old.KnowledgeBaseID)
+	vecDocumentChunks := make([]VecDocumentChunk, 0)
+	finder = zorm.NewSelectFinder(tableName, "id,embedding").Append("WHERE document_id=?", old.Id)
+	if embeddingModel != "" {
+		finder.Append("and embedding_model=?", embeddingModel)
+	}
+	finder.SelectTotalCount = false
+	err = zorm.Query(ctx, finder, &vecDocumentChunks, nil)
+	if err != nil {
+		return err
+	}
+	embeddings := make(map[string][]byte, len(vecDocumentChunks))
+	for _, vecdc := range vecDocumentChunks {
+		embeddings[vecdc.Id] = vecdc.Embedding
+	}
+	chunkVersions := make([]zorm.IEntityStruct, 0, len(documentChunks))
+	for _, documentChunk := range documentChunks {
+		chunkVersions = append(chunkVersions, &DocumentChunkVersion{
+			Id:              documentChunk.Id,
+			VersionID:       version.Id,
+			DocumentID:      documentChunk.DocumentID,
+			KnowledgeBaseID: documentChunk.KnowledgeBaseID,
+			Title:           documentChunk.Title,
+			Markdown:        documentChunk.Markdown,
+			Metadata:        documentChunk.Metadata,
+			SortNo:          documentChunk.SortNo,
+			EmbeddingModel:  embeddingModel,
+			Embedding:       embeddings[documentChunk.Id],
+			ValidFrom:       validFrom,
+			ValidTo:         now,
+		})
+	}
+	_, err = zorm.InsertSlice(ctx, chunkVersions)
+	return err
+}
+
+// knowledgeBaseVecTable 知识库使用的向量表和向量模型,没有指定向量化组件的知识库使用默认的向量表
+func knowledgeBaseVecTable(ctx context.Context, knowledgeBaseID string) (string, string) {
+	if knowledgeBaseID != "" {
+		knowledgeBase, err := findKnowledgeBaseById(ctx, knowledgeBaseID)
+		if err == nil && knowledgeBase.EmbedderID != "" {
+			return vecTableName(knowledgeBase.EmbeddingDimension), knowledgeBase.EmbeddingModel
+		}
+	}
+	return tableVecDocumentChunkName, ""
+}
+
+// filterDocumentChunkValidAt 只保留asOf时间点已经生效的当前版本分块,保持原有顺序
+func filterDocumentChunkValidAt(ctx context.Context, documentChunks []DocumentChunk, asOf string) ([]DocumentChunk, error) {
+	if asOf == "" || len(documentChunks) < 1 {
+		return documentChunks, nil
+	}
+	documentIDs := make([]string, 0, len(documentChunks))
+	for i := 0; i < len(documentChunks); i++ {
+		documentIDs = append(documentIDs, documentChunks[i].DocumentID)
+	}
+	finder := zorm.NewSelectFinder(tableDocumentName, "id").Append("WHERE id IN (?) and "+documentValidFromSQL+" <= ?", documentIDs, asOf)
+	finder.SelectTotalCount = false
+	validIDs := make([]string, 0)
+	err := zorm.Query(ctx, finder, &validIDs, nil)
+	if err != nil {
+		return documentChunks, err
+	}
+	validMap := make(map[string]bool, len(validIDs))
+	for _, id := range validIDs {
+		validMap[id] = true
+	}
+	resultDCS := make([]DocumentChunk, 0, len(documentChunks))
+	for i := 0; i < len(documentChunks); i++ {
+		if validMap[documentChunks[i].DocumentID] {
+			resultDCS = append(resultDCS, documentChunks[i])
+		}
+	}
+	return resultDCS, nil
+}
+
+// findDocumentChunkVersionsByEmbedding 向量检索asOf时间点有效的历史版本分块,score和向量表一致是L2距离
+func findDocumentChunkVersionsByEmbedding(ctx context.Context, embedding []byte, embeddingModel string, knowledgeBaseID string, documentID string, metadataFilter string, asOf string, topN int) ([]DocumentChunk, error) {
+	finder := zorm.NewFinder().Append("SELECT id,document_id,knowledge_base_id,title,markdown,sortno,vec_distance_l2(embedding,?) as score FROM "+tableDocumentChunkVersionName, embedding)
+	finder.Append("WHERE embedding is not null and valid_from <= ? and valid_to > ? and embedding_model=?", asOf, asOf, embeddingModel)
+	if knowledgeBaseID != "" {
+		finder.Append("and knowledge_base_id = ?", knowledgeBaseID)
+	}
+	if documentID != "" {
+		finder.Append("and document_id = ?", documentID)
+	}
+	whereSQL, whereValues, err := metadataFilterSQL(metadataFilter, "metadata")
+	if err != nil {
+		return nil, err
+	}
+	if whereSQL != "" {
+		finder.Append("and ("+whereSQL+")", whereValues...)
+	}
+	finder.Append("ORDER BY score LIMIT " + strconv.Itoa(topN))
+	finder.SelectTotalCount = false
+	documentChunks := make([]DocumentChunk, 0)
+	err = zorm.Query(ctx, finder, &documentChunks, nil)
+	return documentChunks, err
+}
+
+// findDocumentChunkVersionsByKeyword 全文检索asOf时间点有效的历史版本分块,score是BM25乘以-1
+func findDocumentChunkVersionsByKeyword(ctx context.Context, query string, knowledgeBaseID string, documentIDs []string, input map[string]any, asOf string, topN int) ([]DocumentChunk, error) {
+	// 历史版本的全文检索表使用simple分词,中文使用jieba_query,其他语言使用simple_query
+	matchSQL := "fts_document_chunk_version match simple_query(?)"
+	if ftsQueryLanguage(ctx, knowledgeBaseID, query) == languageChinese {
+		matchSQL = "fts_document_chunk_version match jieba_query(?)"
+	}
+	finder := zorm.NewFinder().Append("SELECT id,markdown,-1*rank as score from fts_document_chunk_version where "+matchSQL, query)
+	finder.Append(" and valid_from <= ? and valid_to > ?", asOf, asOf)
+	finder.Append(" and markdown !=?  and markdown is not null", "")
+	if len(documentIDs) > 0 {
+		finder.Append(" and document_id in (?)", documentIDs)
+	}
+	if knowledgeBaseID != "" {
+		finder.Append(" and knowledge_base_id like ?", knowledgeBaseID+"%")
+	}
+	err := appendMetadataFilter(finder, input, tableDocumentChunkVersionName)
+	if err != nil {
+		return nil, err
+	}
+	finder.Append("ORDER BY rank LIMIT " + strconv.Itoa(topN))
+	finder.SelectTotalCount = false
+	documentChunks := make([]DocumentChunk, 0)
+	err = zorm.Query(ctx, finder, &documentChunks, nil)
+	return documentChunks, err
+}
+
+// deleteDocumentVersions 删除文档的历史版本,需要在事务中调用
+func deleteDocumentVersions(ctx context.Context, documentID string) error {
+	for _, tableName := range []string{tableDocumentVersionName, tableDocumentChunkVersionName} {
+		finder := zorm.NewDeleteFinder(tableName).Append("WHERE document_id=?", documentID)
+		_, err := zorm.UpdateFinder(ctx, finder)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffLine 文本差异的一行,Op是 '=' 相同, '-' 删除, '+' 新增
+type diffLine struct {
+	Op   string
+	Text string
+}
+
+// maxDiffCells 按行比较的最大计算量,超过后整体显示为删除和新增
+const maxDiffCells = 4000000
+
+// diffLines 使用最长公共子序列按行比较两个文本
+func diffLines(a string, b string) []diffLine {
+	linesA := strings.Split(a, "\n")
+	linesB := strings.Split(b, "\n")
+	// 去掉相同的前缀和后缀,减少计算量
+	prefix := 0
+	for prefix < len(linesA) && prefix < len(linesB) && linesA[prefix] == linesB[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(linesA)-prefix && suffix < len(linesB)-prefix && linesA[len(linesA)-1-suffix] == linesB[len(linesB)-1-suffix] {
+		suffix++
+	}
+	result := make([]diffLine, 0, len(linesA)+len(linesB))
+	for _, line := range linesA[:prefix] {
+		result = append(result, diffLine{Op: "=", Text: line})
+	}
+	midA := linesA[prefix : len(linesA)-suffix]
+	midB := linesB[prefix : len(linesB)-suffix]
+	n, m := len(midA), len(midB)
+	if n*m > maxDiffCells {
+		for _, line := range midA {
+			result = append(result, diffLine{Op: "-", Text: line})
+		}
+		for _, line := range midB {
+			result = append(result, diffLine{Op: "+", Text: line})
+		}
+	} else {
+		// lcs[i][j] 是 midA[i:] 和 midB[j:] 的最长公共子序列长度
+		lcs := make([][]int32, n+1)
+		for i := range lcs {
+			lcs[i] = make([]int32, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < n || j < m {
+			switch {
+			case i < n && j < m && midA[i] == midB[j]:
+				result = append(result, diffLine{Op: "=", Text: midA[i]})
+				i++
+				j++
+			case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+				result = append(result, diffLine{Op: "-", Text: midA[i]})
+				i++
+			default:
+				result = append(result, diffLine{Op: "+", Text: midB[j]})
+				j++
+			}
+		}
+	}
+	for _, line := range linesA[len(linesA)-suffix:] {
+		result = append(result, diffLine{Op: "=", Text: line})
+	}
+	return result
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	a := "# 报销制度\n差旅补贴每天100元\n住宿标准400元\n需要主管审批"
+	b := "# 报销制度\n差旅补贴每天150元\n住宿标准400元\n需要主管审批\n超过5000元需要总监审批"
+	ops := make([]string, 0)
+	for _, line := range diffLines(a, b) {
+		ops = append(ops, line.Op+line.Text)
+	}
+	want := []string{"=# 报销制度", "-差旅补贴每天100元", "+差旅补贴每天150元", "=住宿标准400元", "=需要主管审批", "+超过5000元需要总监审批"}
+	if strings.Join(ops, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected diff:\n%s", strings.Join(ops, "\n"))
+	}
+	if lines := diffLines(a, a); len(lines) != 4 || lines[1].Op != "=" {
+		t.Errorf("same text should have no changes: %v", lines)
+	}
+}
+
+func TestParseAsOf(t *testing.T) {
+	tests := map[string]string{
+		"":                    "",
+		"2025-03-01":          "2025-03-01 00:00:00",
+		"2025-03-01 08:30:00": "2025-03-01 08:30:00",
+	}
+	for asOf, want := range tests {
+		got, err := parseAsOf(asOf)
+		if err != nil || got != want {
+			t.Errorf("parseAsOf(%q)=%q,%v want %q", asOf, got, err, want)
+		}
+	}
+	if _, err := parseAsOf("last march"); err == nil {
+		t.Errorf("invalid asOf should return error")
+	}
+	asOf, err := inputAsOf(map[string]any{"asOf": "2025-01-02"}, "2024-01-01")
+	if err != nil || asOf != "2025-01-02 00:00:00" {
+		t.Errorf("input asOf should take precedence: %q %v", asOf, err)
+	}
+}
+
+func TestSortDocumentChunksDistance(t *testing.T) {
+	// 当前版本已经按照距离截取topN,再追加历史版本,合并后保留距离最近的分块
+	current := []DocumentChunk{{Id: "c1", Score: 0.2}, {Id: "c2", Score: 0.9}}
+	versions := []DocumentChunk{{Id: "v1", Score: 0.1}, {Id: "v2", Score: 1.5}}
+	documentChunks := sortDocumentChunksDistance(append(current, versions...), nil, 2, 0)
+	ids := make([]string, 0)
+	for _, documentChunk := range documentChunks {
+		ids = append(ids, documentChunk.Id)
+	}
+	if got := strings.Join(ids, ","); got != "v1,c1" {
+		t.Errorf("nearest chunks should survive, got %s", got)
+	}
+	// score是最大距离,过滤距离更大的分块
+	documentChunks = sortDocumentChunksDistance([]DocumentChunk{{Id: "c2", Score: 0.9}, {Id: "c1", Score: 0.2}}, nil, 5, 0.5)
+	if len(documentChunks) != 1 || documentChunks[0].Id != "c1" {
+		t.Errorf("chunks farther than the max distance should be dropped: %v", documentChunks)
+	}
+}
@@ -0,0 +1,559 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gitee.com/chunanyong/zorm"
+)
+
+// 知识库可以指定向量化组件,不同维度的向量存储在 vec_document_chunk_{维度} 表,使用embedding_model字段区分模型.
+// 没有指定向量化组件的知识库,继续使用indexPipeline的默认组件和 vec_document_chunk 表.
+
+const (
+	// migrateStatusNone 没有重新向量化
+	migrateStatusNone = 0
+	// migrateStatusRunning 重新向量化中
+	migrateStatusRunning = 1
+	// migrateStatusFail 重新向量化失败
+	migrateStatusFail = 2
+)
+
+// embeddingDimensionProbe 用于探测向量维度的文本
+const embeddingDimensionProbe = "minRAG"
+
+// embeddingDimensionCache 向量模型对应的维度,避免每次都请求探测维度,key是向量模型
+var embeddingDimensionCache sync.Map
+
+// vecTableName 向量维度对应的向量表名称,维度小于等于0使用默认的 vec_document_chunk 表
+func vecTableName(dimension int) string {
+	if dimension <= 0 {
+		return tableVecDocumentChunkName
+	}
+	return tableVecDocumentChunkName + "_" + strconv.Itoa(dimension)
+}
+
+// createVecTable 创建向量维度对应的向量表
+func createVecTable(ctx context.Context, dimension int) error {
+	if dimension <= 0 {
+		return errors.New(funcT("The embedding dimension must be greater than 0"))
+	}
+	tableName := vecTableName(dimension)
+	if tableExist(tableName) {
+		return nil
+	}
+	createSQL := fmt.Sprintf(`CREATE VIRTUAL TABLE IF NOT EXISTS %s USING vec0(
+	id TEXT,
+    document_id TEXT,
+    knowledge_base_id TEXT,
+    embedding_model TEXT,
+    embedding float[%d],
+    sortno INT,
+    status INT
+);`, tableName, dimension)
+	_, err := execNativeSQL(ctx, createSQL)
+	return err
+}
+
+// findVecTableNames 查询所有的向量表,不包含sqlite-vec的影子表
+func findVecTableNames(ctx context.Context) ([]string, error) {
+	finder := zorm.NewSelectFinder("sqlite_master", "name").Append("WHERE type=? and name like ? and sql like ?", "table", tableVecDocumentChunkName+"%", "CREATE VIRTUAL TABLE%")
+	finder.SelectTotalCount = false
+	tableNames := make([]string, 0)
+	err := zorm.Query(ctx, finder, &tableNames, nil)
+	return tableNames, err
+}
+
+// deleteVecDocumentChunk 删除文档在所有向量表中的数据,需要在事务中调用
+func deleteVecDocumentChunk(ctx context.Context, documentID string) error {
+	tableNames, err := findVecTableNames(ctx)
+	if err != nil {
+		return err
+	}
+	for _, tableName := range tableNames {
+		finder := zorm.NewDeleteFinder(tableName).Append("WHERE document_id=?", documentID)
+		_, err = zorm.UpdateFinder(ctx, finder)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertVecDocumentChunks 保存向量数据,需要在事务中调用.默认的 vec_document_chunk 表没有embedding_model字段
+func insertVecDocumentChunks(ctx context.Context, tableName string, embeddingModel string, vecDocumentChunks []VecDocumentChunk) error {
+	if len(vecDocumentChunks) < 1 {
+		return nil
+	}
+	if tableName == tableVecDocumentChunkName {
+		vecdcs := make([]zorm.IEntityStruct, 0)
+		for i := 0; i < len(vecDocumentChunks); i++ {
+			vecdcs = append(vecdcs, &vecDocumentChunks[i])
+		}
+		_, err := zorm.InsertSlice(ctx, vecdcs)
+		return err
+	}
+	for i := 0; i < len(vecDocumentChunks); i++ {
+		vecdc := vecDocumentChunks[i]
+		finder := zorm.NewFinder().Append("INSERT INTO "+tableName+" (id,document_id,knowledge_base_id,embedding_model,embedding,sortno,status) VALUES (?,?,?,?,?,?,?)",
+			vecdc.Id, vecdc.DocumentID, vecdc.KnowledgeBaseID, embeddingModel, vecdc.Embedding, vecdc.SortNo, vecdc.Status)
+		_, err := zorm.UpdateFinder(ctx, finder)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findKnowledgeBaseById 根据ID查询知识库
+func findKnowledgeBaseById(ctx context.Context, knowledgeBaseID string) (*KnowledgeBase, error) {
+	if knowledgeBaseID == "" {
+		return nil, errors.New(funcT("Knowledge base cannot be empty"))
+	}
+	finder := zorm.NewSelectFinder(tableKnowledgeBaseName).Append("WHERE id=?", knowledgeBaseID)
+	knowledgeBase := &KnowledgeBase{}
+	has, err := zorm.QueryRow(ctx, finder, knowledgeBase)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, errors.New(funcT("ID does not exist"))
+	}
+	return knowledgeBase, nil
+}
+
+// findEmbedder 根据组件ID查询向量化组件
+func findEmbedder(embedderID string) (IEmbedder, error) {
+	component, has := baseComponentMap[embedderID]
+	if !has || component == nil {
+		return nil, fmt.Errorf(funcT("The %s component of the pipeline does not exist"), embedderID)
+	}
+	embedder, ok := component.(IEmbedder)
+	if !ok {
+		return nil, fmt.Errorf(funcT("The %s component is not an embedder"), embedderID)
+	}
+	return embedder, nil
+}
+
+// findKnowledgeBaseEmbedder 查询知识库指定的向量化组件,没有指定返回nil
+func findKnowledgeBaseEmbedder(ctx context.Context, knowledgeBaseID string) (IEmbedder, error) {
+	if knowledgeBaseID == "" {
+		return nil, nil
+	}
+	knowledgeBase, err := findKnowledgeBaseById(ctx, knowledgeBaseID)
+	if err != nil {
+		return nil, err
+	}
+	if knowledgeBase.EmbedderID == "" {
+		return nil, nil
+	}
+	return findEmbedder(knowledgeBase.EmbedderID)
+}
+
+// vecSearchTarget 向量检索的一个知识库,包含对应的向量表,向量模型和序列化后的query向量
+type vecSearchTarget struct {
+	KnowledgeBaseID string
+	TableName       string
+	EmbeddingModel  string
+	Query           []byte
+}
+
+// vecMaxKNNLimit sqlite-vec的KNN查询k的最大值
+const vecMaxKNNLimit = 4096
+
+// defaultEmbedderID 默认向量表使用的向量化组件
+const defaultEmbedderID = "OpenAITextEmbedder"
+
+// searchVecTargets 在每个检索目标中查询距离最近的limit条数据,按照距离升序排序.所有目标返回的数据都少于limit时exhausted为true,说明没有更多的候选数据.
+// 不同向量空间的距离不能直接比较,只保证同一个向量空间内的顺序,由sortDocumentChunksDistance按照排名合并
+func searchVecTargets(ctx context.Context, targets []vecSearchTarget, documentID string, limit int) ([]DocumentChunk, bool, error) {
+	documentChunks := make([]DocumentChunk, 0)
+	exhausted := true
+	for _, target := range targets {
+		finder := zorm.NewSelectFinder(target.TableName, "rowid,distance as score,*").Append("WHERE embedding MATCH ?", target.Query)
+		if target.EmbeddingModel != "" {
+			finder.Append(" and embedding_model=?", target.EmbeddingModel)
+		}
+		if documentID != "" {
+			finder.Append(" and document_id=?", documentID)
+		}
+		if target.KnowledgeBaseID != "" {
+			// Only one of EQUALS, GREATER_THAN, LESS_THAN_OR_EQUAL, LESS_THAN, GREATER_THAN_OR_EQUAL, NOT_EQUALS is allowed
+			// vec不支持 like
+			finder.Append(" and knowledge_base_id = ?", target.KnowledgeBaseID)
+		}
+		finder.Append("ORDER BY score LIMIT " + strconv.Itoa(limit))
+		finder.SelectTotalCount = false
+		targetChunks := make([]DocumentChunk, 0)
+		err := zorm.Query(ctx, finder, &targetChunks, nil)
+		if err != nil {
+			return documentChunks, exhausted, err
+		}
+		if len(targetChunks) >= limit {
+			exhausted = false
+		}
+		documentChunks = append(documentChunks, targetChunks...)
+	}
+	sort.SliceStable(documentChunks, func(i, j int) bool {
+		return documentChunks[i].Score < documentChunks[j].Score
+	})
+	return documentChunks, exhausted, nil
+}
+
+// vecTargetSpaces 每个知识库检索目标的向量空间,同一个向量表和向量模型的距离才能直接比较
+func vecTargetSpaces(targets []vecSearchTarget) map[string]string {
+	spaces := make(map[string]string, len(targets))
+	for _, target := range targets {
+		spaces[target.KnowledgeBaseID] = target.TableName + "/" + target.EmbeddingModel
+	}
+	return spaces
+}
+
+// limitVecDocumentChunks 按照距离升序的分块,每个向量空间最多保留topN条,保持原有顺序
+func limitVecDocumentChunks(documentChunks []DocumentChunk, spaces map[string]string, topN int) []DocumentChunk {
+	counts := make(map[string]int)
+	resultDCS := make([]DocumentChunk, 0, len(documentChunks))
+	for _, documentChunk := range documentChunks {
+		space := spaces[documentChunk.KnowledgeBaseID]
+		if counts[space] >= topN {
+			continue
+		}
+		counts[space]++
+		resultDCS = append(resultDCS, documentChunk)
+	}
+	return resultDCS
+}
+
+// findVecSearchTargets 查询知识库和所有下级知识库的向量检索目标.知识库指定了向量化组件时,使用对应的组件向量化query,
+// embeddingModel和知识库的向量模型一致时,说明上游组件已经使用知识库的向量化组件向量化,直接使用embedding.
+// 没有指定向量化组件的知识库使用默认的向量表,embeddingModel不是默认向量化组件的模型时,使用默认的向量化组件重新向量化query.没有可用的向量时跳过
+func findVecSearchTargets(ctx context.Context, knowledgeBaseID string, query string, embedding []float64, embeddingModel string) ([]vecSearchTarget, error) {
+	knowledgeBases := make([]KnowledgeBase, 0)
+	if knowledgeBaseID != "" {
+		finder := zorm.NewSelectFinder(tableKnowledgeBaseName).Append("WHERE id like ? order by id asc", knowledgeBaseID+"%")
+		finder.SelectTotalCount = false
+		err := zorm.Query(ctx, finder, &knowledgeBases, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// 没有知识库或者知识库不存在,检索默认的向量表
+	if len(knowledgeBases) < 1 {
+		knowledgeBases = append(knowledgeBases, KnowledgeBase{Id: knowledgeBaseID})
+	}
+	// 同一个向量化组件只向量化一次query
+	embeddingMap := make(map[string][]float64)
+	queryEmbedding := func(embedderID string, embedderModel string) ([]float64, error) {
+		if embedding != nil && embeddingModel == embedderModel {
+			return embedding, nil
+		}
+		if targetEmbedding, has := embeddingMap[embedderID]; has {
+			return targetEmbedding, nil
+		}
+		embedder, err := findEmbedder(embedderID)
+		if err != nil {
+			return nil, err
+		}
+		targetEmbedding, _, err := embedder.Embedding(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		embeddingMap[embedderID] = targetEmbedding
+		return targetEmbedding, nil
+	}
+	targets := make([]vecSearchTarget, 0, len(knowledgeBases))
+	for _, knowledgeBase := range knowledgeBases {
+		target := vecSearchTarget{KnowledgeBaseID: knowledgeBase.Id, TableName: tableVecDocumentChunkName}
+		targetEmbedding := embedding
+		if knowledgeBase.EmbedderID != "" {
+			target.TableName = vecTableName(knowledgeBase.EmbeddingDimension)
+			target.EmbeddingModel = knowledgeBase.EmbeddingModel
+			if query != "" {
+				var err error
+				targetEmbedding, err = queryEmbedding(knowledgeBase.EmbedderID, knowledgeBase.EmbeddingModel)
+				if err != nil {
+					return nil, err
+				}
+			}
+		} else if embeddingModel != "" {
+			// 上游组件使用其他向量化组件(例如上级知识库的)向量化,不能检索默认的向量表
+			targetEmbedding = nil
+			if embedder, err := findEmbedder(defaultEmbedderID); err == nil && query != "" {
+				targetEmbedding, err = queryEmbedding(defaultEmbedderID, embedder.EmbeddingModel())
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		if targetEmbedding == nil {
+			continue
+		}
+		targetQuery, err := vecSerializeFloat64(targetEmbedding)
+		if err != nil {
+			return nil, err
+		}
+		target.Query = targetQuery
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// embeddingDimension 向量化探测文本,获取向量维度
+func embeddingDimension(ctx context.Context, embedder IEmbedder) (int, error) {
+	embeddingModel := embedder.EmbeddingModel()
+	if dimension, has := embeddingDimensionCache.Load(embeddingModel); has {
+		return dimension.(int), nil
+	}
+	embedding, _, err := embedder.Embedding(ctx, embeddingDimensionProbe)
+	if err != nil {
+		return 0, err
+	}
+	if len(embedding) < 1 {
+		return 0, errors.New(funcT("The embedding dimension must be greater than 0"))
+	}
+	embeddingDimensionCache.Store(embeddingModel, len(embedding))
+	return len(embedding), nil
+}
+
+// initKnowledgeBaseEmbedder 新建知识库时,记录向量化组件的模型和维度,并创建向量表
+func initKnowledgeBaseEmbedder(ctx context.Context, knowledgeBase *KnowledgeBase) error {
+	if knowledgeBase.EmbedderID == "" {
+		return nil
+	}
+	embedder, err := findEmbedder(knowledgeBase.EmbedderID)
+	if err != nil {
+		return err
+	}
+	dimension, err := embeddingDimension(ctx, embedder)
+	if err != nil {
+		return err
+	}
+	err = createVecTable(ctx, dimension)
+	if err != nil {
+		return err
+	}
+	knowledgeBase.EmbeddingModel = embedder.EmbeddingModel()
+	knowledgeBase.EmbeddingDimension = dimension
+	return nil
+}
+
+// storeKnowledgeBaseVecDocumentChunks 保存文档的向量数据到知识库对应的向量表,需要在事务中调用
+func storeKnowledgeBaseVecDocumentChunks(ctx context.Context, knowledgeBaseID string, vecDocumentChunks []VecDocumentChunk) error {
+	tableName := tableVecDocumentChunkName
+	embeddingModel := ""
+	if knowledgeBaseID != "" {
+		knowledgeBase, err := findKnowledgeBaseById(ctx, knowledgeBaseID)
+		if err == nil && knowledgeBase.EmbedderID != "" {
+			tableName = vecTableName(knowledgeBase.EmbeddingDimension)
+			embeddingModel = knowledgeBase.EmbeddingModel
+		}
+	}
+	return insertVecDocumentChunks(ctx, tableName, embeddingModel, vecDocumentChunks)
+}
+
+// startKnowledgeBaseMigration 使用新的向量化组件重新向量化知识库,在后台运行.切换之前,旧的向量数据继续提供检索
+func startKnowledgeBaseMigration(ctx context.Context, knowledgeBaseID string, embedderID string) error {
+	knowledgeBase, err := findKnowledgeBaseById(ctx, knowledgeBaseID)
+	if err != nil {
+		return err
+	}
+	if knowledgeBase.MigrateStatus == migrateStatusRunning {
+		return errors.New(funcT("The knowledge base is being re-embedded"))
+	}
+	embedder, err := findEmbedder(embedderID)
+	if err != nil {
+		return err
+	}
+	dimension, err := embeddingDimension(ctx, embedder)
+	if err != nil {
+		return err
+	}
+	embeddingModel := embedder.EmbeddingModel()
+	// 相同的表和模型无法区分新旧数据
+	if knowledgeBase.EmbedderID != "" && knowledgeBase.EmbeddingModel == embeddingModel && knowledgeBase.EmbeddingDimension == dimension {
+		return errors.New(funcT("The knowledge base already uses this embedding model"))
+	}
+	err = createVecTable(ctx, dimension)
+	if err != nil {
+		return err
+	}
+	tableName := vecTableName(dimension)
+	_, err = zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		// 清理上次迁移失败残留的数据
+		finder := zorm.NewDeleteFinder(tableName).Append("WHERE knowledge_base_id=? and embedding_model=?", knowledgeBaseID, embeddingModel)
+		_, err := zorm.UpdateFinder(ctx, finder)
+		if err != nil {
+			return nil, err
+		}
+		finder = zorm.NewUpdateFinder(tableKnowledgeBaseName).Append("migrate_embedder_id=?,migrate_status=?,migrate_message=? WHERE id=?", embedderID, migrateStatusRunning, "", knowledgeBaseID)
+		return zorm.UpdateFinder(ctx, finder)
+	})
+	if err != nil {
+		return err
+	}
+
+	go migrateKnowledgeBase(context.Background(), knowledgeBaseID, embedderID, embedder, dimension)
+	return nil
+}
+
+// initKnowledgeBaseMigration 启动时继续上次重启前没有完成的重新向量化,已经迁移的文档会重新向量化.向量化组件不可用时标记为失败,需要重新发起
+func initKnowledgeBaseMigration(ctx context.Context) {
+	finder := zorm.NewSelectFinder(tableKnowledgeBaseName).Append("WHERE migrate_status=?", migrateStatusRunning)
+	finder.SelectTotalCount = false
+	list := make([]KnowledgeBase, 0)
+	err := zorm.Query(ctx, finder, &list, nil)
+	if err != nil {
+		FuncLogError(ctx, err)
+		return
+	}
+	for i := 0; i < len(list); i++ {
+		knowledgeBase := list[i]
+		embedder, err := findEmbedder(knowledgeBase.MigrateEmbedderID)
+		if err != nil {
+			updateKnowledgeBaseMigrate(ctx, knowledgeBase.Id, migrateStatusFail, err.Error())
+			continue
+		}
+		dimension, err := embeddingDimension(ctx, embedder)
+		if err != nil {
+			updateKnowledgeBaseMigrate(ctx, knowledgeBase.Id, migrateStatusFail, err.Error())
+			continue
+		}
+		go migrateKnowledgeBase(ctx, knowledgeBase.Id, knowledgeBase.MigrateEmbedderID, embedder, dimension)
+	}
+}
+
+// migrateKnowledgeBase 逐个文档重新向量化,全部完成后切换到新的向量化组件,并删除旧的向量数据
+func migrateKnowledgeBase(ctx context.Context, knowledgeBaseID string, embedderID string, embedder IEmbedder, dimension int) {
+	embeddingModel := embedder.EmbeddingModel()
+	tableName := vecTableName(dimension)
+	finder := zorm.NewSelectFinder(tableDocumentName, "id").Append("WHERE knowledge_base_id=? order by sortno asc", knowledgeBaseID)
+	finder.SelectTotalCount = false
+	documentIDs := make([]string, 0)
+	err := zorm.Query(ctx, finder, &documentIDs, nil)
+	if err != nil {
+		updateKnowledgeBaseMigrate(ctx, knowledgeBaseID, migrateStatusFail, err.Error())
+		return
+	}
+	for i, documentID := range documentIDs {
+		err = migrateDocumentEmbedding(ctx, documentID, embedder, tableName, embeddingModel)
+		if err != nil {
+			updateKnowledgeBaseMigrate(ctx, knowledgeBaseID, migrateStatusFail, err.Error())
+			return
+		}
+		updateKnowledgeBaseMigrate(ctx, knowledgeBaseID, migrateStatusRunning, fmt.Sprintf("%d/%d", i+1, len(documentIDs)))
+	}
+
+	// 切换到新的向量化组件
+	_, err = zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		knowledgeBase, err := findKnowledgeBaseById(ctx, knowledgeBaseID)
+		if err != nil {
+			return nil, err
+		}
+		finder := zorm.NewDeleteFinder(tableVecDocumentChunkName).Append("WHERE knowledge_base_id=?", knowledgeBaseID)
+		if knowledgeBase.EmbedderID != "" {
+			finder = zorm.NewDeleteFinder(vecTableName(knowledgeBase.EmbeddingDimension)).Append("WHERE knowledge_base_id=? and embedding_model=?", knowledgeBaseID, knowledgeBase.EmbeddingModel)
+		}
+		_, err = zorm.UpdateFinder(ctx, finder)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now().Format("2006-01-02 15:04:05")
+		finder = zorm.NewUpdateFinder(tableKnowledgeBaseName).Append("embedder_id=?,embedding_model=?,embedding_dimension=?,migrate_embedder_id=?,migrate_status=?,migrate_message=?,update_time=? WHERE id=?",
+			embedderID, embeddingModel, dimension, "", migrateStatusNone, now, now, knowledgeBaseID)
+		return zorm.UpdateFinder(ctx, finder)
+	})
+	if err != nil {
+		updateKnowledgeBaseMigrate(ctx, knowledgeBaseID, migrateStatusFail, err.Error())
+	}
+}
+
+// migrateDocumentEmbedding 使用新的向量化组件向量化文档的分块,保存到新的向量表
+func migrateDocumentEmbedding(ctx context.Context, documentID string, embedder IEmbedder, tableName string, embeddingModel string) error {
+	finder := zorm.NewSelectFinder(tableDocumentChunkName, "id,document_id,knowledge_base_id,markdown,context,sortno").Append("WHERE document_id=? and (duplicate_of IS NULL or duplicate_of='') order by sortno asc", documentID)
+	finder.SelectTotalCount = false
+	documentChunks := make([]DocumentChunk, 0)
+	err := zorm.Query(ctx, finder, &documentChunks, nil)
+	if err != nil {
+		return err
+	}
+	vecDocumentChunks := make([]VecDocumentChunk, 0)
+	for i := 0; i < len(documentChunks); i++ {
+		_, embedding, err := embedder.Embedding(ctx, documentChunkEmbeddingText(documentChunks[i]))
+		if err != nil {
+			return err
+		}
+		vecdc := VecDocumentChunk{}
+		vecdc.Id = documentChunks[i].Id
+		vecdc.DocumentID = documentChunks[i].DocumentID
+		vecdc.KnowledgeBaseID = documentChunks[i].KnowledgeBaseID
+		vecdc.SortNo = documentChunks[i].SortNo
+		vecdc.Status = 1
+		vecdc.Embedding = embedding
+		vecDocumentChunks = append(vecDocumentChunks, vecdc)
+	}
+	// 分块的问题也需要重新向量化
+	questionVecDocumentChunks, err := migrateDocumentChunkQuestions(ctx, documentID, embedder)
+	if err != nil {
+		return err
+	}
+	vecDocumentChunks = append(vecDocumentChunks, questionVecDocumentChunks...)
+	_, err = zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		finder := zorm.NewDeleteFinder(tableName).Append("WHERE document_id=? and embedding_model=?", documentID, embeddingModel)
+		_, err := zorm.UpdateFinder(ctx, finder)
+		if err != nil {
+			return nil, err
+		}
+		return nil, insertVecDocumentChunks(ctx, tableName, embeddingModel, vecDocumentChunks)
+	})
+	return err
+}
+
+// migrateDocumentIfRunning 知识库正在重新向量化时,新索引的文档也需要向量化到新的向量表
+func migrateDocumentIfRunning(ctx context.Context, document *Document) error {
+	knowledgeBase, err := findKnowledgeBaseById(ctx, document.KnowledgeBaseID)
+	if err != nil || knowledgeBase.MigrateStatus != migrateStatusRunning {
+		return nil
+	}
+	embedder, err := findEmbedder(knowledgeBase.MigrateEmbedderID)
+	if err != nil {
+		return err
+	}
+	dimension, err := embeddingDimension(ctx, embedder)
+	if err != nil {
+		return err
+	}
+	return migrateDocumentEmbedding(ctx, document.Id, embedder, vecTableName(dimension), embedder.EmbeddingModel())
+}
+
+// updateKnowledgeBaseMigrate 更新知识库重新向量化的状态和信息
+func updateKnowledgeBaseMigrate(ctx context.Context, knowledgeBaseID string, migrateStatus int, migrateMessage string) {
+	_, err := zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		finder := zorm.NewUpdateFinder(tableKnowledgeBaseName).Append("migrate_status=?,migrate_message=? WHERE id=?", migrateStatus, migrateMessage, knowledgeBaseID)
+		return zorm.UpdateFinder(ctx, finder)
+	})
+	if err != nil {
+		FuncLogError(ctx, err)
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"gitee.com/chunanyong/zorm"
+)
+
+// defaultEmbeddingCacheSize 默认缓存的最大条数
+const defaultEmbeddingCacheSize = 100000
+
+// embeddingCacheEvictInterval 每插入多少条缓存,检查一次缓存大小
+const embeddingCacheEvictInterval = 100
+
+var (
+	// embeddingCacheHit 缓存命中次数,重启后清零
+	embeddingCacheHit atomic.Int64
+	// embeddingCacheMiss 缓存未命中次数,重启后清零
+	embeddingCacheMiss atomic.Int64
+	// embeddingCacheInsert 缓存插入次数,用于触发淘汰
+	embeddingCacheInsert atomic.Int64
+)
+
+// EmbeddingCacheStats 向量缓存的统计信息
+type EmbeddingCacheStats struct {
+	// Count 缓存条数
+	Count int `json:"count"`
+	// MaxSize 缓存的最大条数
+	MaxSize int `json:"maxSize"`
+	// Bytes 向量占用的字节数
+	Bytes int `json:"bytes"`
+	// Hit 命中次数
+	Hit int64 `json:"hit"`
+	// Miss 未命中次数
+	Miss int64 `json:"miss"`
+	// HitRate 命中率,百分比
+	HitRate float64 `json:"hitRate"`
+}
+
+// embeddingCacheMaxSize 缓存的最大条数,小于0禁用缓存
+func embeddingCacheMaxSize() int {
+	if config.EmbeddingCacheSize == 0 {
+		return defaultEmbeddingCacheSize
+	}
+	return config.EmbeddingCacheSize
+}
+
+// embeddingCacheID 根据 (model, base_url, sha256(text)) 生成缓存ID
+func embeddingCacheID(model string, baseURL string, textHash string) string {
+	return sha256hex(model + "\n" + baseURL + "\n" + textHash)
+}
+
+// embeddingWithCache 先查询向量缓存,没有命中再调用embed函数,并把结果写入缓存.返回向量和序列化后的向量
+func embeddingWithCache(ctx context.Context, model string, baseURL string, text string, embed func() ([]float64, error)) ([]float64, []byte, error) {
+	if embeddingCacheMaxSize() < 0 {
+		embedding, err := embed()
+		if err != nil {
+			return nil, nil, err
+		}
+		vecEmbedding, err := vecSerializeFloat64(embedding)
+		return embedding, vecEmbedding, err
+	}
+
+	textHash := sha256hex(text)
+	id := embeddingCacheID(model, baseURL, textHash)
+	embedding, vecEmbedding, has := findEmbeddingCache(ctx, id)
+	if has {
+		embeddingCacheHit.Add(1)
+		return embedding, vecEmbedding, nil
+	}
+	embeddingCacheMiss.Add(1)
+
+	embedding, err := embed()
+	if err != nil {
+		return nil, nil, err
+	}
+	vecEmbedding, err = vecSerializeFloat64(embedding)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now().Format("2006-01-02 15:04:05")
+	embeddingCache := &EmbeddingCache{
+		Id:         id,
+		Model:      model,
+		BaseURL:    baseURL,
+		TextHash:   textHash,
+		Embedding:  vecEmbedding,
+		Dimension:  len(embedding),
+		CreateTime: now,
+		UpdateTime: now,
+	}
+	// 缓存写入失败不影响向量化的结果
+	if err := saveEmbeddingCache(ctx, embeddingCache); err != nil {
+		FuncLogError(ctx, err)
+	}
+	return embedding, vecEmbedding, nil
+}
+
+// findEmbeddingCache 根据ID查询缓存,命中后更新命中次数和时间
+func findEmbeddingCache(ctx context.Context, id string) ([]float64, []byte, bool) {
+	finder := zorm.NewSelectFinder(tableEmbeddingCacheName).Append("WHERE id=?", id)
+	embeddingCache := EmbeddingCache{}
+	has, err := zorm.QueryRow(ctx, finder, &embeddingCache)
+	if err != nil || !has || len(embeddingCache.Embedding) < 1 {
+		return nil, nil, false
+	}
+	embedding, err := vecDeserializeFloat64(embeddingCache.Embedding)
+	if err != nil {
+		FuncLogError(ctx, err)
+		return nil, nil, false
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		finder := zorm.NewUpdateFinder(tableEmbeddingCacheName).Append("hit_count=hit_count+1,update_time=? WHERE id=?", now, id)
+		return zorm.UpdateFinder(ctx, finder)
+	})
+	return embedding, embeddingCache.Embedding, true
+}
+
+// saveEmbeddingCache 保存缓存,超过最大条数时淘汰最久未使用的缓存
+func saveEmbeddingCache(ctx context.Context, embeddingCache *EmbeddingCache) error {
+	_, err := zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		//先删除,重新插入
+		zorm.Delete(ctx, embeddingCache)
+		return zorm.Insert(ctx, embeddingCache)
+	})
+	if err != nil {
+		return err
+	}
+	if embeddingCacheInsert.Add(1)%embeddingCacheEvictInterval != 0 {
+		return nil
+	}
+	return evictEmbeddingCache(ctx, embeddingCacheMaxSize())
+}
+
+// evictEmbeddingCache 只保留最近使用的maxSize条缓存
+func evictEmbeddingCache(ctx context.Context, maxSize int) error {
+	if maxSize < 0 {
+		return nil
+	}
+	_, err := zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		finder := zorm.NewDeleteFinder(tableEmbeddingCacheName).Append("WHERE id IN (SELECT id FROM "+tableEmbeddingCacheName+" ORDER BY update_time DESC LIMIT -1 OFFSET ?)", maxSize)
+		return zorm.UpdateFinder(ctx, finder)
+	})
+	return err
+}
+
+// findEmbeddingCacheStats 查询向量缓存的统计信息
+func findEmbeddingCacheStats(ctx context.Context) EmbeddingCacheStats {
+	stats := EmbeddingCacheStats{
+		MaxSize: embeddingCacheMaxSize(),
+		Hit:     embeddingCacheHit.Load(),
+		Miss:    embeddingCacheMiss.Load(),
+	}
+	if total := stats.Hit + stats.Miss; total > 0 {
+		stats.HitRate = float64(stats.Hit*10000/total) / 100
+	}
+	finder := zorm.NewSelectFinder(tableEmbeddingCacheName, "count(*) as count,ifnull(sum(length(embedding)),0) as bytes")
+	row, err := zorm.QueryRowMap(ctx, finder)
+	if err != nil {
+		FuncLogError(ctx, err)
+		return stats
+	}
+	if count, ok := row["count"].(int64); ok {
+		stats.Count = int(count)
+	}
+	if bytes, ok := row["bytes"].(int64); ok {
+		stats.Bytes = int(bytes)
+	}
+	return stats
+}
+
+// purgeEmbeddingCache 清空向量缓存,并重置统计
+func purgeEmbeddingCache(ctx context.Context) error {
+	err := deleteAll(ctx, tableEmbeddingCacheName)
+	if err != nil {
+		return err
+	}
+	embeddingCacheHit.Store(0)
+	embeddingCacheMiss.Store(0)
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSortDocumentChunksDistanceSpaces(t *testing.T) {
+	// 子知识库使用不同的向量化组件,距离不能直接比较,按照各自向量空间中的排名合并
+	spaces := map[string]string{"/a/": "vec_document_chunk/", "/a/b/": "vec_document_chunk_1024/bge-m3"}
+	documentChunks := []DocumentChunk{
+		{Id: "a1", KnowledgeBaseID: "/a/", Score: 0.1},
+		{Id: "a2", KnowledgeBaseID: "/a/", Score: 0.2},
+		{Id: "a3", KnowledgeBaseID: "/a/", Score: 0.3},
+		{Id: "b1", KnowledgeBaseID: "/a/b/", Score: 0.8},
+		{Id: "b2", KnowledgeBaseID: "/a/b/", Score: 0.9},
+	}
+	documentChunks = sortDocumentChunksDistance(documentChunks, spaces, 4, 0)
+	ids := make([]string, 0)
+	for _, documentChunk := range documentChunks {
+		ids = append(ids, documentChunk.Id)
+	}
+	if got := strings.Join(ids, ","); got != "a1,b1,a2,b2" {
+		t.Errorf("chunks of different vector spaces should be merged by rank, got %s", got)
+	}
+	if limited := limitVecDocumentChunks([]DocumentChunk{{Id: "a1", KnowledgeBaseID: "/a/"}, {Id: "a2", KnowledgeBaseID: "/a/"}, {Id: "b1", KnowledgeBaseID: "/a/b/"}}, spaces, 1); len(limited) != 2 || limited[1].Id != "b1" {
+		t.Errorf("each vector space should keep topN chunks: %v", limited)
+	}
+}
+
+func TestFindVecSearchTargetsEmbeddingModel(t *testing.T) {
+	ctx := context.Background()
+	targets, err := findVecSearchTargets(ctx, "", "", []float64{1, 0}, "")
+	if err != nil || len(targets) != 1 {
+		t.Fatalf("default embedding should search the default table: %v %v", targets, err)
+	}
+	// 其他向量化组件的向量不能检索默认的向量表
+	targets, err = findVecSearchTargets(ctx, "", "", []float64{1, 0}, "bge-m3")
+	if err != nil || len(targets) != 0 {
+		t.Errorf("embedding of another model should not search the default table: %v %v", targets, err)
+	}
+}
@@ -0,0 +1,652 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gitee.com/chunanyong/zorm"
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// folderSyncModeSchedule 定时扫描
+	folderSyncModeSchedule = 0
+	// folderSyncModeWatch 监听文件变化
+	folderSyncModeWatch = 1
+
+	// folderSyncStatusIdle 空闲
+	folderSyncStatusIdle = 0
+	// folderSyncStatusRunning 同步中
+	folderSyncStatusRunning = 1
+	// folderSyncStatusFailed 同步失败
+	folderSyncStatusFailed = 2
+
+	// defaultFolderSyncInterval 默认的扫描间隔,单位秒
+	defaultFolderSyncInterval = 300
+	// folderSyncDebounce 监听到文件变化后,等待文件写入完成再同步
+	folderSyncDebounce = 3 * time.Second
+	// folderSyncUploadDir 同步的文件复制到的目录,相对于datadir,转换组件从这里读取文件
+	folderSyncUploadDir = "upload/folder_sync/"
+)
+
+// folderSyncWorker 目录同步的后台任务
+type folderSyncWorker struct {
+	stop    chan struct{}
+	running atomic.Bool
+}
+
+var (
+	// folderSyncWorkers 运行中的目录同步任务,key是FolderSync.Id
+	folderSyncWorkers = make(map[string]*folderSyncWorker)
+	// folderSyncLock 保护folderSyncWorkers
+	folderSyncLock sync.Mutex
+)
+
+// folderSyncResult 一次同步的结果
+type folderSyncResult struct {
+	Added   int
+	Updated int
+	Deleted int
+	Skipped int
+	Failed  int
+}
+
+// String 同步结果的描述
+func (result folderSyncResult) String() string {
+	return fmt.Sprintf(funcT("Added: %d, Updated: %d, Deleted: %d, Unchanged: %d, Failed: %d"), result.Added, result.Updated, result.Deleted, result.Skipped, result.Failed)
+}
+
+// initFolderSync 启动时开启所有可用的目录同步
+func initFolderSync(ctx context.Context) {
+	finder := zorm.NewSelectFinder(tableFolderSyncName).Append("WHERE status=1")
+	list := make([]FolderSync, 0)
+	err := zorm.Query(ctx, finder, &list, nil)
+	if err != nil {
+		FuncLogError(ctx, err)
+		return
+	}
+	for i := 0; i < len(list); i++ {
+		startFolderSync(ctx, &list[i])
+	}
+}
+
+// findFolderSyncById 根据ID查询目录同步
+func findFolderSyncById(ctx context.Context, id string) (*FolderSync, error) {
+	finder := zorm.NewSelectFinder(tableFolderSyncName).Append("WHERE id=?", id)
+	folderSync := &FolderSync{}
+	has, err := zorm.QueryRow(ctx, finder, folderSync)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, errors.New(funcT("ID does not exist"))
+	}
+	return folderSync, nil
+}
+
+// validateFolderSync 校验目录同步的配置
+func validateFolderSync(ctx context.Context, folderSync *FolderSync) error {
+	folderSync.RootPath = strings.TrimSpace(folderSync.RootPath)
+	fileInfo, err := os.Stat(folderSync.RootPath)
+	if err != nil || !fileInfo.IsDir() {
+		return errors.New(funcT("The root path does not exist or is not a directory"))
+	}
+	if _, err := findKnowledgeBaseById(ctx, folderSync.KnowledgeBaseID); err != nil {
+		return err
+	}
+	if _, err := compileFolderSyncGlobs(folderSync.IncludeGlobs); err != nil {
+		return err
+	}
+	if _, err := compileFolderSyncGlobs(folderSync.ExcludeGlobs); err != nil {
+		return err
+	}
+	return nil
+}
+
+// startFolderSync 开启目录同步的后台任务,已经存在的任务会先停止
+func startFolderSync(ctx context.Context, folderSync *FolderSync) {
+	stopFolderSync(folderSync.Id)
+	if folderSync.Status != 1 {
+		return
+	}
+	worker := &folderSyncWorker{stop: make(chan struct{})}
+	folderSyncLock.Lock()
+	folderSyncWorkers[folderSync.Id] = worker
+	folderSyncLock.Unlock()
+
+	interval := folderSync.SyncInterval
+	if interval <= 0 {
+		interval = defaultFolderSyncInterval
+	}
+	go func() {
+		ticker := time.NewTicker(time.Second * time.Duration(interval))
+		defer ticker.Stop()
+
+		var events chan fsnotify.Event
+		var errs chan error
+		var watcher *fsnotify.Watcher
+		if folderSync.SyncMode == folderSyncModeWatch {
+			var err error
+			watcher, err = newFolderSyncWatcher(folderSync.RootPath)
+			if err != nil {
+				FuncLogError(ctx, err)
+			} else {
+				defer watcher.Close()
+				events = watcher.Events
+				errs = watcher.Errors
+			}
+		}
+
+		runFolderSync(ctx, folderSync.Id)
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-worker.stop:
+				return
+			case <-ticker.C:
+				runFolderSync(ctx, folderSync.Id)
+			case event, ok := <-events:
+				if !ok {
+					events = nil
+					continue
+				}
+				// 新建的目录也需要监听
+				if event.Op&fsnotify.Create == fsnotify.Create {
+					if fileInfo, err := os.Stat(event.Name); err == nil && fileInfo.IsDir() {
+						addFolderSyncWatch(watcher, event.Name)
+					}
+				}
+				debounce = time.After(folderSyncDebounce)
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+					continue
+				}
+				FuncLogError(ctx, err)
+			case <-debounce:
+				debounce = nil
+				runFolderSync(ctx, folderSync.Id)
+			}
+		}
+	}()
+}
+
+// stopFolderSync 停止目录同步的后台任务
+func stopFolderSync(id string) {
+	folderSyncLock.Lock()
+	defer folderSyncLock.Unlock()
+	worker, has := folderSyncWorkers[id]
+	if !has {
+		return
+	}
+	close(worker.stop)
+	delete(folderSyncWorkers, id)
+}
+
+// newFolderSyncWatcher 监听目录和所有的子目录,fsnotify不支持递归监听
+func newFolderSyncWatcher(rootPath string) (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	err = addFolderSyncWatch(watcher, rootPath)
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	return watcher, nil
+}
+
+// addFolderSyncWatch 监听目录和所有的子目录
+func addFolderSyncWatch(watcher *fsnotify.Watcher, dirPath string) error {
+	if watcher == nil {
+		return nil
+	}
+	return filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+}
+
+// runFolderSync 执行一次同步,同一个目录同步正在执行时跳过
+func runFolderSync(ctx context.Context, id string) error {
+	folderSyncLock.Lock()
+	worker, has := folderSyncWorkers[id]
+	folderSyncLock.Unlock()
+	if has {
+		if !worker.running.CompareAndSwap(false, true) {
+			return errors.New(funcT("Folder sync is running"))
+		}
+		defer worker.running.Store(false)
+	}
+
+	folderSync, err := findFolderSyncById(ctx, id)
+	if err != nil {
+		FuncLogError(ctx, err)
+		return err
+	}
+	updateFolderSyncStatus(ctx, id, folderSyncStatusRunning, "")
+	result, err := syncFolder(ctx, folderSync)
+	if err != nil {
+		FuncLogError(ctx, err)
+		updateFolderSyncStatus(ctx, id, folderSyncStatusFailed, err.Error())
+		return err
+	}
+	status := folderSyncStatusIdle
+	if result.Failed > 0 {
+		status = folderSyncStatusFailed
+	}
+	updateFolderSyncStatus(ctx, id, status, result.String())
+	return nil
+}
+
+// updateFolderSyncStatus 更新同步状态和结果
+func updateFolderSyncStatus(ctx context.Context, id string, status int, message string) {
+	now := time.Now().Format("2006-01-02 15:04:05")
+	_, err := zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		finder := zorm.NewUpdateFinder(tableFolderSyncName).Append("sync_status=?,sync_message=?,last_sync_time=? WHERE id=?", status, message, now, id)
+		return zorm.UpdateFinder(ctx, finder)
+	})
+	if err != nil {
+		FuncLogError(ctx, err)
+	}
+}
+
+// syncFolder 对比文件的修改时间和hash,新增、更新、删除知识库的文档
+func syncFolder(ctx context.Context, folderSync *FolderSync) (folderSyncResult, error) {
+	result := folderSyncResult{}
+	includes, err := compileFolderSyncGlobs(folderSync.IncludeGlobs)
+	if err != nil {
+		return result, err
+	}
+	excludes, err := compileFolderSyncGlobs(folderSync.ExcludeGlobs)
+	if err != nil {
+		return result, err
+	}
+	rootPath := filepath.Clean(folderSync.RootPath)
+	if fileInfo, err := os.Stat(rootPath); err != nil || !fileInfo.IsDir() {
+		// 目录不可用时不删除文档,避免挂载失败时清空知识库
+		return result, errors.New(funcT("The root path does not exist or is not a directory"))
+	}
+
+	// 已经同步的文件
+	finder := zorm.NewSelectFinder(tableFolderSyncFileName).Append("WHERE folder_sync_id=?", folderSync.Id)
+	syncFiles := make([]FolderSyncFile, 0)
+	err = zorm.Query(ctx, finder, &syncFiles, nil)
+	if err != nil {
+		return result, err
+	}
+	syncFileMap := make(map[string]*FolderSyncFile, len(syncFiles))
+	for i := 0; i < len(syncFiles); i++ {
+		syncFileMap[syncFiles[i].FilePath] = &syncFiles[i]
+	}
+
+	err = filepath.WalkDir(rootPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		relPath, err := filepath.Rel(rootPath, path)
+		if err != nil || relPath == "." {
+			return nil
+		}
+		relPath = filepath.ToSlash(relPath)
+		if d.IsDir() {
+			if matchFolderSyncGlobs(excludes, relPath) || matchFolderSyncGlobs(excludes, relPath+"/") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !folderSyncFileMatch(includes, excludes, relPath) {
+			return nil
+		}
+		syncFile := syncFileMap[relPath]
+		delete(syncFileMap, relPath)
+		added, err := syncFolderFile(ctx, folderSync, path, relPath, syncFile)
+		if err != nil {
+			FuncLogError(ctx, fmt.Errorf("folder sync %s: %w", path, err))
+			result.Failed++
+		} else if added == nil {
+			result.Skipped++
+		} else if *added {
+			result.Added++
+		} else {
+			result.Updated++
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	// 剩余的文件已经被删除或者不再匹配,删除对应的文档
+	for _, syncFile := range syncFileMap {
+		err := deleteFolderSyncFile(ctx, syncFile)
+		if err != nil {
+			FuncLogError(ctx, err)
+			result.Failed++
+			continue
+		}
+		result.Deleted++
+	}
+	return result, nil
+}
+
+// syncFolderFile 同步一个文件,返回nil表示文件没有变化,true表示新增,false表示更新
+func syncFolderFile(ctx context.Context, folderSync *FolderSync, path string, relPath string, syncFile *FolderSyncFile) (*bool, error) {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	modTime := fileInfo.ModTime().UnixNano()
+	if syncFile != nil && syncFile.ModTime == modTime && syncFile.FileSize == fileInfo.Size() {
+		return nil, nil
+	}
+	fileHash, err := sha256File(path)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Format("2006-01-02 15:04:05")
+	added := syncFile == nil
+	if added {
+		syncFile = &FolderSyncFile{
+			Id:           sha256hex(folderSync.Id + "\n" + relPath),
+			FolderSyncID: folderSync.Id,
+			FilePath:     relPath,
+			DocumentID:   FuncGenerateStringID(),
+		}
+	} else if syncFile.FileHash == fileHash && syncFile.ModTime != 0 {
+		// 只有修改时间变化,内容没有变化
+		syncFile.ModTime = modTime
+		syncFile.FileSize = fileInfo.Size()
+		syncFile.UpdateTime = now
+		_, err = zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+			return zorm.Update(ctx, syncFile)
+		})
+		return nil, err
+	}
+
+	knowledgeBaseID, err := folderSyncKnowledgeBaseID(ctx, folderSync.KnowledgeBaseID, filepath.ToSlash(filepath.Dir(relPath)))
+	if err != nil {
+		return nil, err
+	}
+	// 复制到上传目录,和上传的文档一样由转换组件读取
+	filePath := folderSyncUploadDir + folderSync.Id + "/" + relPath
+	err = copyFolderSyncFile(path, datadir+filePath)
+	if err != nil {
+		return nil, err
+	}
+	err = indexFolderSyncDocument(ctx, syncFile.DocumentID, knowledgeBaseID, filePath, relPath)
+
+	// 索引失败时修改时间记录为0,下次同步重新处理
+	syncFile.ModTime = modTime
+	if err != nil {
+		syncFile.ModTime = 0
+	}
+	syncFile.FileSize = fileInfo.Size()
+	syncFile.FileHash = fileHash
+	syncFile.UpdateTime = now
+	_, errSave := zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		zorm.Delete(ctx, syncFile)
+		return zorm.Insert(ctx, syncFile)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &added, errSave
+}
+
+// indexFolderSyncDocument 新增或者更新文档,并执行indexPipeline
+func indexFolderSyncDocument(ctx context.Context, documentID string, knowledgeBaseID string, filePath string, relPath string) error {
+	knowledgeBaseName, err := findKnowledgeBaseNameById(ctx, knowledgeBaseID)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Format("2006-01-02 15:04:05")
+	document := &Document{}
+	finder := zorm.NewSelectFinder(tableDocumentName).Append("WHERE id=?", documentID)
+	has, err := zorm.QueryRow(ctx, finder, document)
+	if err != nil {
+		return err
+	}
+	if !has {
+		metadata, _ := json.Marshal(map[string]string{"source": "folder_sync", "path": relPath})
+		document.Id = documentID
+		document.Metadata = string(metadata)
+		document.SortNo = funcMaxSortNo(tableDocumentName)
+		document.CreateTime = now
+	}
+	document.Name = funcLastURI(relPath)
+	document.FileExt = filepath.Ext(document.Name)
+	document.FilePath = filePath
+	document.KnowledgeBaseID = knowledgeBaseID
+	document.KnowledgeBaseName = knowledgeBaseName
+	// 清空内容,由转换组件重新读取文件
+	document.Markdown = ""
+	document.Toc = ""
+	document.Summary = ""
+	document.Status = 2
+	document.UpdateTime = now
+	_, err = zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		zorm.Delete(ctx, document)
+		return zorm.Insert(ctx, document)
+	})
+	if err != nil {
+		return err
+	}
+	_, err = updateDocumentChunk(ctx, document)
+	if err != nil {
+		// 和上传文档一样,标记为处理失败
+		zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+			finder := zorm.NewUpdateFinder(tableDocumentName).Append("status=3 WHERE id=?", documentID)
+			return zorm.UpdateFinder(ctx, finder)
+		})
+	}
+	return err
+}
+
+// deleteFolderSyncFile 文件已经删除,删除对应的文档和复制的文件
+func deleteFolderSyncFile(ctx context.Context, syncFile *FolderSyncFile) error {
+	err := funcDeleteDocumentById(ctx, syncFile.DocumentID)
+	if err != nil {
+		return err
+	}
+	os.Remove(datadir + folderSyncUploadDir + syncFile.FolderSyncID + "/" + syncFile.FilePath)
+	return deleteById(ctx, tableFolderSyncFileName, syncFile.Id)
+}
+
+// deleteFolderSync 删除目录同步,停止后台任务.已经同步的文档保留在知识库中
+func deleteFolderSync(ctx context.Context, id string) error {
+	stopFolderSync(id)
+	_, err := zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		finder := zorm.NewDeleteFinder(tableFolderSyncFileName).Append("WHERE folder_sync_id=?", id)
+		_, err := zorm.UpdateFinder(ctx, finder)
+		if err != nil {
+			return nil, err
+		}
+		finder = zorm.NewDeleteFinder(tableFolderSyncName).Append("WHERE id=?", id)
+		return zorm.UpdateFinder(ctx, finder)
+	})
+	return err
+}
+
+// folderSyncKnowledgeBaseID 子目录对应子知识库,不存在时创建,继承上级知识库的向量化配置
+func folderSyncKnowledgeBaseID(ctx context.Context, rootKnowledgeBaseID string, relDir string) (string, error) {
+	knowledgeBaseID := rootKnowledgeBaseID
+	if relDir == "." || relDir == "" {
+		return knowledgeBaseID, nil
+	}
+	parent, err := findKnowledgeBaseById(ctx, rootKnowledgeBaseID)
+	if err != nil {
+		return "", err
+	}
+	for _, name := range strings.Split(relDir, "/") {
+		id := parent.Id + name + "/"
+		knowledgeBase, err := findKnowledgeBaseById(ctx, id)
+		if err == nil {
+			parent = knowledgeBase
+			continue
+		}
+		now := time.Now().Format("2006-01-02 15:04:05")
+		knowledgeBase = &KnowledgeBase{
+			Id:                 id,
+			Name:               name,
+			Pid:                parent.Id,
+			KnowledgeBaseType:  parent.KnowledgeBaseType,
+			EmbedderID:         parent.EmbedderID,
+			EmbeddingModel:     parent.EmbeddingModel,
+			EmbeddingDimension: parent.EmbeddingDimension,
+			CreateTime:         now,
+			UpdateTime:         now,
+			SortNo:             funcMaxSortNo(tableKnowledgeBaseName),
+			Status:             1,
+		}
+		_, err = zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+			return zorm.Insert(ctx, knowledgeBase)
+		})
+		if err != nil {
+			return "", err
+		}
+		parent = knowledgeBase
+	}
+	return parent.Id, nil
+}
+
+// copyFolderSyncFile 复制文件,创建需要的目录
+func copyFolderSyncFile(src string, dst string) error {
+	err := os.MkdirAll(filepath.Dir(dst), 0755)
+	if err != nil {
+		return err
+	}
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+	dstFile, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+	_, err = io.Copy(dstFile, srcFile)
+	return err
+}
+
+// sha256File 计算文件内容的sha256
+func sha256File(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// compileFolderSyncGlobs 编译逗号或换行隔开的glob表达式
+func compileFolderSyncGlobs(globs string) ([]*regexp.Regexp, error) {
+	regs := make([]*regexp.Regexp, 0)
+	for _, glob := range strings.FieldsFunc(globs, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		glob = strings.TrimSpace(glob)
+		if glob == "" {
+			continue
+		}
+		reg, err := folderSyncGlobRegexp(glob)
+		if err != nil {
+			return nil, fmt.Errorf(funcT("Invalid glob: %s"), glob)
+		}
+		regs = append(regs, reg)
+	}
+	return regs, nil
+}
+
+// folderSyncGlobRegexp glob转换为正则表达式.支持 * ? **,不包含/的表达式匹配任意目录下的文件名
+func folderSyncGlobRegexp(glob string) (*regexp.Regexp, error) {
+	glob = strings.TrimPrefix(filepath.ToSlash(glob), "/")
+	if !strings.Contains(glob, "/") {
+		glob = "**/" + glob
+	}
+	runes := []rune(glob)
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				i++
+				if i+1 < len(runes) && runes[i+1] == '/' {
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(runes[i])))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+// matchFolderSyncGlobs 路径是否匹配任意一个表达式
+func matchFolderSyncGlobs(regs []*regexp.Regexp, relPath string) bool {
+	for _, reg := range regs {
+		if reg.MatchString(relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// folderSyncFileMatch 文件是否需要同步,排除优先,包含为空时同步所有文件
+func folderSyncFileMatch(includes []*regexp.Regexp, excludes []*regexp.Regexp, relPath string) bool {
+	if matchFolderSyncGlobs(excludes, relPath) {
+		return false
+	}
+	if len(includes) < 1 {
+		return true
+	}
+	return matchFolderSyncGlobs(includes, relPath)
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestFolderSyncFileMatch(t *testing.T) {
+	includes, err := compileFolderSyncGlobs("**/*.md,\ndocs/**/*.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	excludes, err := compileFolderSyncGlobs("**/.git/**, draft-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]bool{
+		"readme.md":              true,
+		"a/b/c.md":               true,
+		"docs/manual.pdf":        true,
+		"docs/v1/manual.pdf":     true,
+		"other/manual.pdf":       false,
+		"a/draft-plan.md":        false,
+		".git/config.md":         false,
+		"a/.git/HEAD.md":         false,
+		"a/b/c.markdown":         false,
+		"中文目录/说明.md":             true,
+		"docs/manual.pdf.backup": false,
+	}
+	for relPath, want := range tests {
+		if got := folderSyncFileMatch(includes, excludes, relPath); got != want {
+			t.Errorf("%s: got %v, want %v", relPath, got, want)
+		}
+	}
+	if !matchFolderSyncGlobs(excludes, ".git/") {
+		t.Error(".git/ directory should be excluded")
+	}
+	if !folderSyncFileMatch(nil, excludes, "any/file.txt") {
+		t.Error("empty includes should match all files")
+	}
+}
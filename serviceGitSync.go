@@ -0,0 +1,475 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"gitee.com/chunanyong/zorm"
+)
+
+const (
+	// defaultGitSyncInterval 默认的同步间隔,单位秒
+	defaultGitSyncInterval = 600
+	// defaultGitSyncExtensions 默认索引的文件后缀
+	defaultGitSyncExtensions = ".md,.markdown,.txt"
+	// gitSyncRepoDir 仓库clone的目录,相对于datadir
+	gitSyncRepoDir = "upload/git_sync/"
+	// gitSyncTimeout git命令的超时时间
+	gitSyncTimeout = 10 * time.Minute
+)
+
+var (
+	// gitSyncWorkers 运行中的git同步任务,复用目录同步的后台任务结构,key是GitSync.Id
+	gitSyncWorkers = make(map[string]*folderSyncWorker)
+	// gitSyncLock 保护gitSyncWorkers
+	gitSyncLock sync.Mutex
+)
+
+// initGitSync 启动时开启所有可用的git同步
+func initGitSync(ctx context.Context) {
+	finder := zorm.NewSelectFinder(tableGitSyncName).Append("WHERE status=1")
+	list := make([]GitSync, 0)
+	err := zorm.Query(ctx, finder, &list, nil)
+	if err != nil {
+		FuncLogError(ctx, err)
+		return
+	}
+	for i := 0; i < len(list); i++ {
+		startGitSync(ctx, &list[i])
+	}
+}
+
+// findGitSyncById 根据ID查询git同步
+func findGitSyncById(ctx context.Context, id string) (*GitSync, error) {
+	finder := zorm.NewSelectFinder(tableGitSyncName).Append("WHERE id=?", id)
+	gitSync := &GitSync{}
+	has, err := zorm.QueryRow(ctx, finder, gitSync)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, errors.New(funcT("ID does not exist"))
+	}
+	return gitSync, nil
+}
+
+// validateGitSync 校验git同步的配置,仓库地址和分支不能以-开头,避免被当作git的参数
+func validateGitSync(ctx context.Context, gitSync *GitSync) error {
+	gitSync.RepoURL = strings.TrimSpace(gitSync.RepoURL)
+	gitSync.Branch = strings.TrimSpace(gitSync.Branch)
+	if gitSync.RepoURL == "" || strings.HasPrefix(gitSync.RepoURL, "-") || strings.HasPrefix(gitSync.Branch, "-") {
+		return errors.New(funcT("Invalid repository URL or branch"))
+	}
+	if _, err := findKnowledgeBaseById(ctx, gitSync.KnowledgeBaseID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// startGitSync 开启git同步的后台任务,已经存在的任务会先停止
+func startGitSync(ctx context.Context, gitSync *GitSync) {
+	stopGitSync(gitSync.Id)
+	if gitSync.Status != 1 {
+		return
+	}
+	worker := &folderSyncWorker{stop: make(chan struct{})}
+	gitSyncLock.Lock()
+	gitSyncWorkers[gitSync.Id] = worker
+	gitSyncLock.Unlock()
+
+	interval := gitSync.SyncInterval
+	if interval <= 0 {
+		interval = defaultGitSyncInterval
+	}
+	go func() {
+		ticker := time.NewTicker(time.Second * time.Duration(interval))
+		defer ticker.Stop()
+		runGitSync(ctx, gitSync.Id)
+		for {
+			select {
+			case <-worker.stop:
+				return
+			case <-ticker.C:
+				runGitSync(ctx, gitSync.Id)
+			}
+		}
+	}()
+}
+
+// stopGitSync 停止git同步的后台任务
+func stopGitSync(id string) {
+	gitSyncLock.Lock()
+	defer gitSyncLock.Unlock()
+	worker, has := gitSyncWorkers[id]
+	if !has {
+		return
+	}
+	close(worker.stop)
+	delete(gitSyncWorkers, id)
+}
+
+// runGitSync 执行一次同步,同一个git同步正在执行时跳过
+func runGitSync(ctx context.Context, id string) error {
+	gitSyncLock.Lock()
+	worker, has := gitSyncWorkers[id]
+	gitSyncLock.Unlock()
+	if has {
+		if !worker.running.CompareAndSwap(false, true) {
+			return errors.New(funcT("Git sync is running"))
+		}
+		defer worker.running.Store(false)
+	}
+
+	gitSync, err := findGitSyncById(ctx, id)
+	if err != nil {
+		FuncLogError(ctx, err)
+		return err
+	}
+	updateGitSyncStatus(ctx, id, folderSyncStatusRunning, "", gitSync.LastCommit)
+	result, commit, err := syncGitRepo(ctx, gitSync)
+	if err != nil {
+		FuncLogError(ctx, err)
+		updateGitSyncStatus(ctx, id, folderSyncStatusFailed, err.Error(), gitSync.LastCommit)
+		return err
+	}
+	status := folderSyncStatusIdle
+	// 有失败的文件时不更新commit,下次同步重新处理
+	if result.Failed > 0 {
+		status = folderSyncStatusFailed
+		commit = gitSync.LastCommit
+	}
+	updateGitSyncStatus(ctx, id, status, result.String(), commit)
+	return nil
+}
+
+// updateGitSyncStatus 更新同步状态、结果和最近一次索引的commit
+func updateGitSyncStatus(ctx context.Context, id string, status int, message string, lastCommit string) {
+	now := time.Now().Format("2006-01-02 15:04:05")
+	_, err := zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		finder := zorm.NewUpdateFinder(tableGitSyncName).Append("sync_status=?,sync_message=?,last_commit=?,last_sync_time=? WHERE id=?", status, message, lastCommit, now, id)
+		return zorm.UpdateFinder(ctx, finder)
+	})
+	if err != nil {
+		FuncLogError(ctx, err)
+	}
+}
+
+// syncGitRepo 更新仓库,只索引上次commit到HEAD之间变化的文件,返回同步结果和HEAD的commit
+func syncGitRepo(ctx context.Context, gitSync *GitSync) (folderSyncResult, string, error) {
+	result := folderSyncResult{}
+	repoDir := datadir + gitSyncRepoDir + gitSync.Id
+	commit, err := gitCheckout(repoDir, gitSync.RepoURL, gitSync.Branch)
+	if err != nil {
+		return result, "", err
+	}
+	if commit == gitSync.LastCommit {
+		return result, commit, nil
+	}
+
+	var changed, deleted []string
+	if gitSync.LastCommit != "" {
+		changed, deleted, err = gitChangedFiles(repoDir, gitSync.LastCommit, commit)
+	}
+	// 第一次同步或者上次的commit已经不存在(例如强制推送),全量索引
+	if gitSync.LastCommit == "" || err != nil {
+		changed, err = gitListFiles(repoDir)
+		if err != nil {
+			return result, "", err
+		}
+		deleted, err = findGitSyncDeletedFiles(ctx, gitSync.Id, changed)
+		if err != nil {
+			return result, "", err
+		}
+	}
+
+	for _, relPath := range changed {
+		if !gitSyncFileMatch(gitSync.Extensions, relPath) {
+			continue
+		}
+		added, err := indexGitSyncDocument(ctx, gitSync, repoDir, relPath, commit)
+		if err != nil {
+			FuncLogError(ctx, fmt.Errorf("git sync %s: %w", relPath, err))
+			result.Failed++
+		} else if added == nil {
+			result.Skipped++
+		} else if *added {
+			result.Added++
+		} else {
+			result.Updated++
+		}
+	}
+	for _, relPath := range deleted {
+		if !gitSyncFileMatch(gitSync.Extensions, relPath) {
+			continue
+		}
+		err := funcDeleteDocumentById(ctx, gitSyncDocumentID(gitSync.Id, relPath))
+		if err != nil {
+			FuncLogError(ctx, err)
+			result.Failed++
+			continue
+		}
+		result.Deleted++
+	}
+	return result, commit, nil
+}
+
+// readGitSyncFile 读取仓库中的普通文件.路径中有符号链接或者不是普通文件时返回nil,
+// 避免远程仓库提交指向服务器文件的符号链接,把数据库或者配置文件索引到知识库
+func readGitSyncFile(repoDir string, relPath string) ([]byte, error) {
+	filePath := repoDir
+	var fileInfo os.FileInfo
+	for _, name := range strings.Split(relPath, "/") {
+		filePath = filepath.Join(filePath, name)
+		var err error
+		fileInfo, err = os.Lstat(filePath)
+		if err != nil {
+			return nil, err
+		}
+		if fileInfo.Mode()&os.ModeSymlink != 0 {
+			return nil, nil
+		}
+	}
+	if fileInfo == nil || !fileInfo.Mode().IsRegular() {
+		return nil, nil
+	}
+	return os.ReadFile(filePath)
+}
+
+// indexGitSyncDocument 读取仓库中的文件,新增或者更新文档,并执行indexPipeline.返回nil表示不是文本文件,跳过
+func indexGitSyncDocument(ctx context.Context, gitSync *GitSync, repoDir string, relPath string, commit string) (*bool, error) {
+	content, err := readGitSyncFile(repoDir, relPath)
+	if err != nil {
+		return nil, err
+	}
+	if content == nil || !utf8.Valid(content) {
+		return nil, nil
+	}
+	knowledgeBaseName, err := findKnowledgeBaseNameById(ctx, gitSync.KnowledgeBaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	documentID := gitSyncDocumentID(gitSync.Id, relPath)
+	document := &Document{}
+	finder := zorm.NewSelectFinder(tableDocumentName).Append("WHERE id=?", documentID)
+	has, err := zorm.QueryRow(ctx, finder, document)
+	if err != nil {
+		return nil, err
+	}
+	added := !has
+	if added {
+		document.Id = documentID
+		document.SortNo = funcMaxSortNo(tableDocumentName)
+		document.CreateTime = now
+	}
+	metadata, err := json.Marshal(map[string]string{
+		"source":    "git",
+		"gitSyncID": gitSync.Id,
+		"commit":    commit,
+		"path":      relPath,
+		"sourceURL": gitSourceURL(gitSync.SourceURLTemplate, commit, relPath, gitSync.Branch),
+	})
+	if err != nil {
+		return nil, err
+	}
+	document.Name = path.Base(relPath)
+	document.FileExt = path.Ext(relPath)
+	// 仓库相对路径+commit,可以定位到具体版本的文件
+	document.FilePath = relPath + "@" + commit
+	document.FileSize = len(content)
+	document.KnowledgeBaseID = gitSync.KnowledgeBaseID
+	document.KnowledgeBaseName = knowledgeBaseName
+	document.Metadata = string(metadata)
+	// 直接使用文件内容,转换组件不再读取文件
+	document.Markdown = string(content)
+	document.Toc = ""
+	document.Summary = ""
+	document.Status = 2
+	document.UpdateTime = now
+	_, err = zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		zorm.Delete(ctx, document)
+		return zorm.Insert(ctx, document)
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, err = updateDocumentChunk(ctx, document)
+	if err != nil {
+		zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+			finder := zorm.NewUpdateFinder(tableDocumentName).Append("status=3 WHERE id=?", documentID)
+			return zorm.UpdateFinder(ctx, finder)
+		})
+		return nil, err
+	}
+	return &added, nil
+}
+
+// findGitSyncDeletedFiles 全量同步时,查询已经索引但是仓库中已经不存在的文件
+func findGitSyncDeletedFiles(ctx context.Context, gitSyncID string, files []string) ([]string, error) {
+	finder := zorm.NewSelectFinder(tableDocumentName, "metadata").Append("WHERE json_extract(metadata,?)=?", "$.gitSyncID", gitSyncID)
+	metadatas := make([]string, 0)
+	err := zorm.Query(ctx, finder, &metadatas, nil)
+	if err != nil {
+		return nil, err
+	}
+	fileMap := make(map[string]bool, len(files))
+	for _, file := range files {
+		fileMap[file] = true
+	}
+	deleted := make([]string, 0)
+	for _, metadata := range metadatas {
+		metadataMap := make(map[string]string)
+		if err := json.Unmarshal([]byte(metadata), &metadataMap); err != nil {
+			continue
+		}
+		if relPath := metadataMap["path"]; relPath != "" && !fileMap[relPath] {
+			deleted = append(deleted, relPath)
+		}
+	}
+	return deleted, nil
+}
+
+// deleteGitSync 删除git同步,停止后台任务,删除clone的仓库.已经同步的文档保留在知识库中
+func deleteGitSync(ctx context.Context, id string) error {
+	stopGitSync(id)
+	err := deleteById(ctx, tableGitSyncName, id)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(datadir + gitSyncRepoDir + id)
+}
+
+// gitSyncDocumentID 根据git同步ID和文件路径生成文档ID,文件更新时使用同一个文档
+func gitSyncDocumentID(gitSyncID string, relPath string) string {
+	return sha256hex(gitSyncID + "\n" + relPath)[:32]
+}
+
+// gitSyncFileMatch 文件后缀是否需要索引,不区分大小写
+func gitSyncFileMatch(extensions string, relPath string) bool {
+	if strings.TrimSpace(extensions) == "" {
+		extensions = defaultGitSyncExtensions
+	}
+	ext := strings.ToLower(path.Ext(relPath))
+	if ext == "" {
+		return false
+	}
+	for _, extension := range strings.Split(extensions, ",") {
+		extension = strings.ToLower(strings.TrimSpace(extension))
+		if extension != "" && !strings.HasPrefix(extension, ".") {
+			extension = "." + extension
+		}
+		if extension == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// gitSourceURL 根据模板生成源文件的URL,支持 {commit} {path} {branch}
+func gitSourceURL(template string, commit string, relPath string, branch string) string {
+	if template == "" {
+		return ""
+	}
+	return strings.NewReplacer("{commit}", commit, "{path}", relPath, "{branch}", branch).Replace(template)
+}
+
+// gitCheckout 第一次clone仓库,之后fetch并重置到最新的commit,返回HEAD的commit
+func gitCheckout(repoDir string, repoURL string, branch string) (string, error) {
+	if !pathExist(filepath.Join(repoDir, ".git")) {
+		err := os.MkdirAll(filepath.Dir(repoDir), 0755)
+		if err != nil {
+			return "", err
+		}
+		args := []string{"clone", "--single-branch"}
+		if branch != "" {
+			args = append(args, "--branch", branch)
+		}
+		args = append(args, "--", repoURL, repoDir)
+		_, err = ExecGit("", gitSyncTimeout, args...)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		// 仓库地址可能修改了
+		_, err := ExecGit(repoDir, gitSyncTimeout, "remote", "set-url", "origin", repoURL)
+		if err != nil {
+			return "", err
+		}
+		ref := branch
+		if ref == "" {
+			ref = "HEAD"
+		}
+		_, err = ExecGit(repoDir, gitSyncTimeout, "fetch", "origin", ref)
+		if err != nil {
+			return "", err
+		}
+		_, err = ExecGit(repoDir, gitSyncTimeout, "reset", "--hard", "FETCH_HEAD")
+		if err != nil {
+			return "", err
+		}
+	}
+	commit, err := ExecGit(repoDir, gitSyncTimeout, "rev-parse", "HEAD")
+	return strings.TrimSpace(commit), err
+}
+
+// gitListFiles 仓库中的所有文件
+func gitListFiles(repoDir string) ([]string, error) {
+	output, err := ExecGit(repoDir, gitSyncTimeout, "ls-files", "-z")
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0)
+	for _, file := range strings.Split(output, "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// gitChangedFiles 两个commit之间新增修改和删除的文件,重命名拆分为删除和新增
+func gitChangedFiles(repoDir string, from string, to string) ([]string, []string, error) {
+	output, err := ExecGit(repoDir, gitSyncTimeout, "diff", "--name-status", "--no-renames", "-z", from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	changed := make([]string, 0)
+	deleted := make([]string, 0)
+	fields := strings.Split(strings.TrimSuffix(output, "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		status, file := fields[i], fields[i+1]
+		if strings.HasPrefix(status, "D") {
+			deleted = append(deleted, file)
+		} else {
+			changed = append(changed, file)
+		}
+	}
+	return changed, deleted, nil
+}
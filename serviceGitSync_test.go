@@ -0,0 +1,192 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGitSyncCheckout(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	bareDir := filepath.Join(dir, "repo.git")
+	workDir := filepath.Join(dir, "work")
+	repoDir := filepath.Join(dir, "clone")
+
+	git := func(dir string, args ...string) {
+		args = append([]string{"-c", "user.name=minrag", "-c", "user.email=minrag@localhost"}, args...)
+		if _, err := ExecGit(dir, time.Minute, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile := func(name string, content string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(workDir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(workDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("", "init", "--bare", "--initial-branch=main", bareDir)
+	git("", "clone", bareDir, workDir)
+	git(workDir, "checkout", "-b", "main")
+	writeFile("readme.md", "# readme")
+	writeFile("docs/a.md", "a")
+	writeFile("docs/b.txt", "b")
+	git(workDir, "add", "-A")
+	git(workDir, "commit", "-m", "init")
+	git(workDir, "push", "origin", "main")
+
+	first, err := gitCheckout(repoDir, bareDir, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := gitListFiles(repoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(files)
+	if !slices.Equal(files, []string{"docs/a.md", "docs/b.txt", "readme.md"}) {
+		t.Fatalf("files: %v", files)
+	}
+
+	writeFile("docs/a.md", "a2")
+	writeFile("docs/c.md", "c")
+	if err := os.Remove(filepath.Join(workDir, "docs/b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	git(workDir, "add", "-A")
+	git(workDir, "commit", "-m", "update")
+	git(workDir, "push", "origin", "main")
+
+	second, err := gitCheckout(repoDir, bareDir, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first || len(second) != 40 {
+		t.Fatalf("commit not updated: %s %s", first, second)
+	}
+	content, err := os.ReadFile(filepath.Join(repoDir, "docs/a.md"))
+	if err != nil || string(content) != "a2" {
+		t.Fatalf("content: %q %v", content, err)
+	}
+
+	changed, deleted, err := gitChangedFiles(repoDir, first, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(changed)
+	if !slices.Equal(changed, []string{"docs/a.md", "docs/c.md"}) {
+		t.Errorf("changed: %v", changed)
+	}
+	if !slices.Equal(deleted, []string{"docs/b.txt"}) {
+		t.Errorf("deleted: %v", deleted)
+	}
+
+	if _, _, err := gitChangedFiles(repoDir, "0000000000000000000000000000000000000000", second); err == nil {
+		t.Error("unknown commit should return error")
+	}
+}
+
+func TestGitSyncFileMatch(t *testing.T) {
+	tests := map[string]bool{
+		"readme.md":       true,
+		"docs/A.MD":       true,
+		"notes.txt":       true,
+		"main.go":         false,
+		"Makefile":        false,
+		"docs/x.markdown": true,
+	}
+	for relPath, want := range tests {
+		if got := gitSyncFileMatch("", relPath); got != want {
+			t.Errorf("%s: got %v, want %v", relPath, got, want)
+		}
+	}
+	if !gitSyncFileMatch("go, .MOD", "main.go") || !gitSyncFileMatch("go, .MOD", "go.mod") || gitSyncFileMatch("go", "readme.md") {
+		t.Error("custom extensions not matched")
+	}
+}
+
+func TestGitSourceURL(t *testing.T) {
+	got := gitSourceURL("https://github.com/org/repo/blob/{commit}/{path}?ref={branch}", "abc", "docs/a.md", "main")
+	if got != "https://github.com/org/repo/blob/abc/docs/a.md?ref=main" {
+		t.Errorf("got %s", got)
+	}
+	if gitSourceURL("", "abc", "a.md", "main") != "" {
+		t.Error("empty template should return empty url")
+	}
+}
+
+func TestReadGitSyncFileSymlink(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	bareDir := filepath.Join(dir, "repo.git")
+	workDir := filepath.Join(dir, "work")
+	repoDir := filepath.Join(dir, "clone")
+	secret := filepath.Join(dir, "minrag.db")
+	if err := os.WriteFile(secret, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	git := func(dir string, args ...string) {
+		args = append([]string{"-c", "user.name=minrag", "-c", "user.email=minrag@localhost"}, args...)
+		if _, err := ExecGit(dir, time.Minute, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("", "init", "--bare", "--initial-branch=main", bareDir)
+	git("", "clone", bareDir, workDir)
+	git(workDir, "checkout", "-b", "main")
+	if err := os.WriteFile(filepath.Join(workDir, "readme.md"), []byte("# readme"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 符号链接文件和符号链接目录都指向仓库外的文件
+	if err := os.Symlink(secret, filepath.Join(workDir, "notes.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(dir, filepath.Join(workDir, "docs")); err != nil {
+		t.Fatal(err)
+	}
+	git(workDir, "add", "-A")
+	git(workDir, "commit", "-m", "symlink")
+	git(workDir, "push", "origin", "main")
+
+	if _, err := gitCheckout(repoDir, bareDir, "main"); err != nil {
+		t.Fatal(err)
+	}
+	content, err := readGitSyncFile(repoDir, "readme.md")
+	if err != nil || string(content) != "# readme" {
+		t.Fatalf("readme.md: %q %v", content, err)
+	}
+	for _, relPath := range []string{"notes.md", "docs/minrag.db"} {
+		content, err := readGitSyncFile(repoDir, relPath)
+		if err != nil || content != nil {
+			t.Errorf("%s: symlink should be skipped, got %q %v", relPath, content, err)
+		}
+	}
+}
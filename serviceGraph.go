@@ -0,0 +1,380 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"gitee.com/chunanyong/zorm"
+)
+
+// replaceDocumentGraph 替换文档的知识图谱,需要在事务中调用.先删除文档原有的关系和节点关联,再保存新的,同名节点合并属性
+func replaceDocumentGraph(ctx context.Context, document *Document, graphs []GraphData) error {
+	err := deleteDocumentGraph(ctx, document.Id)
+	if err != nil || len(graphs) < 1 {
+		return err
+	}
+	now := time.Now().Format("2006-01-02 15:04:05")
+	nodeIDs := make(map[string]string)
+	entities := make([]zorm.IEntityStruct, 0)
+	for _, graph := range graphs {
+		for _, graphNode := range graph.Node {
+			nodeID, err := saveKnowledgeGraphNode(ctx, document.KnowledgeBaseID, graphNode, nodeIDs, now)
+			if err != nil {
+				return err
+			}
+			for _, chunkID := range graphNode.Chunks {
+				entities = append(entities, &KnowledgeGraphNodeChunk{Id: FuncGenerateStringID(), KnowledgeBaseID: document.KnowledgeBaseID, DocumentID: document.Id, DocumentChunkID: chunkID, NodeID: nodeID})
+			}
+		}
+		for _, relation := range graph.Relation {
+			sourceNodeID, targetNodeID := nodeIDs[relation.Node1], nodeIDs[relation.Node2]
+			if sourceNodeID == "" || targetNodeID == "" {
+				continue
+			}
+			entities = append(entities, &KnowledgeGraphEdge{Id: FuncGenerateStringID(), KnowledgeBaseID: document.KnowledgeBaseID, DocumentID: document.Id, DocumentChunkID: graph.DocumentChunkID, SourceNodeID: sourceNodeID, TargetNodeID: targetNodeID, Relation: relation.Type, CreateTime: now})
+		}
+	}
+	if len(entities) < 1 {
+		return nil
+	}
+	_, err = zorm.InsertSlice(ctx, entities)
+	return err
+}
+
+// saveKnowledgeGraphNode 保存节点,知识库中已经有同名节点时合并属性,返回节点ID.nodeIDs记录节点名称对应的ID,用于保存关系
+func saveKnowledgeGraphNode(ctx context.Context, knowledgeBaseID string, graphNode GraphNode, nodeIDs map[string]string, now string) (string, error) {
+	node := &KnowledgeGraphNode{}
+	finder := zorm.NewSelectFinder(tableKnowledgeGraphNodeName).Append("WHERE knowledge_base_id=? and name=?", knowledgeBaseID, graphNode.Name)
+	has, err := zorm.QueryRow(ctx, finder, node)
+	if err != nil {
+		return "", err
+	}
+	if !has {
+		attributes, _ := json.Marshal(mergeGraphAttributes(nil, graphNode.Attributes))
+		node = &KnowledgeGraphNode{Id: FuncGenerateStringID(), KnowledgeBaseID: knowledgeBaseID, Name: graphNode.Name, Attributes: string(attributes), CreateTime: now, UpdateTime: now}
+		_, err = zorm.Insert(ctx, node)
+		nodeIDs[graphNode.Name] = node.Id
+		return node.Id, err
+	}
+	nodeIDs[graphNode.Name] = node.Id
+	oldAttributes := knowledgeGraphNodeAttributes(node)
+	merged := mergeGraphAttributes(oldAttributes, graphNode.Attributes)
+	if len(merged) == len(oldAttributes) {
+		return node.Id, nil
+	}
+	attributes, _ := json.Marshal(merged)
+	finder = zorm.NewUpdateFinder(tableKnowledgeGraphNodeName).Append("attributes=?,update_time=? WHERE id=?", string(attributes), now, node.Id)
+	_, err = zorm.UpdateFinder(ctx, finder)
+	return node.Id, err
+}
+
+// knowledgeGraphNodeAttributes 解析节点属性的json数组
+func knowledgeGraphNodeAttributes(node *KnowledgeGraphNode) []string {
+	attributes := make([]string, 0)
+	if node.Attributes != "" {
+		json.Unmarshal([]byte(node.Attributes), &attributes)
+	}
+	return attributes
+}
+
+// deleteDocumentGraph 删除文档的关系和节点关联,没有关联其他分块的节点一起删除,需要在事务中调用
+func deleteDocumentGraph(ctx context.Context, documentID string) error {
+	finder := zorm.NewSelectFinder(tableKnowledgeGraphNodeChunkName, "DISTINCT node_id").Append("WHERE document_id=?", documentID)
+	finder.SelectTotalCount = false
+	nodeIDs := make([]string, 0)
+	err := zorm.Query(ctx, finder, &nodeIDs, nil)
+	if err != nil {
+		return err
+	}
+	for _, tableName := range []string{tableKnowledgeGraphEdgeName, tableKnowledgeGraphNodeChunkName} {
+		finder = zorm.NewDeleteFinder(tableName).Append("WHERE document_id=?", documentID)
+		_, err = zorm.UpdateFinder(ctx, finder)
+		if err != nil {
+			return err
+		}
+	}
+	if len(nodeIDs) < 1 {
+		return nil
+	}
+	finder = zorm.NewDeleteFinder(tableKnowledgeGraphNodeName).Append("WHERE id IN (?) and id NOT IN (SELECT node_id FROM "+tableKnowledgeGraphNodeChunkName+" WHERE node_id IN (?))", nodeIDs, nodeIDs)
+	_, err = zorm.UpdateFinder(ctx, finder)
+	return err
+}
+
+// findKnowledgeGraphNodesByNames 在知识库和子知识库中查询实体对应的节点,先精确匹配名称,没有匹配的实体再模糊匹配
+func findKnowledgeGraphNodesByNames(ctx context.Context, knowledgeBaseID string, names []string) ([]KnowledgeGraphNode, error) {
+	nodes := make([]KnowledgeGraphNode, 0)
+	if len(names) < 1 {
+		return nodes, nil
+	}
+	finder := zorm.NewSelectFinder(tableKnowledgeGraphNodeName, "id,knowledge_base_id,name").Append("WHERE knowledge_base_id like ? and name IN (?)", knowledgeBaseID+"%", names)
+	finder.SelectTotalCount = false
+	err := zorm.Query(ctx, finder, &nodes, nil)
+	if err != nil {
+		return nodes, err
+	}
+	matched := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		matched[node.Name] = true
+	}
+	for _, name := range names {
+		if matched[name] {
+			continue
+		}
+		// 模糊匹配每个实体最多取3个节点,避免短名称匹配太多节点
+		finder = zorm.NewSelectFinder(tableKnowledgeGraphNodeName, "id,knowledge_base_id,name").Append("WHERE knowledge_base_id like ? and name like ?", knowledgeBaseID+"%", "%"+name+"%")
+		finder.SelectTotalCount = false
+		page := zorm.NewPage()
+		page.PageSize = 3
+		list := make([]KnowledgeGraphNode, 0)
+		err = zorm.Query(ctx, finder, &list, page)
+		if err != nil {
+			return nodes, err
+		}
+		nodes = append(nodes, list...)
+	}
+	return nodes, nil
+}
+
+// graphChunkFilterSQL 知识图谱检索的分块过滤子查询,使用input中的元数据过滤和时间点,没有过滤条件时返回空字符串
+func graphChunkFilterSQL(input map[string]any) (string, []any, error) {
+	metadataFilter, err := inputMetadataFilter(input, "")
+	if err != nil {
+		return "", nil, err
+	}
+	asOf, err := inputAsOf(input, "")
+	if err != nil {
+		return "", nil, err
+	}
+	whereSQL, whereValues, err := metadataFilterSQL(metadataFilter, "metadata")
+	if err != nil {
+		return "", nil, err
+	}
+	if whereSQL == "" && asOf == "" {
+		return "", nil, nil
+	}
+	conditions := make([]string, 0, 2)
+	values := make([]any, 0, len(whereValues)+1)
+	if whereSQL != "" {
+		conditions = append(conditions, "("+whereSQL+")")
+		values = append(values, whereValues...)
+	}
+	if asOf != "" {
+		conditions = append(conditions, "document_id IN (SELECT id FROM "+tableDocumentName+" WHERE "+documentValidFromSQL+" <= ?)")
+		values = append(values, asOf)
+	}
+	return "SELECT id FROM " + tableDocumentChunkName + " WHERE " + strings.Join(conditions, " and "), values, nil
+}
+
+// walkKnowledgeGraph 从匹配的节点出发走hops跳,返回经过的节点和跳数(匹配的节点是0),以及经过的关系,最多maxRelations条关系.
+// 只经过抽取自满足input中元数据过滤和时间点的分块的关系,避免返回被过滤文档的关系
+func walkKnowledgeGraph(ctx context.Context, input map[string]any, knowledgeBaseID string, seeds []KnowledgeGraphNode, hops int, maxRelations int) (map[string]int, []KnowledgeGraphEdge, error) {
+	distances := make(map[string]int, len(seeds))
+	frontier := make([]string, 0, len(seeds))
+	for _, seed := range seeds {
+		if _, has := distances[seed.Id]; has {
+			continue
+		}
+		distances[seed.Id] = 0
+		frontier = append(frontier, seed.Id)
+	}
+	edges := make([]KnowledgeGraphEdge, 0)
+	edgeSeen := make(map[string]bool)
+	chunkFilterSQL, chunkFilterValues, err := graphChunkFilterSQL(input)
+	if err != nil {
+		return distances, edges, err
+	}
+	for hop := 1; hop <= hops && len(frontier) > 0 && len(edges) < maxRelations; hop++ {
+		finder := zorm.NewSelectFinder(tableKnowledgeGraphEdgeName, "id,source_node_id,target_node_id,relation").Append("WHERE knowledge_base_id like ? and (source_node_id IN (?) or target_node_id IN (?))", knowledgeBaseID+"%", frontier, frontier)
+		if chunkFilterSQL != "" {
+			finder.Append("and document_chunk_id IN ("+chunkFilterSQL+")", chunkFilterValues...)
+		}
+		finder.SelectTotalCount = false
+		list := make([]KnowledgeGraphEdge, 0)
+		err := zorm.Query(ctx, finder, &list, nil)
+		if err != nil {
+			return distances, edges, err
+		}
+		next := make([]string, 0)
+		for _, edge := range list {
+			if len(edges) >= maxRelations {
+				break
+			}
+			// 同一个关系可能从多个分块抽取,只保留一条
+			key := edge.SourceNodeID + "|" + edge.Relation + "|" + edge.TargetNodeID
+			if edgeSeen[key] {
+				continue
+			}
+			edgeSeen[key] = true
+			edges = append(edges, edge)
+			for _, nodeID := range []string{edge.SourceNodeID, edge.TargetNodeID} {
+				if _, has := distances[nodeID]; !has {
+					distances[nodeID] = hop
+					next = append(next, nodeID)
+				}
+			}
+		}
+		frontier = next
+	}
+	return distances, edges, nil
+}
+
+// findKnowledgeGraphRelations 查询关系两端节点的名称,转换为GraphRelation
+func findKnowledgeGraphRelations(ctx context.Context, edges []KnowledgeGraphEdge) ([]GraphRelation, error) {
+	relations := make([]GraphRelation, 0, len(edges))
+	if len(edges) < 1 {
+		return relations, nil
+	}
+	nodeIDs := make([]string, 0, len(edges)*2)
+	for _, edge := range edges {
+		nodeIDs = append(nodeIDs, edge.SourceNodeID, edge.TargetNodeID)
+	}
+	names, err := findKnowledgeGraphNodeNames(ctx, nodeIDs)
+	if err != nil {
+		return relations, err
+	}
+	for _, edge := range edges {
+		relations = append(relations, GraphRelation{Node1: names[edge.SourceNodeID], Node2: names[edge.TargetNodeID], Type: edge.Relation})
+	}
+	return relations, nil
+}
+
+// findKnowledgeGraphNodeNames 查询节点的名称,key是节点ID
+func findKnowledgeGraphNodeNames(ctx context.Context, nodeIDs []string) (map[string]string, error) {
+	names := make(map[string]string, len(nodeIDs))
+	if len(nodeIDs) < 1 {
+		return names, nil
+	}
+	finder := zorm.NewSelectFinder(tableKnowledgeGraphNodeName, "id,name").Append("WHERE id IN (?)", nodeIDs)
+	finder.SelectTotalCount = false
+	nodes := make([]KnowledgeGraphNode, 0)
+	err := zorm.Query(ctx, finder, &nodes, nil)
+	if err != nil {
+		return names, err
+	}
+	for _, node := range nodes {
+		names[node.Id] = node.Name
+	}
+	return names, nil
+}
+
+// findKnowledgeGraphNodeChunks 查询节点关联的分块
+func findKnowledgeGraphNodeChunks(ctx context.Context, distances map[string]int) ([]KnowledgeGraphNodeChunk, error) {
+	nodeChunks := make([]KnowledgeGraphNodeChunk, 0)
+	if len(distances) < 1 {
+		return nodeChunks, nil
+	}
+	nodeIDs := make([]string, 0, len(distances))
+	for nodeID := range distances {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Strings(nodeIDs)
+	finder := zorm.NewSelectFinder(tableKnowledgeGraphNodeChunkName, "node_id,document_chunk_id").Append("WHERE node_id IN (?)", nodeIDs)
+	finder.SelectTotalCount = false
+	err := zorm.Query(ctx, finder, &nodeChunks, nil)
+	return nodeChunks, err
+}
+
+// findGraphDocumentChunks 查询知识图谱检索到的分块,使用input中的元数据过滤和时间点,按照分数降序返回前topN个
+func findGraphDocumentChunks(ctx context.Context, input map[string]any, scores map[string]float32, topN int) ([]DocumentChunk, error) {
+	documentChunks := make([]DocumentChunk, 0)
+	if len(scores) < 1 {
+		return documentChunks, nil
+	}
+	finder := zorm.NewSelectFinder(tableDocumentChunkName, "id,document_id,knowledge_base_id,title,markdown,sortno").Append("WHERE id IN (?)", topGraphChunkIDs(scores, 0))
+	chunkFilterSQL, chunkFilterValues, err := graphChunkFilterSQL(input)
+	if err != nil {
+		return documentChunks, err
+	}
+	if chunkFilterSQL != "" {
+		finder.Append("and id IN ("+chunkFilterSQL+")", chunkFilterValues...)
+	}
+	finder.SelectTotalCount = false
+	list := make([]DocumentChunk, 0)
+	err = zorm.Query(ctx, finder, &list, nil)
+	if err != nil {
+		return documentChunks, err
+	}
+	chunkMap := make(map[string]DocumentChunk, len(list))
+	filtered := make(map[string]float32, len(list))
+	for _, documentChunk := range list {
+		chunkMap[documentChunk.Id] = documentChunk
+		filtered[documentChunk.Id] = scores[documentChunk.Id]
+	}
+	for _, id := range topGraphChunkIDs(filtered, topN) {
+		documentChunk := chunkMap[id]
+		documentChunk.Score = filtered[id]
+		documentChunks = append(documentChunks, documentChunk)
+	}
+	return documentChunks, nil
+}
+
+// graphNodeNeighbor 后台知识图谱浏览页面显示的相邻节点
+type graphNodeNeighbor struct {
+	// Outgoing 是否是当前节点指向相邻节点的关系
+	Outgoing        bool
+	Relation        string
+	NodeID          string
+	NodeName        string
+	DocumentID      string
+	DocumentChunkID string
+}
+
+// findKnowledgeGraphNodeNeighbors 查询节点的所有关系和相邻节点,用于后台浏览知识图谱
+func findKnowledgeGraphNodeNeighbors(ctx context.Context, nodeID string) ([]graphNodeNeighbor, error) {
+	neighbors := make([]graphNodeNeighbor, 0)
+	finder := zorm.NewSelectFinder(tableKnowledgeGraphEdgeName).Append("WHERE source_node_id=? or target_node_id=? order by relation asc", nodeID, nodeID)
+	finder.SelectTotalCount = false
+	edges := make([]KnowledgeGraphEdge, 0)
+	err := zorm.Query(ctx, finder, &edges, nil)
+	if err != nil || len(edges) < 1 {
+		return neighbors, err
+	}
+	nodeIDs := make([]string, 0, len(edges))
+	for _, edge := range edges {
+		nodeIDs = append(nodeIDs, edge.SourceNodeID, edge.TargetNodeID)
+	}
+	names, err := findKnowledgeGraphNodeNames(ctx, nodeIDs)
+	if err != nil {
+		return neighbors, err
+	}
+	for _, edge := range edges {
+		neighbor := graphNodeNeighbor{Outgoing: edge.SourceNodeID == nodeID, Relation: edge.Relation, DocumentID: edge.DocumentID, DocumentChunkID: edge.DocumentChunkID}
+		neighbor.NodeID = edge.TargetNodeID
+		if !neighbor.Outgoing {
+			neighbor.NodeID = edge.SourceNodeID
+		}
+		neighbor.NodeName = names[neighbor.NodeID]
+		neighbors = append(neighbors, neighbor)
+	}
+	return neighbors, nil
+}
+
+// findKnowledgeGraphNodeDocumentChunks 查询节点关联的分块,用于后台浏览知识图谱
+func findKnowledgeGraphNodeDocumentChunks(ctx context.Context, nodeID string) ([]DocumentChunk, error) {
+	finder := zorm.NewSelectFinder(tableDocumentChunkName, "id,document_id,knowledge_base_id,title,markdown,sortno").Append("WHERE id IN (SELECT document_chunk_id FROM "+tableKnowledgeGraphNodeChunkName+" WHERE node_id=?) order by document_id asc,sortno asc", nodeID)
+	finder.SelectTotalCount = false
+	documentChunks := make([]DocumentChunk, 0)
+	err := zorm.Query(ctx, finder, &documentChunks, nil)
+	return documentChunks, err
+}
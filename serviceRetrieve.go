@@ -0,0 +1,234 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gitee.com/chunanyong/zorm"
+)
+
+// RetrieveRequestBody /v1/retrieve 检索接口的请求参数,只检索和重排序,不请求大模型生成回答
+type RetrieveRequestBody struct {
+	// Query 检索的问题
+	Query string `json:"query,omitempty"`
+	// KnowledgeBaseIDs 检索的知识库ID,只能是智能体知识库和下级知识库,为空时使用智能体的知识库
+	KnowledgeBaseIDs []string `json:"knowledge_base_ids,omitempty"`
+	// TopN 返回多少条,默认5
+	TopN int `json:"top_n,omitempty"`
+	// MetadataFilter 本次请求的元数据过滤表达式,和智能体的过滤表达式同时生效
+	MetadataFilter string `json:"metadata_filter,omitempty"`
+	// AsOf 本次请求检索的时间点,检索这个时间点有效的文档版本
+	AsOf string `json:"as_of,omitempty"`
+	// PipelineID 检索流水线ID,只能包含检索和重排序组件,为空时使用混合检索
+	PipelineID string `json:"pipeline_id,omitempty"`
+}
+
+// RetrieveResult 检索结果,包含分块内容,分数,文档信息和关键字高亮的片段
+type RetrieveResult struct {
+	CitationSource
+	// Markdown 分块内容
+	Markdown string `json:"markdown,omitempty"`
+	// Score 按照每个知识库内的排名计算的倒数排名分数,越大越相关
+	Score float32 `json:"score"`
+	// SourceScores 混合检索时各个检索器的原始分数
+	SourceScores map[string]float32 `json:"sourceScores,omitempty"`
+	// Highlight 关键字高亮的片段,使用<mark>标签
+	Highlight string `json:"highlight,omitempty"`
+}
+
+// retrieveComponentAllowed 检索流水线只能包含向量化问题,检索和重排序的组件
+func retrieveComponentAllowed(component IComponent) bool {
+	switch component.(type) {
+	case *OpenAITextEmbedder, *LKETextEmbedder, *HyDEEmbedder, *QueryRewriter,
+		*VecEmbeddingRetriever, *FtsKeywordRetriever, *HybridRetriever, *MarkdownRetriever, *GraphRetriever, *ContextExpander,
+		*DocumentChunkReranker, *QianFanDocumentChunkReranker, *BaiLianDocumentChunkReranker, *LKEDocumentChunkReranker, *MMRReranker:
+		return true
+	}
+	return false
+}
+
+// retrieveDocumentChunks 使用智能体的知识库和元数据过滤检索分块,多个知识库分别检索后按照排名合并
+func retrieveDocumentChunks(ctx context.Context, agent *Agent, body *RetrieveRequestBody) ([]RetrieveResult, error) {
+	if strings.TrimSpace(body.Query) == "" {
+		return nil, errors.New(funcT("input['query'] cannot be empty"))
+	}
+	topN := body.TopN
+	if topN <= 0 {
+		topN = 5
+	}
+	knowledgeBaseIDs := body.KnowledgeBaseIDs
+	if len(knowledgeBaseIDs) < 1 {
+		knowledgeBaseIDs = []string{agent.KnowledgeBaseID}
+	}
+	// 请求的知识库只能进一步缩小智能体的检索范围
+	for _, knowledgeBaseID := range knowledgeBaseIDs {
+		if !strings.HasPrefix(knowledgeBaseID, agent.KnowledgeBaseID) {
+			return nil, fmt.Errorf(funcT("The knowledge base %s is not allowed for this agent"), knowledgeBaseID)
+		}
+	}
+	kbDocumentChunks := make([][]DocumentChunk, 0, len(knowledgeBaseIDs))
+	for _, knowledgeBaseID := range knowledgeBaseIDs {
+		input := map[string]any{"query": body.Query, "agentID": agent.Id, "knowledgeBaseID": knowledgeBaseID, "topN": topN}
+		metadataFilter, err := joinMetadataFilter(agent.MetadataFilter, body.MetadataFilter)
+		if err != nil {
+			return nil, err
+		}
+		if metadataFilter != "" {
+			input["metadataFilter"] = metadataFilter
+		}
+		if body.AsOf != "" {
+			input["asOf"] = body.AsOf
+		}
+		if body.PipelineID != "" {
+			// 流水线运行后会记录组件状态,每个知识库使用新的流水线实例
+			var pipeline *Pipeline
+			pipeline, err = findRetrievePipeline(ctx, body.PipelineID, input)
+			if err == nil {
+				err = pipeline.Run(ctx, input)
+			}
+		} else {
+			err = runDefaultRetrieve(ctx, input)
+		}
+		if err == nil && input[errorKey] != nil {
+			err = input[errorKey].(error)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if documentChunks, ok := input["documentChunks"].([]DocumentChunk); ok {
+			kbDocumentChunks = append(kbDocumentChunks, documentChunks)
+		}
+	}
+	documentChunks := mergeRetrieveDocumentChunks(kbDocumentChunks, topN)
+
+	sources, err := buildCitationSources(ctx, documentChunks)
+	if err != nil {
+		return nil, err
+	}
+	highlights, err := findDocumentChunkHighlights(ctx, body.Query, agent.KnowledgeBaseID, documentChunks)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]RetrieveResult, 0, len(documentChunks))
+	for i, documentChunk := range documentChunks {
+		results = append(results, RetrieveResult{
+			CitationSource: sources[i],
+			Markdown:       documentChunk.Markdown,
+			Score:          documentChunk.Score,
+			SourceScores:   documentChunk.SourceScores,
+			Highlight:      highlights[documentChunk.Id],
+		})
+	}
+	return results, nil
+}
+
+// findRetrievePipeline 查询检索流水线,只能包含检索相关的组件
+func findRetrievePipeline(ctx context.Context, pipelineID string, input map[string]any) (*Pipeline, error) {
+	pipeline, err := findPipelineById(ctx, pipelineID, input)
+	if err != nil {
+		return nil, err
+	}
+	if pipeline == nil || len(pipeline.DownStream) < 1 {
+		return nil, fmt.Errorf(funcT("The %s component of the pipeline does not exist"), pipelineID)
+	}
+	for id, pipelineComponent := range pipeline.pipelineComponentMap {
+		if pipelineComponent.Component == nil {
+			continue
+		}
+		if !retrieveComponentAllowed(pipelineComponent.Component) {
+			return nil, fmt.Errorf(funcT("The %s component cannot be used in the retrieval pipeline"), id)
+		}
+	}
+	return pipeline, nil
+}
+
+// runDefaultRetrieve 默认的检索,向量化问题后使用混合检索
+func runDefaultRetrieve(ctx context.Context, input map[string]any) error {
+	// 知识库指定了向量化组件时由向量检索组件向量化,默认的向量化组件不存在时只使用知识库的向量化组件
+	if embedder, err := findEmbedder("OpenAITextEmbedder"); err == nil {
+		embedding, _, err := embedder.Embedding(ctx, input["query"].(string))
+		if err != nil {
+			return err
+		}
+		input["embedding"] = embedding
+	}
+	hybridRetriever := &HybridRetriever{}
+	hybridRetriever.Initialization(ctx, input)
+	return hybridRetriever.Run(ctx, input)
+}
+
+// retrieveRRFK 合并多个知识库结果时倒数排名融合的平滑常数k,和HybridRetriever默认值一致
+const retrieveRRFK = 60
+
+// mergeRetrieveDocumentChunks 合并多个知识库的检索结果,按照分块ID去重,返回前topN条.
+// 流水线输出的分块已经按照从好到差排序,但是分数含义不同,例如向量检索的距离越小越好,
+// 所以按照每个知识库内的排名重新计算倒数排名分数,分数越大越相关
+func mergeRetrieveDocumentChunks(kbDocumentChunks [][]DocumentChunk, topN int) []DocumentChunk {
+	documentChunks := make([]DocumentChunk, 0)
+	seen := make(map[string]bool)
+	for _, chunks := range kbDocumentChunks {
+		rank := 0
+		for _, documentChunk := range chunks {
+			if seen[documentChunk.Id] {
+				continue
+			}
+			seen[documentChunk.Id] = true
+			documentChunk.Score = 1.0 / float32(retrieveRRFK+rank+1)
+			documentChunks = append(documentChunks, documentChunk)
+			rank++
+		}
+	}
+	sort.SliceStable(documentChunks, func(i, j int) bool {
+		return documentChunks[i].Score > documentChunks[j].Score
+	})
+	if len(documentChunks) > topN {
+		documentChunks = documentChunks[:topN]
+	}
+	return documentChunks
+}
+
+// findDocumentChunkHighlights 使用全文检索的snippet函数生成关键字高亮的片段,没有匹配关键字的分块没有片段
+func findDocumentChunkHighlights(ctx context.Context, query string, knowledgeBaseID string, documentChunks []DocumentChunk) (map[string]string, error) {
+	highlights := make(map[string]string, len(documentChunks))
+	if len(documentChunks) < 1 {
+		return highlights, nil
+	}
+	ids := make([]string, 0, len(documentChunks))
+	for _, documentChunk := range documentChunks {
+		ids = append(ids, documentChunk.Id)
+	}
+	ftsTable, matchSQL, matchValue := ftsMatch(ftsQueryLanguage(ctx, knowledgeBaseID, query), query)
+	// markdown是全文检索表的第5个字段,索引为4
+	finder := zorm.NewFinder().Append("SELECT id,snippet("+ftsTable+",4,'<mark>','</mark>','...',32) as markdown from "+ftsTable+" where "+matchSQL, matchValue)
+	finder.Append(" and id in (?)", ids)
+	finder.SelectTotalCount = false
+	list := make([]DocumentChunk, 0)
+	err := zorm.Query(ctx, finder, &list, nil)
+	if err != nil {
+		return highlights, err
+	}
+	for _, documentChunk := range list {
+		highlights[documentChunk.Id] = documentChunk.Markdown
+	}
+	return highlights, nil
+}
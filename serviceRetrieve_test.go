@@ -0,0 +1,74 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestMergeRetrieveDocumentChunks(t *testing.T) {
+	// 两个知识库的检索结果,已经按照从好到差排序,c2在第一个知识库重复出现
+	kbDocumentChunks := [][]DocumentChunk{
+		{{Id: "c2", Score: 0.8}, {Id: "c1", Score: 0.3}, {Id: "c2", Score: 0.2}},
+		{{Id: "c3", Score: 12.5}, {Id: "c4", Score: 9.1}},
+	}
+	merged := mergeRetrieveDocumentChunks(kbDocumentChunks, 3)
+	if len(merged) != 3 {
+		t.Fatalf("unexpected length %d", len(merged))
+	}
+	// 排名相同的分块保持知识库顺序
+	if merged[0].Id != "c2" || merged[1].Id != "c3" || merged[2].Id != "c1" {
+		t.Errorf("unexpected order %v", merged)
+	}
+	if merged[0].Score != 1.0/61 || merged[2].Score != 1.0/62 {
+		t.Errorf("unexpected score %v", merged)
+	}
+	merged = mergeRetrieveDocumentChunks(kbDocumentChunks, 1)
+	if len(merged) != 1 || merged[0].Id != "c2" {
+		t.Errorf("unexpected topN result %v", merged)
+	}
+}
+
+func TestMergeRetrieveDocumentChunksVector(t *testing.T) {
+	// 只有VecEmbeddingRetriever的流水线,分数是向量距离,越小越好
+	kbDocumentChunks := [][]DocumentChunk{
+		{{Id: "near", Score: 0.1}, {Id: "middle", Score: 0.5}, {Id: "far", Score: 1.2}},
+	}
+	merged := mergeRetrieveDocumentChunks(kbDocumentChunks, 3)
+	if len(merged) != 3 || merged[0].Id != "near" || merged[1].Id != "middle" || merged[2].Id != "far" {
+		t.Fatalf("unexpected order %v", merged)
+	}
+	for i := 1; i < len(merged); i++ {
+		if merged[i-1].Score <= merged[i].Score {
+			t.Errorf("score should be higher for more relevant chunks %v", merged)
+		}
+	}
+}
+
+func TestRetrieveComponentAllowed(t *testing.T) {
+	for _, component := range []IComponent{&VecEmbeddingRetriever{}, &HybridRetriever{}, &MMRReranker{}, &OpenAITextEmbedder{}} {
+		if !retrieveComponentAllowed(component) {
+			t.Errorf("%T should be allowed", component)
+		}
+	}
+	for _, component := range []IComponent{&OpenAIChatGenerator{}, &ChatMessageLogStore{}, &WebSearch{}, &SQLiteVecDocumentStore{}, &Pipeline{}} {
+		if retrieveComponentAllowed(component) {
+			t.Errorf("%T should not be allowed", component)
+		}
+	}
+}
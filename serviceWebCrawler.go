@@ -0,0 +1,689 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gitee.com/chunanyong/zorm"
+)
+
+const (
+	// defaultCrawlDelay 同一个域名两次请求的默认间隔,单位秒
+	defaultCrawlDelay = 1
+	// defaultHostConcurrency 同一个域名的默认并发数
+	defaultHostConcurrency = 2
+	// defaultCrawlMaxPages sitemap抓取的默认最大网页数
+	defaultCrawlMaxPages = 1000
+	// maxSitemapDepth sitemap索引文件最多嵌套的层数
+	maxSitemapDepth = 3
+	// maxCrawlBodySize 抓取的网页和sitemap的最大字节数
+	maxCrawlBodySize = 20 << 20
+)
+
+// webCrawler sitemap爬虫,遵守robots.txt,限制每个域名的并发和请求间隔
+type webCrawler struct {
+	webScraper *WebScraper
+	client     *http.Client
+	// robots 每个域名的robots.txt规则,key是 scheme://host
+	robots map[string]*robotsRules
+	lock   sync.Mutex
+	// sitemapFailed 有sitemap索引文件读取失败,抓取的网页不完整
+	sitemapFailed bool
+}
+
+// webCrawlHost 同一个域名的请求间隔控制
+type webCrawlHost struct {
+	delay time.Duration
+	next  time.Time
+	lock  sync.Mutex
+}
+
+// wait 等待到允许请求的时间
+func (host *webCrawlHost) wait(ctx context.Context) error {
+	host.lock.Lock()
+	now := time.Now()
+	start := host.next
+	if start.Before(now) {
+		start = now
+	}
+	host.next = start.Add(host.delay)
+	host.lock.Unlock()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Until(start)):
+		return nil
+	}
+}
+
+// webCrawlPageContextKey 抓取网页的请求,重定向时需要检查路径前缀和robots.txt
+type webCrawlPageContextKey struct{}
+
+// maxCrawlRedirects 最多跟随的重定向次数
+const maxCrawlRedirects = 10
+
+// newWebCrawler 创建sitemap爬虫
+func newWebCrawler(webScraper *WebScraper) *webCrawler {
+	crawler := &webCrawler{
+		webScraper: webScraper,
+		robots:     make(map[string]*robotsRules),
+	}
+	crawler.client = &http.Client{Timeout: time.Duration(webScraper.Timeout) * time.Second, CheckRedirect: crawler.checkRedirect}
+	return crawler
+}
+
+// checkRedirect 重定向的网址也必须是允许的域名,网页的重定向还需要符合路径前缀和目标域名的robots.txt,避免绕过抓取范围
+func (crawler *webCrawler) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxCrawlRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxCrawlRedirects)
+	}
+	if _, ok := crawler.allowedHost(req.URL.String()); !ok {
+		return fmt.Errorf("redirect to %s is not allowed", req.URL.String())
+	}
+	if isPage, _ := req.Context().Value(webCrawlPageContextKey{}).(bool); !isPage {
+		return nil
+	}
+	if _, ok := crawler.allowedURL(req.URL.String()); !ok {
+		return fmt.Errorf("redirect to %s is not allowed", req.URL.String())
+	}
+	rules := crawler.robotsRules(context.WithoutCancel(req.Context()), req.URL.Scheme+"://"+req.URL.Host)
+	if !rules.allowed(req.URL.RequestURI()) {
+		return fmt.Errorf("redirect to %s is disallowed by robots.txt", req.URL.String())
+	}
+	return nil
+}
+
+// crawlSitemap 从sitemap抓取网页,只重新索引ETag、Last-Modified或者内容hash变化的网页
+func crawlSitemap(ctx context.Context, webScraper *WebScraper) (folderSyncResult, error) {
+	result := folderSyncResult{}
+	err := webScraper.Initialization(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	// sitemap已经包含了所有的网页,不再递归抓取超链接
+	webScraper.Depth = 1
+	if len(webScraper.AllowedHosts) < 1 {
+		sitemapURL, err := url.Parse(webScraper.SitemapURL)
+		if err != nil {
+			return result, err
+		}
+		webScraper.AllowedHosts = []string{sitemapURL.Host}
+	}
+	crawler := newWebCrawler(webScraper)
+	pageURLs, err := crawler.sitemapURLs(ctx, webScraper.SitemapURL, 0, make(map[string]bool))
+	if err != nil {
+		return result, err
+	}
+	seen := make(map[string]bool, len(pageURLs))
+	for _, pageURL := range pageURLs {
+		seen[pageURL] = true
+	}
+
+	// 按照域名分组,每个域名启动HostConcurrency个协程
+	hostURLs := make(map[string][]string)
+	hostOrder := make([]string, 0)
+	for _, pageURL := range pageURLs {
+		u, _ := url.Parse(pageURL)
+		origin := u.Scheme + "://" + u.Host
+		if _, has := hostURLs[origin]; !has {
+			hostOrder = append(hostOrder, origin)
+		}
+		hostURLs[origin] = append(hostURLs[origin], pageURL)
+	}
+
+	var resultLock sync.Mutex
+	var wg sync.WaitGroup
+	for _, origin := range hostOrder {
+		rules := crawler.robotsRules(ctx, origin)
+		delay := time.Duration(webScraper.CrawlDelay) * time.Second
+		if rules.crawlDelay > delay {
+			delay = rules.crawlDelay
+		}
+		host := &webCrawlHost{delay: delay}
+		queue := make(chan string, len(hostURLs[origin]))
+		for _, pageURL := range hostURLs[origin] {
+			u, _ := url.Parse(pageURL)
+			if !rules.allowed(u.RequestURI()) {
+				continue
+			}
+			queue <- pageURL
+		}
+		close(queue)
+		for i := 0; i < webScraper.HostConcurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for pageURL := range queue {
+					if host.wait(ctx) != nil {
+						return
+					}
+					state, err := crawler.crawlPage(ctx, pageURL)
+					resultLock.Lock()
+					if err != nil {
+						FuncLogError(ctx, fmt.Errorf("crawl %s: %w", pageURL, err))
+						result.Failed++
+					} else {
+						switch state {
+						case webCrawlAdded:
+							result.Added++
+						case webCrawlUpdated:
+							result.Updated++
+						case webCrawlDeleted:
+							result.Deleted++
+						default:
+							result.Skipped++
+						}
+					}
+					resultLock.Unlock()
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	// sitemap中已经删除的网页,同时删除文档.有sitemap索引文件读取失败时,无法确定网页是否删除,不处理
+	if crawler.sitemapFailed {
+		return result, nil
+	}
+	deleted, err := crawler.deleteUnseenPages(ctx, seen)
+	result.Deleted += deleted
+	return result, err
+}
+
+// deleteUnseenPages 删除知识库中属于本次抓取范围,但是不在sitemap中的网页和文档,返回删除的数量
+func (crawler *webCrawler) deleteUnseenPages(ctx context.Context, seen map[string]bool) (int, error) {
+	finder := zorm.NewSelectFinder(tableWebCrawlPageName, "id,url,document_id").Append("WHERE knowledge_base_id=?", crawler.webScraper.KnowledgeBaseID)
+	finder.SelectTotalCount = false
+	pages := make([]WebCrawlPage, 0)
+	err := zorm.Query(ctx, finder, &pages, nil)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, page := range pages {
+		// 其他sitemap抓取的网页不处理
+		pageURL, ok := crawler.allowedURL(page.URL)
+		if !ok || seen[pageURL] {
+			continue
+		}
+		err = funcDeleteDocumentById(ctx, page.DocumentID)
+		if err != nil {
+			return deleted, err
+		}
+		err = deleteById(ctx, tableWebCrawlPageName, page.Id)
+		if err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+const (
+	// webCrawlUnchanged 网页没有变化
+	webCrawlUnchanged = iota
+	// webCrawlAdded 新增的网页
+	webCrawlAdded
+	// webCrawlUpdated 更新的网页
+	webCrawlUpdated
+	// webCrawlDeleted 网页已经不存在,删除文档
+	webCrawlDeleted
+)
+
+// crawlPage 抓取一个网页,内容变化时重新索引
+func (crawler *webCrawler) crawlPage(ctx context.Context, pageURL string) (int, error) {
+	webScraper := crawler.webScraper
+	documentID := webScraper.KnowledgeBaseID + sha256hex(pageURL)
+	page := &WebCrawlPage{}
+	finder := zorm.NewSelectFinder(tableWebCrawlPageName).Append("WHERE id=?", documentID)
+	has, err := zorm.QueryRow(ctx, finder, page)
+	if err != nil {
+		return webCrawlUnchanged, err
+	}
+	if !has {
+		page.Id = documentID
+		page.KnowledgeBaseID = webScraper.KnowledgeBaseID
+		page.URL = pageURL
+		page.DocumentID = documentID
+	}
+	now := time.Now().Format("2006-01-02 15:04:05")
+	page.CrawlTime = now
+
+	// 上次索引失败时不使用条件请求,重新处理
+	etag, lastModified := "", ""
+	if page.ContentHash != "" {
+		etag, lastModified = page.ETag, page.LastModified
+	}
+	statusCode, body, header, err := crawler.fetch(context.WithValue(ctx, webCrawlPageContextKey{}, true), pageURL, etag, lastModified)
+	if err != nil {
+		return webCrawlUnchanged, err
+	}
+	page.StatusCode = statusCode
+	switch {
+	case statusCode == http.StatusNotModified:
+		return webCrawlUnchanged, saveWebCrawlPage(ctx, page)
+	case statusCode == http.StatusNotFound || statusCode == http.StatusGone:
+		// 网页已经删除,同时删除文档
+		if !has {
+			return webCrawlUnchanged, nil
+		}
+		err := funcDeleteDocumentById(ctx, page.DocumentID)
+		if err != nil {
+			return webCrawlUnchanged, err
+		}
+		return webCrawlDeleted, deleteById(ctx, tableWebCrawlPageName, page.Id)
+	case statusCode < 200 || statusCode > 299:
+		return webCrawlUnchanged, fmt.Errorf("http status %d", statusCode)
+	}
+
+	contentHash := sha256hex(string(body))
+	page.ETag = header.Get("ETag")
+	page.LastModified = header.Get("Last-Modified")
+	if contentHash == page.ContentHash {
+		return webCrawlUnchanged, saveWebCrawlPage(ctx, page)
+	}
+
+	// 内容变化,抓取网页并重新索引.失败时清空hash,下次抓取重新处理
+	page.ContentHash = ""
+	document := &Document{}
+	finder = zorm.NewSelectFinder(tableDocumentName, "sortno,create_time").Append("WHERE id=?", documentID)
+	_, err = zorm.QueryRow(ctx, finder, document)
+	if err != nil {
+		return webCrawlUnchanged, err
+	}
+	exists := document.CreateTime != ""
+	if !exists {
+		document.SortNo = funcMaxSortNo(tableDocumentName)
+	}
+	document.Id = documentID
+	document.KnowledgeBaseID = webScraper.KnowledgeBaseID
+	document.Status = 2
+	// http抓取方式直接使用已经请求的网页内容
+	input := map[string]any{"document": document, "webScraper_webURL": pageURL, "webScraper_html": body}
+	_, err = webScraper.FetchPage(ctx, document, input)
+	if err == nil {
+		err = saveWebScraperDocument(ctx, document)
+	}
+	if err != nil {
+		saveWebCrawlPage(ctx, page)
+		return webCrawlUnchanged, err
+	}
+	page.ContentHash = contentHash
+	err = saveWebCrawlPage(ctx, page)
+	if exists {
+		return webCrawlUpdated, err
+	}
+	return webCrawlAdded, err
+}
+
+// saveWebCrawlPage 保存网页的抓取记录
+func saveWebCrawlPage(ctx context.Context, page *WebCrawlPage) error {
+	_, err := zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		zorm.Delete(ctx, page)
+		return zorm.Insert(ctx, page)
+	})
+	return err
+}
+
+// saveWebScraperDocument 清洗抓取网页的html标签,保存文档并执行indexPipeline
+func saveWebScraperDocument(ctx context.Context, document *Document) error {
+	input := make(map[string]any, 0)
+	input["document"] = document
+	//清洗html标签
+	hc := &HtmlCleaner{}
+	hc.Initialization(ctx, input)
+	err := hc.Run(ctx, input)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Format("2006-01-02 15:04:05")
+	if document.CreateTime == "" {
+		document.CreateTime = now
+	}
+	document.UpdateTime = now
+	f := zorm.NewSelectFinder(tableKnowledgeBaseName, "name as knowledge_base_name").Append(" where id =?", document.KnowledgeBaseID)
+	zorm.QueryRow(ctx, f, document)
+	_, err = zorm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		zorm.Delete(ctx, document) //先删除
+		return zorm.Insert(ctx, document)
+	})
+	if err != nil {
+		return err
+	}
+	// 文档分块,分析处理
+	_, err = updateDocumentChunk(ctx, document)
+	return err
+}
+
+// fetch 发送http GET请求,etag和lastModified不为空时使用条件请求
+func (crawler *webCrawler) fetch(ctx context.Context, rawURL string, etag string, lastModified string) (int, []byte, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	req.Header.Set("User-Agent", crawler.webScraper.UserAgent)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+	resp, err := crawler.client.Do(req)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCrawlBodySize))
+	if err != nil {
+		return resp.StatusCode, nil, resp.Header, err
+	}
+	return resp.StatusCode, body, resp.Header, nil
+}
+
+// sitemapXML sitemap文件,兼容 urlset 和 sitemapindex
+type sitemapXML struct {
+	URLs []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// sitemapURLs 解析sitemap,递归处理sitemap索引文件,返回允许抓取的网页地址
+func (crawler *webCrawler) sitemapURLs(ctx context.Context, sitemapURL string, depth int, visited map[string]bool) ([]string, error) {
+	if visited[sitemapURL] {
+		return nil, nil
+	}
+	visited[sitemapURL] = true
+	statusCode, body, _, err := crawler.fetch(ctx, sitemapURL, "", "")
+	if err != nil {
+		return nil, err
+	}
+	if statusCode < 200 || statusCode > 299 {
+		return nil, fmt.Errorf("sitemap %s: http status %d", sitemapURL, statusCode)
+	}
+	// sitemap.xml.gz
+	if len(body) > 2 && body[0] == 0x1f && body[1] == 0x8b {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		body, err = io.ReadAll(io.LimitReader(reader, maxCrawlBodySize))
+		if err != nil {
+			return nil, err
+		}
+	}
+	sitemap := sitemapXML{}
+	err = xml.Unmarshal(body, &sitemap)
+	if err != nil {
+		return nil, fmt.Errorf("sitemap %s: %w", sitemapURL, err)
+	}
+
+	pageURLs := make([]string, 0, len(sitemap.URLs))
+	for _, loc := range sitemap.URLs {
+		if len(pageURLs) >= crawler.webScraper.MaxPages {
+			break
+		}
+		pageURL, ok := crawler.allowedURL(loc.Loc)
+		if !ok || visited[pageURL] {
+			continue
+		}
+		visited[pageURL] = true
+		pageURLs = append(pageURLs, pageURL)
+	}
+	if depth+1 >= maxSitemapDepth {
+		return pageURLs, nil
+	}
+	for _, loc := range sitemap.Sitemaps {
+		if len(pageURLs) >= crawler.webScraper.MaxPages {
+			break
+		}
+		childURL, ok := crawler.allowedHost(loc.Loc)
+		if !ok {
+			continue
+		}
+		childURLs, err := crawler.sitemapURLs(ctx, childURL, depth+1, visited)
+		if err != nil {
+			crawler.sitemapFailed = true
+			FuncLogError(ctx, err)
+			continue
+		}
+		pageURLs = append(pageURLs, childURLs...)
+	}
+	if len(pageURLs) > crawler.webScraper.MaxPages {
+		pageURLs = pageURLs[:crawler.webScraper.MaxPages]
+	}
+	return pageURLs, nil
+}
+
+// allowedHost 网址的域名是否在AllowedHosts中,返回去掉#锚点的网址
+func (crawler *webCrawler) allowedHost(rawURL string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", false
+	}
+	u.Fragment = ""
+	host := strings.ToLower(u.Host)
+	for _, allowedHost := range crawler.webScraper.AllowedHosts {
+		allowedHost = strings.ToLower(strings.TrimSpace(allowedHost))
+		if allowedHost != "" && (allowedHost == host || allowedHost == strings.ToLower(u.Hostname())) {
+			return u.String(), true
+		}
+	}
+	return "", false
+}
+
+// allowedURL 网址的域名和路径前缀是否允许抓取
+func (crawler *webCrawler) allowedURL(rawURL string) (string, bool) {
+	pageURL, ok := crawler.allowedHost(rawURL)
+	if !ok {
+		return "", false
+	}
+	prefixes := make([]string, 0, len(crawler.webScraper.PathPrefixes))
+	for _, prefix := range crawler.webScraper.PathPrefixes {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	if len(prefixes) < 1 {
+		return pageURL, true
+	}
+	u, _ := url.Parse(pageURL)
+	urlPath := u.Path
+	if urlPath == "" {
+		urlPath = "/"
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(urlPath, prefix) {
+			return pageURL, true
+		}
+	}
+	return "", false
+}
+
+// robotsRules 获取域名的robots.txt规则,同一个域名只请求一次.
+// robots.txt不存在(4xx)时允许抓取所有网页,无法访问(5xx或者网络错误)时不抓取
+func (crawler *webCrawler) robotsRules(ctx context.Context, origin string) *robotsRules {
+	crawler.lock.Lock()
+	rules, has := crawler.robots[origin]
+	crawler.lock.Unlock()
+	if has {
+		return rules
+	}
+	statusCode, body, _, err := crawler.fetch(ctx, origin+"/robots.txt", "", "")
+	switch {
+	case err != nil || statusCode >= 500:
+		rules = &robotsRules{disallowAll: true}
+	case statusCode >= 200 && statusCode <= 299:
+		rules = parseRobotsTxt(string(body), crawler.webScraper.UserAgent)
+	default:
+		rules = &robotsRules{}
+	}
+	crawler.lock.Lock()
+	crawler.robots[origin] = rules
+	crawler.lock.Unlock()
+	return rules
+}
+
+// robotsRules robots.txt中适用于当前爬虫的规则
+type robotsRules struct {
+	allows      []string
+	disallows   []string
+	crawlDelay  time.Duration
+	sitemaps    []string
+	disallowAll bool
+}
+
+// robotsGroup robots.txt中的一组User-agent规则
+type robotsGroup struct {
+	agents     []string
+	allows     []string
+	disallows  []string
+	crawlDelay time.Duration
+}
+
+// parseRobotsTxt 解析robots.txt,优先使用和userAgent匹配的组,没有匹配时使用 User-agent: *
+func parseRobotsTxt(content string, userAgent string) *robotsRules {
+	rules := &robotsRules{}
+	groups := make([]*robotsGroup, 0)
+	var group *robotsGroup
+	// 上一行是否是User-agent,连续的User-agent属于同一组
+	lastAgent := false
+	for _, line := range strings.Split(content, "\n") {
+		if index := strings.Index(line, "#"); index >= 0 {
+			line = line[:index]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if !lastAgent {
+				group = &robotsGroup{}
+				groups = append(groups, group)
+			}
+			group.agents = append(group.agents, strings.ToLower(value))
+			lastAgent = true
+			continue
+		case "sitemap":
+			rules.sitemaps = append(rules.sitemaps, value)
+		case "allow":
+			if group != nil && value != "" {
+				group.allows = append(group.allows, value)
+			}
+		case "disallow":
+			if group != nil && value != "" {
+				group.disallows = append(group.disallows, value)
+			}
+		case "crawl-delay":
+			if group != nil {
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					group.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+		lastAgent = false
+	}
+
+	userAgent = strings.ToLower(userAgent)
+	var matched, wildcard *robotsGroup
+	for _, g := range groups {
+		for _, agent := range g.agents {
+			if agent == "*" {
+				if wildcard == nil {
+					wildcard = g
+				}
+			} else if agent != "" && (agent == "minrag" || strings.Contains(userAgent, agent)) {
+				if matched == nil {
+					matched = g
+				}
+			}
+		}
+	}
+	if matched == nil {
+		matched = wildcard
+	}
+	if matched != nil {
+		rules.allows = matched.allows
+		rules.disallows = matched.disallows
+		rules.crawlDelay = matched.crawlDelay
+	}
+	return rules
+}
+
+// allowed 路径是否允许抓取,使用最长匹配的规则,长度相同时Allow优先
+func (rules *robotsRules) allowed(requestURI string) bool {
+	if rules.disallowAll {
+		return false
+	}
+	if requestURI == "/robots.txt" {
+		return true
+	}
+	allowLen, disallowLen := -1, -1
+	for _, pattern := range rules.allows {
+		if len(pattern) > allowLen && robotsPatternMatch(pattern, requestURI) {
+			allowLen = len(pattern)
+		}
+	}
+	for _, pattern := range rules.disallows {
+		if len(pattern) > disallowLen && robotsPatternMatch(pattern, requestURI) {
+			disallowLen = len(pattern)
+		}
+	}
+	return allowLen >= disallowLen
+}
+
+// robotsPatternMatch robots.txt的路径匹配,支持 * 通配符和 $ 结尾
+func robotsPatternMatch(pattern string, requestURI string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(requestURI, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for i := 1; i < len(parts); i++ {
+		if anchored && i == len(parts)-1 {
+			return strings.HasSuffix(requestURI[pos:], parts[i])
+		}
+		index := strings.Index(requestURI[pos:], parts[i])
+		if index < 0 {
+			return false
+		}
+		pos = pos + index + len(parts[i])
+	}
+	return !anchored || pos == len(requestURI)
+}
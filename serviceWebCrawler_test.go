@@ -0,0 +1,210 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseRobotsTxt(t *testing.T) {
+	robots := `
+# comment
+User-agent: Googlebot
+Disallow: /
+
+User-agent: *
+User-agent: other
+Disallow: /private/
+Allow: /private/public
+Disallow: /*.pdf$
+Disallow: /tmp*/cache
+Crawl-delay: 2.5
+
+Sitemap: https://example.com/sitemap.xml
+`
+	rules := parseRobotsTxt(robots, userAgent)
+	if rules.crawlDelay != 2500*time.Millisecond {
+		t.Errorf("crawl delay: %v", rules.crawlDelay)
+	}
+	if !slices.Equal(rules.sitemaps, []string{"https://example.com/sitemap.xml"}) {
+		t.Errorf("sitemaps: %v", rules.sitemaps)
+	}
+	tests := map[string]bool{
+		"/":                      true,
+		"/docs/a.html":           true,
+		"/private/":              false,
+		"/private/a.html":        false,
+		"/private/public/a.html": true,
+		"/a/b.pdf":               false,
+		"/a/b.pdf?x=1":           true,
+		"/tmp1/cache/a":          false,
+		"/robots.txt":            true,
+	}
+	for requestURI, want := range tests {
+		if got := rules.allowed(requestURI); got != want {
+			t.Errorf("%s: got %v, want %v", requestURI, got, want)
+		}
+	}
+
+	if parseRobotsTxt(robots, "Mozilla/5.0 (compatible; Googlebot/2.1)").allowed("/docs/a.html") {
+		t.Error("Googlebot group should disallow all")
+	}
+	if (&robotsRules{disallowAll: true}).allowed("/") {
+		t.Error("disallowAll should disallow all")
+	}
+}
+
+func TestCrawlerSitemapURLs(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>` + server.URL + `/docs.xml.gz</loc></sitemap>
+  <sitemap><loc>https://other.example.com/sitemap.xml</loc></sitemap>
+</sitemapindex>`))
+		case "/docs.xml.gz":
+			var buf bytes.Buffer
+			gz := gzip.NewWriter(&buf)
+			gz.Write([]byte(`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>` + server.URL + `/docs/a.html#top</loc></url>
+  <url><loc>` + server.URL + `/docs/a.html</loc></url>
+  <url><loc>` + server.URL + `/blog/b.html</loc></url>
+  <url><loc>https://other.example.com/docs/c.html</loc></url>
+</urlset>`))
+			gz.Close()
+			w.Write(buf.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	webScraper := &WebScraper{SitemapURL: server.URL + "/sitemap.xml", AllowedHosts: []string{u.Host}, PathPrefixes: []string{"/docs/"}}
+	webScraper.Initialization(context.Background(), nil)
+	crawler := newWebCrawler(webScraper)
+	pageURLs, err := crawler.sitemapURLs(context.Background(), webScraper.SitemapURL, 0, make(map[string]bool))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(pageURLs, []string{server.URL + "/docs/a.html"}) {
+		t.Errorf("page urls: %v", pageURLs)
+	}
+}
+
+func TestCrawlerConditionalFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("<html><body>hello</body></html>"))
+	}))
+	defer server.Close()
+
+	webScraper := &WebScraper{}
+	webScraper.Initialization(context.Background(), nil)
+	crawler := newWebCrawler(webScraper)
+	statusCode, body, header, err := crawler.fetch(context.Background(), server.URL+"/a.html", "", "")
+	if err != nil || statusCode != http.StatusOK || header.Get("ETag") != `"v1"` || len(body) == 0 {
+		t.Fatalf("fetch: %d %q %v", statusCode, body, err)
+	}
+	statusCode, _, _, err = crawler.fetch(context.Background(), server.URL+"/a.html", header.Get("ETag"), "")
+	if err != nil || statusCode != http.StatusNotModified {
+		t.Fatalf("conditional fetch: %d %v", statusCode, err)
+	}
+	// robots.txt无法访问时不抓取
+	if crawler.robotsRules(context.Background(), server.URL).allowed("/a.html") {
+		t.Error("robots.txt 5xx should disallow all")
+	}
+}
+
+func TestWebCrawlHostDelay(t *testing.T) {
+	host := &webCrawlHost{delay: 50 * time.Millisecond}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := host.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("crawl delay not applied: %v", elapsed)
+	}
+}
+
+func TestCrawlerCheckRedirect(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>other</body></html>"))
+	}))
+	defer other.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.Write([]byte("User-agent: *\nDisallow: /docs/private/\n"))
+		case "/docs/other-host":
+			http.Redirect(w, r, other.URL+"/docs/a.html", http.StatusFound)
+		case "/docs/outside":
+			http.Redirect(w, r, "/blog/a.html", http.StatusFound)
+		case "/docs/private":
+			http.Redirect(w, r, "/docs/private/a.html", http.StatusFound)
+		case "/docs/moved":
+			http.Redirect(w, r, "/docs/a.html", http.StatusMovedPermanently)
+		default:
+			w.Write([]byte("<html><body>hello</body></html>"))
+		}
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	webScraper := &WebScraper{AllowedHosts: []string{serverURL.Host}, PathPrefixes: []string{"/docs/"}}
+	webScraper.Initialization(context.Background(), nil)
+	crawler := newWebCrawler(webScraper)
+	pageCtx := context.WithValue(context.Background(), webCrawlPageContextKey{}, true)
+	tests := map[string]bool{
+		"/docs/other-host": false,
+		"/docs/outside":    false,
+		"/docs/private":    false,
+		"/docs/moved":      true,
+	}
+	for path, allowed := range tests {
+		_, _, _, err := crawler.fetch(pageCtx, server.URL+path, "", "")
+		if (err == nil) != allowed {
+			t.Errorf("redirect %s: %v", path, err)
+		}
+	}
+	// sitemap的重定向只检查域名
+	if _, _, _, err := crawler.fetch(context.Background(), server.URL+"/docs/outside", "", ""); err != nil {
+		t.Errorf("sitemap redirect: %v", err)
+	}
+}
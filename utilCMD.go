@@ -23,6 +23,7 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"strings"
 	"time"
 )
 
@@ -53,3 +54,22 @@ func ExecCMD(command string, envs []string, timeout time.Duration) (string, erro
 	}
 	return result, nil
 }
+
+// ExecGit 执行git命令,参数不经过shell,避免仓库地址等参数注入
+func ExecGit(dir string, timeout time.Duration, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel() // 确保释放资源
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	// 禁止交互式输入账号密码
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	output, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return string(output), fmt.Errorf("ExecGit timeout")
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return string(output), fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return string(output), err
+}
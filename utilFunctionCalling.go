@@ -152,6 +152,10 @@ func (fc FCSearchDocumentTOCById) Run(ctx context.Context, arguments string, int
 	if len(fc.DocumentIds) > 0 {
 		f_dc.Append("and id in (?)", fc.DocumentIds)
 	}
+	err = appendMetadataFilter(f_dc, intput, "")
+	if err != nil {
+		return "", err
+	}
 
 	page := zorm.NewPage()
 	page.PageSize = len(fc.DocumentIds)
@@ -237,6 +241,10 @@ func (fc FCSearchContentByNode) Run(ctx context.Context, arguments string, intpu
 	if len(fc.NodeIds) > 0 {
 		f_dc.Append("and id in (?)", fc.NodeIds)
 	}
+	err = appendMetadataFilter(f_dc, intput, "")
+	if err != nil {
+		return "", err
+	}
 
 	page := zorm.NewPage()
 	page.PageSize = len(fc.NodeIds)
@@ -315,30 +323,56 @@ func (fc FCSearchDocumentByKeyword) Run(ctx context.Context, arguments string, i
 	if len(fc.Query) < 1 {
 		return "", nil
 	}
+	documentChunks, err := searchDocumentChunkByKeyword(ctx, fc.Query, fc.DocumentIds, intput)
+	if err != nil {
+		return "", err
+	}
+	resultByte, _ := json.Marshal(documentChunks)
+	return string(resultByte), nil
+}
+
+// searchDocumentChunkByKeyword 根据关键字全文检索分块,使用input中的knowledgeBaseID,score,topN,metadataFilter和asOf,按照BM25分数降序
+func searchDocumentChunkByKeyword(ctx context.Context, query string, documentIDs []string, input map[string]any) ([]DocumentChunk, error) {
 	knowledgeBaseID := ""
-	if intput["knowledgeBaseID"] != nil {
-		knowledgeBaseID = intput["knowledgeBaseID"].(string)
+	if input["knowledgeBaseID"] != nil {
+		knowledgeBaseID = input["knowledgeBaseID"].(string)
 	}
 	var score float32 = 0.3
-	if intput["score"] != nil {
-		score = intput["score"].(float32)
+	if input["score"] != nil {
+		score = input["score"].(float32)
 	}
 
 	topN := 5
-	if intput["topN"] != nil {
-		topN = intput["topN"].(int)
+	if input["topN"] != nil {
+		topN = input["topN"].(int)
 	}
 
 	// BM25的FTS5实现在返回结果之前将结果乘以-1,得分越小(数值上更负),表示匹配越好
-	finder := zorm.NewFinder().Append("SELECT id,markdown from fts_document_chunk where fts_document_chunk match jieba_query(?)", fc.Query)
+	// 根据语言选择全文检索表和分词方式
+	ftsTable, matchSQL, matchValue := ftsMatch(ftsQueryLanguage(ctx, knowledgeBaseID, query), query)
+	asOf, err := inputAsOf(input, "")
+	if err != nil {
+		return nil, err
+	}
+	// 返回BM25分数,用于和历史版本,向量检索的结果合并排序
+	columns := "id,document_id,knowledge_base_id,markdown,-1*rank as score"
+	finder := zorm.NewFinder().Append("SELECT "+columns+" from "+ftsTable+" where "+matchSQL, matchValue)
 	finder.SelectTotalCount = false
 	finder.Append(" and markdown !=?  and markdown is not null", "")
-	if len(fc.DocumentIds) > 0 {
-		finder.Append(" and document_id in (?)", fc.DocumentIds)
+	if asOf != "" { // 只检索asOf时间点已经生效的当前版本
+		finder.Append(" and document_id in (SELECT id FROM "+tableDocumentName+" WHERE "+documentValidFromSQL+" <= ?)", asOf)
+	}
+	if len(documentIDs) > 0 {
+		finder.Append(" and document_id in (?)", documentIDs)
 	}
 	if knowledgeBaseID != "" {
 		finder.Append(" and knowledge_base_id like ?", knowledgeBaseID+"%")
 	}
+	// fts表没有元数据字段,使用document_chunk子查询过滤
+	err = appendMetadataFilter(finder, input, tableDocumentChunkName)
+	if err != nil {
+		return nil, err
+	}
 	if score > 0.0 { // BM25的FTS5实现在返回结果之前将结果乘以-1,查询时再乘以-1
 		finder.Append("and -1*rank >= ?", score)
 	}
@@ -349,11 +383,35 @@ func (fc FCSearchDocumentByKeyword) Run(ctx context.Context, arguments string, i
 	documentChunks := make([]DocumentChunk, 0)
 	err = zorm.Query(ctx, finder, &documentChunks, nil)
 	if err != nil {
-		//input[errorKey] = err
-		return "", err
+		return nil, err
 	}
-	resultByte, _ := json.Marshal(documentChunks)
-	return string(resultByte), nil
+	// asOf时间点有效的历史版本分块
+	if asOf != "" {
+		versionChunks, err := findDocumentChunkVersionsByKeyword(ctx, query, knowledgeBaseID, documentIDs, input, asOf, topN)
+		if err != nil {
+			return nil, err
+		}
+		documentChunks = sortDocumentChunksScore(append(documentChunks, versionChunks...), topN, score)
+	}
+	return documentChunks, nil
+}
+
+// appendMetadataFilter 追加input中metadataFilter元数据过滤条件,subQueryTable不为空时,使用id子查询过滤
+func appendMetadataFilter(finder *zorm.Finder, intput map[string]any, subQueryTable string) error {
+	metadataFilter, err := inputMetadataFilter(intput, "")
+	if err != nil {
+		return err
+	}
+	whereSQL, whereValues, err := metadataFilterSQL(metadataFilter, "metadata")
+	if err != nil || whereSQL == "" {
+		return err
+	}
+	if subQueryTable != "" {
+		finder.Append(" and id in (SELECT id FROM "+subQueryTable+" WHERE "+whereSQL+")", whereValues...)
+		return nil
+	}
+	finder.Append(" and ("+whereSQL+")", whereValues...)
+	return nil
 }
 
 // web_search_json 网络搜索json字符串
@@ -436,6 +494,8 @@ func (fc FCWebSearch) Run(ctx context.Context, arguments string, intput map[stri
 	hrefWS := &WebSearch{}
 	hrefWS.WebScraper.Depth = 1
 	hrefWS.WebScraper.QuerySelector = []string{"body"}
+	// 搜索结果的网页使用http抓取,需要javascript渲染时才使用chrome
+	hrefWS.WebScraper.FetchMode = webFetchModeHTTP
 	hrefWS.WebScraper.Initialization(ctx, nil)
 	webSerachDocuments := make([]Document, 0)
 	// 使用WaitGroup和Mutex的基本异步方案
@@ -0,0 +1,291 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// htmlSelector 解析后的css选择器,只支持常用的语法:
+// 标签、#id、.class、[attr]、[attr=value]、后代选择器(空格)、子选择器(>)和选择器列表(,)
+type htmlSelector struct {
+	// parts 从左到右的复合选择器
+	parts []htmlCompoundSelector
+}
+
+// htmlCompoundSelector 复合选择器,例如 div.content#main
+type htmlCompoundSelector struct {
+	tag     string
+	id      string
+	classes []string
+	attrs   [][2]string
+	// child 和前一个复合选择器是父子关系(>),否则是后代关系
+	child bool
+}
+
+// parseHTMLSelector 解析css选择器列表
+func parseHTMLSelector(selector string) ([]htmlSelector, error) {
+	selectors := make([]htmlSelector, 0)
+	for _, item := range strings.Split(selector, ",") {
+		item = strings.TrimSpace(strings.ReplaceAll(item, ">", " > "))
+		if item == "" {
+			return nil, fmt.Errorf("invalid selector %q", selector)
+		}
+		s := htmlSelector{}
+		child := false
+		for _, field := range strings.Fields(item) {
+			if field == ">" {
+				if len(s.parts) == 0 || child {
+					return nil, fmt.Errorf("invalid selector %q", selector)
+				}
+				child = true
+				continue
+			}
+			compound, err := parseHTMLCompoundSelector(field)
+			if err != nil {
+				return nil, err
+			}
+			compound.child = child
+			child = false
+			s.parts = append(s.parts, compound)
+		}
+		if child || len(s.parts) == 0 {
+			return nil, fmt.Errorf("invalid selector %q", selector)
+		}
+		selectors = append(selectors, s)
+	}
+	return selectors, nil
+}
+
+// parseHTMLCompoundSelector 解析复合选择器
+func parseHTMLCompoundSelector(field string) (htmlCompoundSelector, error) {
+	compound := htmlCompoundSelector{}
+	// 不支持伪类和兄弟选择器
+	if name, _, _ := strings.Cut(field, "["); strings.ContainsAny(name, ":()~+") {
+		return compound, fmt.Errorf("unsupported selector %q", field)
+	}
+	i := 0
+	readName := func() string {
+		j := i
+		for j < len(field) && field[j] != '.' && field[j] != '#' && field[j] != '[' {
+			j++
+		}
+		name := field[i:j]
+		i = j
+		return name
+	}
+	compound.tag = strings.ToLower(readName())
+	if compound.tag == "*" {
+		compound.tag = ""
+	}
+	for i < len(field) {
+		switch field[i] {
+		case '#':
+			i++
+			compound.id = readName()
+		case '.':
+			i++
+			compound.classes = append(compound.classes, readName())
+		case '[':
+			end := strings.IndexByte(field[i:], ']')
+			if end < 0 {
+				return compound, fmt.Errorf("invalid selector %q", field)
+			}
+			name, value, _ := strings.Cut(field[i+1:i+end], "=")
+			value = strings.Trim(value, `"'`)
+			compound.attrs = append(compound.attrs, [2]string{strings.ToLower(name), value})
+			i = i + end + 1
+		}
+	}
+	return compound, nil
+}
+
+// match 元素是否匹配复合选择器
+func (compound *htmlCompoundSelector) match(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if compound.tag != "" && n.Data != compound.tag {
+		return false
+	}
+	if compound.id != "" && htmlAttr(n, "id") != compound.id {
+		return false
+	}
+	if len(compound.classes) > 0 {
+		classes := strings.Fields(htmlAttr(n, "class"))
+		for _, class := range compound.classes {
+			found := false
+			for _, c := range classes {
+				if c == class {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	for _, attr := range compound.attrs {
+		value, has := htmlAttrLookup(n, attr[0])
+		if !has || (attr[1] != "" && value != attr[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// match 元素是否匹配选择器,从右向左匹配祖先元素
+func (s *htmlSelector) match(n *html.Node) bool {
+	return s.matchPart(n, len(s.parts)-1)
+}
+
+func (s *htmlSelector) matchPart(n *html.Node, index int) bool {
+	if !s.parts[index].match(n) {
+		return false
+	}
+	if index == 0 {
+		return true
+	}
+	if s.parts[index].child {
+		return n.Parent != nil && s.matchPart(n.Parent, index-1)
+	}
+	for p := n.Parent; p != nil; p = p.Parent {
+		if s.matchPart(p, index-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// htmlQuerySelectorAll 按照文档顺序返回匹配选择器的元素,和 document.querySelectorAll 一致
+func htmlQuerySelectorAll(root *html.Node, selector string) ([]*html.Node, error) {
+	selectors, err := parseHTMLSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]*html.Node, 0)
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for i := range selectors {
+			if selectors[i].match(n) {
+				nodes = append(nodes, n)
+				break
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	return nodes, nil
+}
+
+// htmlAttrLookup 获取元素的属性
+func htmlAttrLookup(n *html.Node, key string) (string, bool) {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+// htmlAttr 获取元素的属性,不存在返回空字符串
+func htmlAttr(n *html.Node, key string) string {
+	value, _ := htmlAttrLookup(n, key)
+	return value
+}
+
+// htmlBlockElements 块级元素,提取文本时前后换行
+var htmlBlockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "dd": true, "div": true, "dl": true, "dt": true,
+	"fieldset": true, "figcaption": true, "figure": true, "footer": true, "form": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true, "header": true, "hr": true, "li": true, "main": true, "nav": true, "ol": true,
+	"p": true, "pre": true, "section": true, "table": true, "tr": true, "ul": true, "body": true,
+}
+
+// htmlInnerText 提取元素的文本,近似浏览器的 innerText,忽略脚本和样式
+func htmlInnerText(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			text := strings.Join(strings.Fields(n.Data), " ")
+			if text != "" {
+				if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") && !strings.HasSuffix(sb.String(), " ") {
+					sb.WriteString(" ")
+				}
+				sb.WriteString(text)
+			}
+			return
+		case html.ElementNode:
+			switch n.Data {
+			case "script", "style", "noscript", "template", "head":
+				return
+			case "br":
+				sb.WriteString("\n")
+				return
+			}
+		}
+		block := n.Type == html.ElementNode && htmlBlockElements[n.Data]
+		if block && sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteString("\n")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if block && sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	walk(n)
+	return strings.TrimSpace(sb.String())
+}
+
+// htmlTitle 获取网页的title
+func htmlTitle(root *html.Node) string {
+	nodes, _ := htmlQuerySelectorAll(root, "title")
+	if len(nodes) < 1 {
+		return ""
+	}
+	return strings.TrimSpace(htmlInnerText(nodes[0]))
+}
+
+// htmlLinks 获取元素中所有超链接的绝对地址,和浏览器的 a.href 一致
+func htmlLinks(n *html.Node, baseURL *url.URL) []string {
+	links := make([]string, 0)
+	anchors, _ := htmlQuerySelectorAll(n, "a")
+	for _, a := range anchors {
+		href, has := htmlAttrLookup(a, "href")
+		if !has {
+			continue
+		}
+		u, err := baseURL.Parse(strings.TrimSpace(href))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		links = append(links, u.String())
+	}
+	return links
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"gitee.com/chunanyong/zorm"
+)
+
+const (
+	// languageChinese 中文,使用jieba_query分词查询
+	languageChinese = "zh"
+	// languageJapanese 日文,使用simple_query按字查询
+	languageJapanese = "ja"
+	// languageKorean 韩文,使用simple_query按字查询
+	languageKorean = "ko"
+	// languageEnglish 英文等拉丁语系,使用porter分词的全文检索表
+	languageEnglish = "en"
+)
+
+// validLanguage 是否是支持的语言,空字符串表示自动检测
+func validLanguage(language string) bool {
+	switch language {
+	case "", languageChinese, languageJapanese, languageKorean, languageEnglish:
+		return true
+	}
+	return false
+}
+
+// detectLanguage 根据字符的书写系统检测文本的语言,文本太短无法判断时返回空字符串
+func detectLanguage(text string) string {
+	han, kana, hangul, latinWords := 0, 0, 0, 0
+	inWord := false
+	for _, r := range text {
+		latin := false
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Latin, r):
+			latin = true
+			if !inWord {
+				latinWords++
+			}
+		}
+		inWord = latin
+	}
+	cjk := han + kana + hangul
+	if cjk < 2 && latinWords < 2 {
+		return ""
+	}
+	// 一个英文单词大约对应两个汉字
+	if latinWords*2 > cjk {
+		return languageEnglish
+	}
+	if kana > 0 && kana*4 >= han {
+		return languageJapanese
+	}
+	if hangul > han {
+		return languageKorean
+	}
+	return languageChinese
+}
+
+// resolveDocumentLanguage 文档的语言,优先使用文档的设置,然后是知识库的设置,最后根据内容自动检测
+func resolveDocumentLanguage(ctx context.Context, document *Document) string {
+	if document.Language != "" {
+		return document.Language
+	}
+	if document.KnowledgeBaseID != "" {
+		knowledgeBase, err := findKnowledgeBaseById(ctx, document.KnowledgeBaseID)
+		if err == nil && knowledgeBase.Language != "" {
+			return knowledgeBase.Language
+		}
+	}
+	if language := detectLanguage(document.Markdown); language != "" {
+		return language
+	}
+	return languageChinese
+}
+
+// ftsQueryLanguage 全文检索使用的语言,优先使用知识库的设置,然后根据查询内容检测,
+// 查询太短无法判断时,使用知识库中最多的分块语言
+func ftsQueryLanguage(ctx context.Context, knowledgeBaseID string, query string) string {
+	if knowledgeBaseID != "" {
+		knowledgeBase, err := findKnowledgeBaseById(ctx, knowledgeBaseID)
+		if err == nil && knowledgeBase.Language != "" {
+			return knowledgeBase.Language
+		}
+	}
+	language := detectLanguage(query)
+	if language == "" {
+		finder := zorm.NewSelectFinder(tableDocumentChunkName, "language").Append("WHERE language is not null and language!=''")
+		if knowledgeBaseID != "" {
+			finder.Append("and knowledge_base_id like ?", knowledgeBaseID+"%")
+		}
+		finder.Append("GROUP BY language ORDER BY count(*) DESC LIMIT 1")
+		finder.SelectTotalCount = false
+		_, err := zorm.QueryRow(ctx, finder, &language)
+		if err != nil || language == "" {
+			return languageChinese
+		}
+	}
+	// 知识库没有设置语言时可能包含多种语言,英文查询也使用jieba_query检索包含所有分块的fts_document_chunk,
+	// 才能匹配中文分块中的英文单词,例如API.porter分词表只有英文分块,只用于设置为英文的知识库
+	if language == languageEnglish {
+		return languageChinese
+	}
+	return language
+}
+
+// ftsMatch 根据语言返回全文检索表名称,match语句和参数.
+// 中文使用jieba_query,日文和韩文使用simple_query,英文使用porter分词的全文检索表.
+// 所有分块都写入simple分词表,英文分块同时写入porter分词表,porter分词表只用于设置为英文的知识库
+func ftsMatch(language string, query string) (string, string, string) {
+	switch language {
+	case languageJapanese, languageKorean:
+		return tableFtsDocumentChunkName, tableFtsDocumentChunkName + " match simple_query(?)", query
+	case languageEnglish:
+		if porterQuery := ftsPorterQuery(query); porterQuery != "" {
+			return tableFtsDocumentChunkPorterName, tableFtsDocumentChunkPorterName + " match ?", porterQuery
+		}
+	}
+	return tableFtsDocumentChunkName, tableFtsDocumentChunkName + " match jieba_query(?)", query
+}
+
+// ftsPorterQuery 把查询拆分为单词,使用OR连接,避免FTS5的查询语法错误
+func ftsPorterQuery(query string) string {
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, `"`+word+`"`)
+	}
+	return strings.Join(terms, " OR ")
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := map[string]string{
+		"minRAG是一个轻量级的RAG框架,支持全文检索和向量检索":                               languageChinese,
+		"How do I configure the embedding model for a knowledge base?": languageEnglish,
+		"ナレッジベースの埋め込みモデルを設定する方法":                                       languageJapanese,
+		"지식 베이스의 임베딩 모델을 설정하는 방법":                                      languageKorean,
+		"使用 SQLite 存储向量":                                               languageChinese,
+		"RAG":                                                          "",
+		"12345":                                                        "",
+	}
+	for text, want := range tests {
+		if got := detectLanguage(text); got != want {
+			t.Errorf("detectLanguage(%q)=%q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestFtsMatch(t *testing.T) {
+	table, matchSQL, value := ftsMatch(languageEnglish, `running "dogs" AND (cats)-`)
+	if table != tableFtsDocumentChunkPorterName || matchSQL != "fts_document_chunk_porter match ?" || value != `"running" OR "dogs" OR "AND" OR "cats"` {
+		t.Errorf("unexpected english match %s %s %s", table, matchSQL, value)
+	}
+	// 没有单词时使用默认的jieba_query
+	if _, matchSQL, _ = ftsMatch(languageEnglish, "?!"); matchSQL != "fts_document_chunk match jieba_query(?)" {
+		t.Errorf("unexpected fallback %s", matchSQL)
+	}
+	if _, matchSQL, _ = ftsMatch(languageJapanese, "設定"); matchSQL != "fts_document_chunk match simple_query(?)" {
+		t.Errorf("unexpected japanese match %s", matchSQL)
+	}
+	if _, matchSQL, _ = ftsMatch(languageChinese, "设置"); matchSQL != "fts_document_chunk match jieba_query(?)" {
+		t.Errorf("unexpected chinese match %s", matchSQL)
+	}
+}
+
+func TestFtsQueryLanguage(t *testing.T) {
+	// 没有设置语言的知识库,英文查询检索包含所有分块的fts_document_chunk
+	tests := map[string]string{
+		"How do I configure the API?": languageChinese,
+		"如何配置API":                     languageChinese,
+		"ナレッジベースの埋め込みモデルを設定する方法": languageJapanese,
+	}
+	for query, want := range tests {
+		if got := ftsQueryLanguage(context.Background(), "", query); got != want {
+			t.Errorf("ftsQueryLanguage(%q)=%q, want %q", query, got, want)
+		}
+	}
+}
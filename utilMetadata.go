@@ -0,0 +1,375 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// metadataKeyRegexp 元数据key只能是字母、数字、下划线、中划线和点
+var metadataKeyRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_\-\.]*$`)
+
+// validateDocumentMetadata 校验文档的元数据,必须是json对象,key符合规范,value只能是字符串、数字或者布尔值
+func validateDocumentMetadata(metadata string) error {
+	metadata = strings.TrimSpace(metadata)
+	if metadata == "" {
+		return nil
+	}
+	metadataMap := make(map[string]any)
+	if err := json.Unmarshal([]byte(metadata), &metadataMap); err != nil {
+		return errors.New(funcT("Metadata must be a JSON object"))
+	}
+	for key, value := range metadataMap {
+		if !metadataKeyRegexp.MatchString(key) {
+			return fmt.Errorf(funcT("Invalid metadata key: %s"), key)
+		}
+		switch value.(type) {
+		case string, float64, bool:
+		default:
+			return fmt.Errorf(funcT("Metadata value must be a string, number or boolean: %s"), key)
+		}
+	}
+	return nil
+}
+
+// mergeDocumentMetadata 合并文档和分块的元数据,分块的元数据优先,都为空时返回空字符串
+func mergeDocumentMetadata(documentMetadata string, chunkMetadata string) string {
+	if strings.TrimSpace(chunkMetadata) == "" {
+		return documentMetadata
+	}
+	if strings.TrimSpace(documentMetadata) == "" {
+		return chunkMetadata
+	}
+	metadataMap := make(map[string]any)
+	if err := json.Unmarshal([]byte(documentMetadata), &metadataMap); err != nil {
+		return chunkMetadata
+	}
+	chunkMap := make(map[string]any)
+	if err := json.Unmarshal([]byte(chunkMetadata), &chunkMap); err != nil {
+		return documentMetadata
+	}
+	for key, value := range chunkMap {
+		metadataMap[key] = value
+	}
+	metadata, err := json.Marshal(metadataMap)
+	if err != nil {
+		return chunkMetadata
+	}
+	return string(metadata)
+}
+
+// inputMetadataFilter 获取元数据过滤表达式,input中的metadataFilter和组件配置的过滤表达式使用 AND 合并,只能进一步缩小组件的检索范围
+func inputMetadataFilter(input map[string]any, componentFilter string) (string, error) {
+	metadataFilter, _ := input["metadataFilter"].(string)
+	return joinMetadataFilter(componentFilter, metadataFilter)
+}
+
+// joinMetadataFilter 使用 AND 合并多个元数据过滤表达式,忽略空的和重复的表达式.
+// 每个表达式必须能够单独解析,例如未闭合的括号会返回错误,避免和其他表达式拼接后改变 AND 的范围
+func joinMetadataFilter(filters ...string) (string, error) {
+	exprs := make([]string, 0, len(filters))
+	for _, filter := range filters {
+		filter = strings.TrimSpace(filter)
+		if filter == "" || slices.Contains(exprs, filter) {
+			continue
+		}
+		if _, _, err := metadataFilterSQL(filter, "metadata"); err != nil {
+			return "", err
+		}
+		exprs = append(exprs, filter)
+	}
+	if len(exprs) < 2 {
+		return strings.Join(exprs, ""), nil
+	}
+	return "(" + strings.Join(exprs, ") AND (") + ")", nil
+}
+
+// metadataFilterSQL 把元数据过滤表达式转换为SQL条件,column是保存元数据json的字段.
+// 支持 AND OR NOT 括号, = != <> > >= < <= IN, 值可以是字符串、数字、true/false. 例如:
+// lang = "zh" AND (version >= 3 OR tag IN ("faq","manual"))
+// json路径也使用参数传递,避免SQL中出现单引号
+func metadataFilterSQL(expression string, column string) (string, []any, error) {
+	expression = strings.TrimSpace(expression)
+	if expression == "" {
+		return "", nil, nil
+	}
+	tokens, err := metadataFilterTokens(expression)
+	if err != nil {
+		return "", nil, err
+	}
+	parser := &metadataFilterParser{tokens: tokens, column: column}
+	sql, err := parser.parseOr()
+	if err != nil {
+		return "", nil, err
+	}
+	if parser.pos < len(parser.tokens) {
+		return "", nil, fmt.Errorf("metadata filter: unexpected %q", parser.tokens[parser.pos].text)
+	}
+	return sql, parser.values, nil
+}
+
+// metadataFilterToken 过滤表达式的词法单元
+type metadataFilterToken struct {
+	// kind 类型: ident,string,number,op,(,),,
+	kind string
+	text string
+}
+
+// metadataFilterTokens 把过滤表达式拆分为词法单元
+func metadataFilterTokens(expression string) ([]metadataFilterToken, error) {
+	tokens := make([]metadataFilterToken, 0)
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			i++
+		case r == '(' || r == ')' || r == ',':
+			tokens = append(tokens, metadataFilterToken{kind: string(r), text: string(r)})
+			i++
+		case r == '"' || r == '\'':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				sb.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, errors.New("metadata filter: unterminated string")
+			}
+			tokens = append(tokens, metadataFilterToken{kind: "string", text: sb.String()})
+			i = j + 1
+		case r == '=' || r == '!' || r == '<' || r == '>':
+			op := string(r)
+			if i+1 < len(runes) && (runes[i+1] == '=' || (r == '<' && runes[i+1] == '>')) {
+				op = op + string(runes[i+1])
+			}
+			i = i + len(op)
+			switch op {
+			case "!":
+				return nil, errors.New("metadata filter: unexpected !")
+			case "==":
+				op = "="
+			case "<>":
+				op = "!="
+			}
+			tokens = append(tokens, metadataFilterToken{kind: "op", text: op})
+		case r == '-' || r == '+' || r == '.' || (r >= '0' && r <= '9'):
+			j := i + 1
+			for ; j < len(runes) && (runes[j] == '.' || runes[j] == 'e' || runes[j] == 'E' || (runes[j] >= '0' && runes[j] <= '9')); j++ {
+			}
+			tokens = append(tokens, metadataFilterToken{kind: "number", text: string(runes[i:j])})
+			i = j
+		default:
+			j := i
+			for ; j < len(runes) && (runes[j] == '_' || runes[j] == '-' || runes[j] == '.' || (runes[j] >= '0' && runes[j] <= '9') || (runes[j] >= 'a' && runes[j] <= 'z') || (runes[j] >= 'A' && runes[j] <= 'Z')); j++ {
+			}
+			if j == i {
+				return nil, fmt.Errorf("metadata filter: unexpected %q", string(r))
+			}
+			tokens = append(tokens, metadataFilterToken{kind: "ident", text: string(runes[i:j])})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// metadataFilterParser 递归下降解析过滤表达式,生成SQL条件和参数
+type metadataFilterParser struct {
+	tokens []metadataFilterToken
+	pos    int
+	column string
+	values []any
+}
+
+// peekKeyword 当前词法单元是否是指定的关键字,不区分大小写
+func (p *metadataFilterParser) peekKeyword(keyword string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == "ident" && strings.EqualFold(p.tokens[p.pos].text, keyword)
+}
+
+// peekKind 当前词法单元是否是指定的类型
+func (p *metadataFilterParser) peekKind(kind string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == kind
+}
+
+// parseOr or_expr := and_expr { OR and_expr }
+func (p *metadataFilterParser) parseOr() (string, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return "", err
+	}
+	for p.peekKeyword("OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return "", err
+		}
+		left = left + " OR " + right
+	}
+	return left, nil
+}
+
+// parseAnd and_expr := not_expr { AND not_expr }
+func (p *metadataFilterParser) parseAnd() (string, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return "", err
+	}
+	for p.peekKeyword("AND") {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return "", err
+		}
+		left = left + " AND " + right
+	}
+	return left, nil
+}
+
+// parseNot not_expr := NOT not_expr | ( or_expr ) | comparison
+func (p *metadataFilterParser) parseNot() (string, error) {
+	if p.peekKeyword("NOT") {
+		p.pos++
+		expr, err := p.parseNot()
+		if err != nil {
+			return "", err
+		}
+		return "NOT (" + expr + ")", nil
+	}
+	if p.peekKind("(") {
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return "", err
+		}
+		if !p.peekKind(")") {
+			return "", errors.New("metadata filter: missing )")
+		}
+		p.pos++
+		return "(" + expr + ")", nil
+	}
+	return p.parseComparison()
+}
+
+// parseComparison comparison := key op value | key [NOT] IN ( value {, value} )
+func (p *metadataFilterParser) parseComparison() (string, error) {
+	if !p.peekKind("ident") {
+		return "", errors.New("metadata filter: expected metadata key")
+	}
+	key := p.tokens[p.pos].text
+	if !metadataKeyRegexp.MatchString(key) {
+		return "", fmt.Errorf("metadata filter: invalid key %q", key)
+	}
+	p.pos++
+	// json路径作为参数,key中的点不作为层级
+	path := `$."` + key + `"`
+
+	not := ""
+	if p.peekKeyword("NOT") {
+		not = "NOT "
+		p.pos++
+		if !p.peekKeyword("IN") {
+			return "", errors.New("metadata filter: expected IN after NOT")
+		}
+	}
+	if p.peekKeyword("IN") {
+		p.pos++
+		if !p.peekKind("(") {
+			return "", errors.New("metadata filter: expected ( after IN")
+		}
+		p.pos++
+		values := make([]any, 0)
+		numeric := true
+		for {
+			value, isNumber, err := p.parseValue()
+			if err != nil {
+				return "", err
+			}
+			numeric = numeric && isNumber
+			values = append(values, value)
+			if p.peekKind(",") {
+				p.pos++
+				continue
+			}
+			if p.peekKind(")") {
+				p.pos++
+				break
+			}
+			return "", errors.New("metadata filter: missing )")
+		}
+		p.values = append(p.values, path)
+		p.values = append(p.values, values...)
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(values)), ",")
+		return p.jsonExtract(numeric) + " " + not + "IN (" + placeholders + ")", nil
+	}
+
+	if !p.peekKind("op") {
+		return "", fmt.Errorf("metadata filter: expected operator after %q", key)
+	}
+	op := p.tokens[p.pos].text
+	p.pos++
+	value, isNumber, err := p.parseValue()
+	if err != nil {
+		return "", err
+	}
+	p.values = append(p.values, path, value)
+	return p.jsonExtract(isNumber) + " " + op + " ?", nil
+}
+
+// parseValue value := string | number | true | false
+func (p *metadataFilterParser) parseValue() (any, bool, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, false, errors.New("metadata filter: expected value")
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	switch token.kind {
+	case "string":
+		return token.text, false, nil
+	case "number":
+		number, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return nil, false, fmt.Errorf("metadata filter: invalid number %q", token.text)
+		}
+		return number, true, nil
+	case "ident":
+		// json的true/false经过json_extract后是1/0
+		if strings.EqualFold(token.text, "true") {
+			return 1, true, nil
+		} else if strings.EqualFold(token.text, "false") {
+			return 0, true, nil
+		}
+	}
+	return nil, false, fmt.Errorf("metadata filter: invalid value %q", token.text)
+}
+
+// jsonExtract 生成提取元数据的SQL,数字比较时转换为REAL
+func (p *metadataFilterParser) jsonExtract(numeric bool) string {
+	if numeric {
+		return "CAST(json_extract(" + p.column + ",?) AS REAL)"
+	}
+	return "json_extract(" + p.column + ",?)"
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2025 minRAG Authors.
+//
+// This file is part of minRAG.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses>.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMetadataFilterSQL(t *testing.T) {
+	tests := []struct {
+		expression string
+		sql        string
+		values     []any
+	}{
+		{`lang = "zh"`, "json_extract(metadata,?) = ?", []any{`$."lang"`, "zh"}},
+		{`version >= 3.2`, "CAST(json_extract(metadata,?) AS REAL) >= ?", []any{`$."version"`, 3.2}},
+		{`lang <> 'en' and public == true`, "json_extract(metadata,?) != ? AND CAST(json_extract(metadata,?) AS REAL) = ?", []any{`$."lang"`, "en", `$."public"`, 1}},
+		{`NOT (tag IN ("faq", "manual") OR year < 2020)`, "NOT ((json_extract(metadata,?) IN (?,?) OR CAST(json_extract(metadata,?) AS REAL) < ?))", []any{`$."tag"`, "faq", "manual", `$."year"`, 2020.0}},
+		{`product.line not in (1,2)`, "CAST(json_extract(metadata,?) AS REAL) NOT IN (?,?)", []any{`$."product.line"`, 1.0, 2.0}},
+		{``, "", nil},
+	}
+	for _, test := range tests {
+		sql, values, err := metadataFilterSQL(test.expression, "metadata")
+		if err != nil {
+			t.Fatalf("%s: %v", test.expression, err)
+		}
+		if sql != test.sql {
+			t.Errorf("%s: got sql %q, want %q", test.expression, sql, test.sql)
+		}
+		if !reflect.DeepEqual(values, test.values) {
+			t.Errorf("%s: got values %v, want %v", test.expression, values, test.values)
+		}
+	}
+
+	invalids := []string{`lang =`, `lang = "zh`, `(lang = "zh"`, `lang = "zh" extra`, `lang ! "zh"`, `"lang" = "zh"`, `lang = "zh"; DROP TABLE document`, `tag IN ("a"`}
+	for _, expression := range invalids {
+		if _, _, err := metadataFilterSQL(expression, "metadata"); err == nil {
+			t.Errorf("%s: expected error", expression)
+		}
+	}
+}
+
+func TestValidateDocumentMetadata(t *testing.T) {
+	if err := validateDocumentMetadata(`{"lang":"zh","version":3,"public":true}`); err != nil {
+		t.Error(err)
+	}
+	for _, metadata := range []string{`[1,2]`, `{"a b":1}`, `{"tags":["a"]}`, `{"a":null}`} {
+		if err := validateDocumentMetadata(metadata); err == nil {
+			t.Errorf("%s: expected error", metadata)
+		}
+	}
+}
+
+func TestMergeDocumentMetadata(t *testing.T) {
+	tests := []struct {
+		document string
+		chunk    string
+		want     string
+	}{
+		{`{"lang":"zh","year":2024}`, ``, `{"lang":"zh","year":2024}`},
+		{``, `{"page":3}`, `{"page":3}`},
+		// 分块的元数据优先
+		{`{"lang":"zh","year":2024}`, `{"lang":"en","page":3}`, `{"lang":"en","page":3,"year":2024}`},
+	}
+	for _, test := range tests {
+		if got := mergeDocumentMetadata(test.document, test.chunk); got != test.want {
+			t.Errorf("mergeDocumentMetadata(%q,%q)=%q want %q", test.document, test.chunk, got, test.want)
+		}
+	}
+}
+
+func TestJoinMetadataFilter(t *testing.T) {
+	filter, err := joinMetadataFilter(`dept = "hr"`, ``, `year >= 2024`)
+	if err != nil || filter != `(dept = "hr") AND (year >= 2024)` {
+		t.Errorf("unexpected filter: %q %v", filter, err)
+	}
+	if filter, err = joinMetadataFilter(`dept = "hr"`, `dept = "hr"`); err != nil || filter != `dept = "hr"` {
+		t.Errorf("duplicate filter should be ignored: %q %v", filter, err)
+	}
+	// 未闭合的括号不能跳出智能体的过滤范围
+	if _, err = joinMetadataFilter(`dept = "hr"`, `x = 1) OR (dept = "secret"`); err == nil {
+		t.Errorf("unbalanced request filter should be rejected")
+	}
+	// 组件配置的过滤表达式不能被input覆盖
+	filter, err = inputMetadataFilter(map[string]any{"metadataFilter": `year >= 2024`}, `dept = "hr"`)
+	if err != nil || filter != `(dept = "hr") AND (year >= 2024)` {
+		t.Errorf("input filter should be joined with the component filter: %q %v", filter, err)
+	}
+}
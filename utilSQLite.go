@@ -22,8 +22,10 @@ import (
 	"context"
 	"database/sql"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"gitee.com/chunanyong/zorm"
 
@@ -99,6 +101,12 @@ func checkSQLiteStatus() bool {
 	}
 
 	if tableExist(tableDocumentName) {
+		// 已经安装过的数据库,补充升级后新增的表和字段
+		err = upgradeSQLiteTable(context.Background())
+		if err != nil {
+			FuncLogError(nil, err)
+			return false
+		}
 		return true
 	}
 
@@ -128,6 +136,354 @@ func tableExist(tableName string) bool {
 	return count > 0
 }
 
+// upgradeTableSQLMap 升级时需要补充创建的表,key是表名称,value是建表语句,需要和minrag.sql保持一致
+var upgradeTableSQLMap = map[string]string{
+	tableEmbeddingCacheName: `CREATE TABLE IF NOT EXISTS embedding_cache (
+		id TEXT PRIMARY KEY NOT NULL,
+		model              TEXT NOT NULL,
+		base_url           TEXT NOT NULL,
+		text_hash          TEXT NOT NULL,
+		embedding          BLOB NOT NULL,
+		dimension          INT NOT NULL,
+		hit_count          INT NOT NULL,
+		create_time        TEXT,
+		update_time        TEXT
+	 ) strict ;
+CREATE INDEX IF NOT EXISTS idx_embedding_cache_update_time ON embedding_cache (update_time);`,
+	tableFolderSyncName: `CREATE TABLE IF NOT EXISTS folder_sync (
+		id TEXT PRIMARY KEY NOT NULL,
+		name               TEXT NOT NULL,
+		root_path          TEXT NOT NULL,
+		include_globs      TEXT,
+		exclude_globs      TEXT,
+		knowledge_base_id  TEXT NOT NULL,
+		sync_mode          INT,
+		sync_interval      INT,
+		sync_status        INT,
+		sync_message       TEXT,
+		last_sync_time     TEXT,
+		create_time        TEXT,
+		update_time        TEXT,
+		create_user        TEXT,
+		sortno             INT NOT NULL,
+		status             INT NOT NULL
+	 ) strict ;`,
+	tableFolderSyncFileName: `CREATE TABLE IF NOT EXISTS folder_sync_file (
+		id TEXT PRIMARY KEY NOT NULL,
+		folder_sync_id     TEXT NOT NULL,
+		file_path          TEXT NOT NULL,
+		document_id        TEXT NOT NULL,
+		file_size          INT,
+		mod_time           INT,
+		file_hash          TEXT,
+		update_time        TEXT
+	 ) strict ;
+CREATE INDEX IF NOT EXISTS idx_folder_sync_file_folder_sync_id ON folder_sync_file (folder_sync_id);`,
+	tableGitSyncName: `CREATE TABLE IF NOT EXISTS git_sync (
+		id TEXT PRIMARY KEY NOT NULL,
+		name               TEXT NOT NULL,
+		repo_url           TEXT NOT NULL,
+		branch             TEXT,
+		extensions         TEXT,
+		knowledge_base_id  TEXT NOT NULL,
+		source_url_template TEXT,
+		last_commit        TEXT,
+		sync_interval      INT,
+		sync_status        INT,
+		sync_message       TEXT,
+		last_sync_time     TEXT,
+		create_time        TEXT,
+		update_time        TEXT,
+		create_user        TEXT,
+		sortno             INT NOT NULL,
+		status             INT NOT NULL
+	 ) strict ;`,
+	tableDocumentChunkSignatureName: `CREATE TABLE IF NOT EXISTS document_chunk_signature (
+		id TEXT PRIMARY KEY NOT NULL,
+		document_id        TEXT NOT NULL,
+		knowledge_base_id  TEXT NOT NULL,
+		algorithm          TEXT NOT NULL,
+		signature          TEXT NOT NULL
+	 ) strict ;
+CREATE INDEX IF NOT EXISTS idx_document_chunk_signature_knowledge_base_id ON document_chunk_signature (knowledge_base_id);
+CREATE INDEX IF NOT EXISTS idx_document_chunk_signature_document_id ON document_chunk_signature (document_id);`,
+	tableDocumentChunkDuplicateName: `CREATE TABLE IF NOT EXISTS document_chunk_duplicate (
+		id TEXT PRIMARY KEY NOT NULL,
+		knowledge_base_id  TEXT NOT NULL,
+		document_id        TEXT NOT NULL,
+		document_name      TEXT,
+		duplicate_of       TEXT NOT NULL,
+		duplicate_of_document_id TEXT NOT NULL,
+		duplicate_of_document_name TEXT,
+		similarity         REAL,
+		action             TEXT,
+		markdown           TEXT,
+		create_time        TEXT
+	 ) strict ;
+CREATE INDEX IF NOT EXISTS idx_document_chunk_duplicate_knowledge_base_id ON document_chunk_duplicate (knowledge_base_id);
+CREATE INDEX IF NOT EXISTS idx_document_chunk_duplicate_document_id ON document_chunk_duplicate (document_id);`,
+	tableDocumentChunkQuestionName: `CREATE TABLE IF NOT EXISTS document_chunk_question (
+		id TEXT PRIMARY KEY NOT NULL,
+		document_chunk_id  TEXT NOT NULL,
+		document_id        TEXT NOT NULL,
+		knowledge_base_id  TEXT NOT NULL,
+		question           TEXT NOT NULL,
+		sortno             INT NOT NULL,
+		create_time        TEXT
+	 ) strict ;
+CREATE INDEX IF NOT EXISTS idx_document_chunk_question_document_id ON document_chunk_question (document_id);
+CREATE INDEX IF NOT EXISTS idx_document_chunk_question_document_chunk_id ON document_chunk_question (document_chunk_id);`,
+	tableDocumentVersionName: `CREATE TABLE IF NOT EXISTS document_version (
+		id TEXT PRIMARY KEY NOT NULL,
+		document_id        TEXT NOT NULL,
+		knowledge_base_id  TEXT,
+		name               TEXT,
+		markdown           TEXT,
+		file_path          TEXT,
+		metadata           TEXT,
+		version            INT NOT NULL,
+		valid_from         TEXT,
+		valid_to           TEXT NOT NULL,
+		create_time        TEXT
+	 ) strict ;
+CREATE INDEX IF NOT EXISTS idx_document_version_document_id ON document_version (document_id);`,
+	tableDocumentChunkVersionName: `CREATE TABLE IF NOT EXISTS document_chunk_version (
+		id TEXT PRIMARY KEY NOT NULL,
+		version_id         TEXT NOT NULL,
+		document_id        TEXT NOT NULL,
+		knowledge_base_id  TEXT NOT NULL,
+		title              TEXT,
+		markdown           TEXT,
+		metadata           TEXT,
+		sortno             INT,
+		embedding_model    TEXT,
+		embedding          BLOB,
+		valid_from         TEXT,
+		valid_to           TEXT NOT NULL
+	 ) strict ;
+CREATE INDEX IF NOT EXISTS idx_document_chunk_version_document_id ON document_chunk_version (document_id);
+CREATE INDEX IF NOT EXISTS idx_document_chunk_version_knowledge_base_id ON document_chunk_version (knowledge_base_id);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS fts_document_chunk_version USING fts5 (
+    id UNINDEXED,
+    document_id UNINDEXED,
+    knowledge_base_id UNINDEXED,
+	title,
+    markdown,
+    valid_from UNINDEXED,
+    valid_to UNINDEXED,
+    tokenize = 'simple 0',
+    content='document_chunk_version',
+    content_rowid='rowid'
+);
+
+CREATE TRIGGER trigger_document_chunk_version_insert AFTER INSERT ON document_chunk_version
+BEGIN
+    INSERT INTO fts_document_chunk_version(rowid, id, document_id, knowledge_base_id, title, markdown, valid_from, valid_to)
+    VALUES (new.rowid, new.id, new.document_id, new.knowledge_base_id, new.title, new.markdown, new.valid_from, new.valid_to);
+END;
+
+CREATE TRIGGER trigger_document_chunk_version_delete AFTER DELETE ON document_chunk_version
+BEGIN
+    INSERT INTO fts_document_chunk_version(fts_document_chunk_version, rowid, id, document_id, knowledge_base_id, title, markdown, valid_from, valid_to)
+    VALUES ('delete', old.rowid, old.id, old.document_id, old.knowledge_base_id, old.title, old.markdown, old.valid_from, old.valid_to);
+END;`,
+	tableKnowledgeGraphNodeName: `CREATE TABLE IF NOT EXISTS knowledge_graph_node (
+		id TEXT PRIMARY KEY NOT NULL,
+		knowledge_base_id  TEXT NOT NULL,
+		name               TEXT NOT NULL,
+		attributes         TEXT,
+		create_time        TEXT,
+		update_time        TEXT
+	 ) strict ;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_knowledge_graph_node_name ON knowledge_graph_node (knowledge_base_id,name);`,
+	tableKnowledgeGraphEdgeName: `CREATE TABLE IF NOT EXISTS knowledge_graph_edge (
+		id TEXT PRIMARY KEY NOT NULL,
+		knowledge_base_id  TEXT NOT NULL,
+		document_id        TEXT NOT NULL,
+		document_chunk_id  TEXT NOT NULL,
+		source_node_id     TEXT NOT NULL,
+		target_node_id     TEXT NOT NULL,
+		relation           TEXT,
+		create_time        TEXT
+	 ) strict ;
+CREATE INDEX IF NOT EXISTS idx_knowledge_graph_edge_document_id ON knowledge_graph_edge (document_id);
+CREATE INDEX IF NOT EXISTS idx_knowledge_graph_edge_source_node_id ON knowledge_graph_edge (source_node_id);
+CREATE INDEX IF NOT EXISTS idx_knowledge_graph_edge_target_node_id ON knowledge_graph_edge (target_node_id);`,
+	tableKnowledgeGraphNodeChunkName: `CREATE TABLE IF NOT EXISTS knowledge_graph_node_chunk (
+		id TEXT PRIMARY KEY NOT NULL,
+		knowledge_base_id  TEXT NOT NULL,
+		document_id        TEXT NOT NULL,
+		document_chunk_id  TEXT NOT NULL,
+		node_id            TEXT NOT NULL
+	 ) strict ;
+CREATE INDEX IF NOT EXISTS idx_knowledge_graph_node_chunk_document_id ON knowledge_graph_node_chunk (document_id);
+CREATE INDEX IF NOT EXISTS idx_knowledge_graph_node_chunk_node_id ON knowledge_graph_node_chunk (node_id);`,
+	tableWebCrawlPageName: `CREATE TABLE IF NOT EXISTS web_crawl_page (
+		id TEXT PRIMARY KEY NOT NULL,
+		knowledge_base_id  TEXT NOT NULL,
+		url                TEXT NOT NULL,
+		document_id        TEXT NOT NULL,
+		etag               TEXT,
+		last_modified      TEXT,
+		content_hash       TEXT,
+		status_code        INT,
+		crawl_time         TEXT
+	 ) strict ;
+CREATE INDEX IF NOT EXISTS idx_web_crawl_page_knowledge_base_id ON web_crawl_page (knowledge_base_id);`,
+}
+
+// upgradeColumnSQLs 升级时需要补充的字段,格式:[表名称,字段名称,字段定义],需要和minrag.sql保持一致
+var upgradeColumnSQLs = [][3]string{
+	{tableConfigName, "embedding_cache_size", "INT"},
+	{tableKnowledgeBaseName, "embedder_id", "TEXT"},
+	{tableKnowledgeBaseName, "embedding_model", "TEXT"},
+	{tableKnowledgeBaseName, "embedding_dimension", "INT"},
+	{tableKnowledgeBaseName, "migrate_embedder_id", "TEXT"},
+	{tableKnowledgeBaseName, "migrate_status", "INT"},
+	{tableKnowledgeBaseName, "migrate_message", "TEXT"},
+	{tableDocumentName, "metadata", "TEXT"},
+	{tableDocumentChunkName, "metadata", "TEXT"},
+	{tableAgentName, "metadata_filter", "TEXT"},
+	{tableDocumentChunkName, "duplicate_of", "TEXT"},
+	{tableKnowledgeBaseName, "language", "TEXT"},
+	{tableDocumentName, "language", "TEXT"},
+	{tableDocumentChunkName, "language", "TEXT"},
+	{tableDocumentName, "valid_from", "TEXT"},
+	{tableDocumentChunkName, "context", "TEXT"},
+	{tableMessageLogName, "citations", "TEXT"},
+	{tableMessageLogName, "trace", "TEXT"},
+}
+
+// upgradeFtsTableSQLs 全文检索表,不存在,缺少字段或者触发器和建表语句不一致时删除重建.所有分块写入simple分词表,英文分块同时写入porter分词表,需要在补充字段之后执行,格式:[表名称,触发器名称前缀,建表语句],需要和minrag.sql保持一致
+var upgradeFtsTableSQLs = [][3]string{
+	{tableFtsDocumentChunkName, "trigger_document_chunk", `CREATE VIRTUAL TABLE IF NOT EXISTS fts_document_chunk USING fts5 (
+    id UNINDEXED,
+    document_id UNINDEXED,
+    knowledge_base_id UNINDEXED,
+	title,
+    markdown,
+    context,
+    sortno UNINDEXED,
+    status UNINDEXED,
+    tokenize = 'simple 0',
+    content='document_chunk',
+    content_rowid='rowid'
+);
+
+CREATE TRIGGER trigger_document_chunk_insert AFTER INSERT ON document_chunk
+BEGIN
+    INSERT INTO fts_document_chunk(rowid, id, document_id, knowledge_base_id, title, markdown, context, sortno, status)
+    VALUES (new.rowid, new.id, new.document_id, new.knowledge_base_id, new.title, new.markdown, new.context, new.sortno, new.status);
+END;
+
+CREATE TRIGGER trigger_document_chunk_delete AFTER DELETE ON document_chunk
+BEGIN
+    INSERT INTO fts_document_chunk(fts_document_chunk, rowid, id, document_id, knowledge_base_id, title, markdown, context, sortno, status)
+    VALUES ('delete', old.rowid, old.id, old.document_id, old.knowledge_base_id, old.title, old.markdown, old.context, old.sortno, old.status);
+END;
+
+CREATE TRIGGER trigger_document_chunk_update AFTER UPDATE ON document_chunk
+BEGIN
+    INSERT INTO fts_document_chunk(fts_document_chunk, rowid, id, document_id, knowledge_base_id, title, markdown, context, sortno, status)
+    VALUES ('delete', old.rowid, old.id, old.document_id, old.knowledge_base_id, old.title, old.markdown, old.context, old.sortno, old.status);
+    INSERT INTO fts_document_chunk(rowid, id, document_id, knowledge_base_id, title, markdown, context, sortno, status)
+    VALUES (new.rowid, new.id, new.document_id, new.knowledge_base_id, new.title, new.markdown, new.context, new.sortno, new.status);
+END;
+INSERT INTO fts_document_chunk(fts_document_chunk) VALUES('rebuild');`},
+	{tableFtsDocumentChunkPorterName, "trigger_document_chunk_porter", `CREATE VIRTUAL TABLE IF NOT EXISTS fts_document_chunk_porter USING fts5 (
+    id UNINDEXED,
+    document_id UNINDEXED,
+    knowledge_base_id UNINDEXED,
+	title,
+    markdown,
+    context,
+    sortno UNINDEXED,
+    status UNINDEXED,
+    tokenize = 'porter unicode61 remove_diacritics 2',
+    content='document_chunk',
+    content_rowid='rowid'
+);
+
+CREATE TRIGGER trigger_document_chunk_porter_insert AFTER INSERT ON document_chunk WHEN new.language='en'
+BEGIN
+    INSERT INTO fts_document_chunk_porter(rowid, id, document_id, knowledge_base_id, title, markdown, context, sortno, status)
+    VALUES (new.rowid, new.id, new.document_id, new.knowledge_base_id, new.title, new.markdown, new.context, new.sortno, new.status);
+END;
+
+CREATE TRIGGER trigger_document_chunk_porter_delete AFTER DELETE ON document_chunk WHEN old.language='en'
+BEGIN
+    INSERT INTO fts_document_chunk_porter(fts_document_chunk_porter, rowid, id, document_id, knowledge_base_id, title, markdown, context, sortno, status)
+    VALUES ('delete', old.rowid, old.id, old.document_id, old.knowledge_base_id, old.title, old.markdown, old.context, old.sortno, old.status);
+END;
+
+CREATE TRIGGER trigger_document_chunk_porter_update AFTER UPDATE ON document_chunk
+BEGIN
+    INSERT INTO fts_document_chunk_porter(fts_document_chunk_porter, rowid, id, document_id, knowledge_base_id, title, markdown, context, sortno, status)
+    SELECT 'delete', old.rowid, old.id, old.document_id, old.knowledge_base_id, old.title, old.markdown, old.context, old.sortno, old.status WHERE old.language='en';
+    INSERT INTO fts_document_chunk_porter(rowid, id, document_id, knowledge_base_id, title, markdown, context, sortno, status)
+    SELECT new.rowid, new.id, new.document_id, new.knowledge_base_id, new.title, new.markdown, new.context, new.sortno, new.status WHERE new.language='en';
+END;
+INSERT INTO fts_document_chunk_porter(rowid, id, document_id, knowledge_base_id, title, markdown, context, sortno, status)
+SELECT rowid, id, document_id, knowledge_base_id, title, markdown, context, sortno, status FROM document_chunk WHERE language='en';`},
+}
+
+// upgradeSQLiteTable 升级已有的数据库,补充新增的表和字段,可以重复执行
+func upgradeSQLiteTable(ctx context.Context) error {
+	for tableName, createSQL := range upgradeTableSQLMap {
+		if tableExist(tableName) {
+			continue
+		}
+		_, err := execNativeSQL(ctx, createSQL)
+		if err != nil {
+			return err
+		}
+	}
+	for _, columnSQL := range upgradeColumnSQLs {
+		if columnExist(columnSQL[0], columnSQL[1]) {
+			continue
+		}
+		_, err := execNativeSQL(ctx, "ALTER TABLE "+columnSQL[0]+" ADD COLUMN "+columnSQL[1]+" "+columnSQL[2])
+		if err != nil {
+			return err
+		}
+	}
+	// 全文检索表的字段不能修改,删除表和触发器后重建索引
+	for _, ftsSQL := range upgradeFtsTableSQLs {
+		// 触发器和建表语句不一致时重建,例如旧的porter分词表没有语言条件
+		if tableExist(ftsSQL[0]) && columnExist(ftsSQL[0], "context") && triggersUpToDate(ftsSQL[1], ftsSQL[2]) {
+			continue
+		}
+		dropSQL := "DROP TRIGGER IF EXISTS " + ftsSQL[1] + "_insert;DROP TRIGGER IF EXISTS " + ftsSQL[1] + "_delete;DROP TRIGGER IF EXISTS " + ftsSQL[1] + "_update;DROP TABLE IF EXISTS " + ftsSQL[0] + ";"
+		_, err := execNativeSQL(ctx, dropSQL+ftsSQL[2])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columnExist 数据表的字段是否存在
+func columnExist(tableName string, columnName string) bool {
+	finder := zorm.NewFinder().Append("SELECT count(*) FROM pragma_table_info(?) WHERE name=?", tableName, columnName)
+	count := 0
+	zorm.QueryRow(context.Background(), finder, &count)
+	return count > 0
+}
+
+// triggersUpToDate 全文检索表的insert,delete,update触发器是否和建表语句中的定义一致
+func triggersUpToDate(triggerPrefix string, createSQL string) bool {
+	for _, suffix := range []string{"_insert", "_delete", "_update"} {
+		finder := zorm.NewSelectFinder("sqlite_master", "sql").Append("WHERE type='trigger' and name=?", triggerPrefix+suffix)
+		triggerSQL := ""
+		zorm.QueryRow(context.Background(), finder, &triggerSQL)
+		if triggerSQL == "" || !strings.Contains(createSQL, triggerSQL) {
+			return false
+		}
+	}
+	return true
+}
+
 // deleteById 根据Id删除数据
 func deleteById(ctx context.Context, tableName string, id string) error {
 	finder := zorm.NewDeleteFinder(tableName).Append(" WHERE id=?", id)
@@ -177,3 +533,20 @@ func vecSerializeFloat64(vector []float64) ([]byte, error) {
 	}
 	return buf.Bytes(), nil
 }
+
+// vecDeserializeFloat64 把sqlite-vec的向量BLOB反序列化为float64数组,和vecSerializeFloat64对应
+func vecDeserializeFloat64(embedding []byte) ([]float64, error) {
+	if len(embedding)%4 != 0 {
+		return nil, errors.New("vecDeserializeFloat64 embedding length is invalid")
+	}
+	vector32 := make([]float32, len(embedding)/4)
+	err := binary.Read(bytes.NewReader(embedding), binary.LittleEndian, vector32)
+	if err != nil {
+		return nil, err
+	}
+	vector := make([]float64, len(vector32))
+	for i, v := range vector32 {
+		vector[i] = float64(v)
+	}
+	return vector, nil
+}
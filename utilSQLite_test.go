@@ -53,3 +53,26 @@ func TestVecQuery(t *testing.T) {
 	}
 
 }
+
+func TestVecDeserializeFloat64(t *testing.T) {
+	vector := []float64{0.5, -1.25, 3, 0}
+	embedding, err := vecSerializeFloat64(vector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := vecDeserializeFloat64(embedding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(vector) {
+		t.Fatalf("len = %d, want %d", len(got), len(vector))
+	}
+	for i := range vector {
+		if got[i] != vector[i] {
+			t.Errorf("got[%d] = %v, want %v", i, got[i], vector[i])
+		}
+	}
+	if _, err := vecDeserializeFloat64([]byte{1, 2, 3}); err == nil {
+		t.Error("expected error for invalid embedding length")
+	}
+}
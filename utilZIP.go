@@ -19,12 +19,34 @@ package main
 
 import (
 	"archive/zip"
+	"errors"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"sync"
 )
 
+// zipEntryPath 压缩包内文件的解压路径,防止zip-slip,不允许绝对路径和跳出destDir的 ../ 路径
+func zipEntryPath(destDir string, name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, ":") {
+		return "", errors.New("illegal file path in zip: " + name)
+	}
+	for _, part := range strings.Split(path.Clean(name), "/") {
+		if part == ".." {
+			return "", errors.New("illegal file path in zip: " + name)
+		}
+	}
+	destDir = filepath.Clean(destDir)
+	filePath := filepath.Join(destDir, filepath.FromSlash(name))
+	if !strings.HasPrefix(filePath, destDir+string(os.PathSeparator)) {
+		return "", errors.New("illegal file path in zip: " + name)
+	}
+	return filePath, nil
+}
+
 // unzip 用于解压ZIP文件到指定目录
 func unzip(zipPath, destDir string) (err error) {
 	// 打开ZIP文件
@@ -53,7 +75,10 @@ func unzip(zipPath, destDir string) (err error) {
 	// 遍历ZIP文件中的所有文件
 	for _, f := range r.File {
 		// 构造文件路径
-		filePath := filepath.Join(destDir, f.Name)
+		filePath, err := zipEntryPath(destDir, f.Name)
+		if err != nil {
+			return err
+		}
 
 		// 如果文件是目录,则创建目录
 		if f.FileInfo().IsDir() {